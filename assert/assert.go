@@ -1,21 +1,48 @@
 // Package assert provides tiny asserting functions for testing.
 // Inspired by github.com/stretchr/testify/assert
 // and https://github.com/benbjohnson/testing
+//
+// Every assertion X(..., msg string) has a variant Xf(..., format,
+// args...) formatting its message only on failure; an empty message
+// tells the assertion instead. Each returns whether it passed,
+// so a test can stop before the failures following:
+//
+//	if !a.NoError(err, "Decoded") {
+//		return
+//	}
 package assert
 
 import (
 	"fmt"
-	"path/filepath"
 	"reflect"
-	"runtime"
+	"runtime/debug"
+	"strings"
 	"testing"
 )
 
+// maxShown is the most shown of a container in failures.
+const maxShown = 200
+
 // Assert wraps a testing.TB for convenient asserting calls.
 type Assert struct {
 	t testing.TB
 }
 
+// message is the message of an assertion, formatted only on failure,
+// or def if it formats to nothing.
+type message struct {
+	def    string
+	format string
+	args   []interface{}
+}
+
+func (m message) String() string {
+	if s := fmt.Sprintf(m.format, m.args...); s != "" {
+		return s
+	}
+	return m.def
+}
+
 // ObjectsAreEqual checks two interfaces with reflect.DeepEqual.
 func ObjectsAreEqual(expected, actual interface{}) bool {
 	if expected == nil || actual == nil {
@@ -25,72 +52,523 @@ func ObjectsAreEqual(expected, actual interface{}) bool {
 	return reflect.DeepEqual(expected, actual)
 }
 
-// IsNil checks an interface{} with the reflect package.
+// IsNil checks an interface{} with the reflect package: a nil
+// interface, or one holding a nil chan, func, map, pointer, slice or
+// unsafe.Pointer, e.g., an error being a (*MyErr)(nil), is nil.
 func IsNil(object interface{}) bool {
 	if object == nil {
 		return true
 	}
 
 	value := reflect.ValueOf(object)
-	kind := value.Kind()
-	if kind >= reflect.Chan && kind <= reflect.Slice && value.IsNil() {
-		return true
+	switch value.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map,
+		reflect.Ptr, reflect.Slice, reflect.UnsafePointer:
+		return value.IsNil()
 	}
 
 	return false
 }
 
-// errorSingle fails and prints the single object
-// along with the message.
+// errorSingle fails t with the single object, an error by its chain,
+// along with the message, reported at the asserting line.
 func errorSingle(t testing.TB, msg string, obj interface{}) {
-	//t.Errorf("%s: %v", msg, obj)
-	_, file, line, _ := runtime.Caller(2)
-	fmt.Printf("\033[31m\t%s:%d: %s\n\n\t\t%#v\033[39m\n\n", filepath.Base(file), line, msg, obj)
-	t.Fail()
+	t.Helper()
+	if err, ok := obj.(error); ok {
+		report(t, msg, showError(err), "")
+		return
+	}
+	report(t, msg, fmt.Sprintf("%#v", obj), "")
 }
 
-// errorCompare fails and prints both the compared objects
-// along with the message.
+// errorCompare fails t with both the compared objects, or only
+// where they differ if composite or long, hexdumped if byte slices,
+// along with the message, reported at the asserting line.
 func errorCompare(t testing.TB, msg string, expected, actual interface{}) {
-	_, file, line, _ := runtime.Caller(2)
-	fmt.Printf("\033[31m\t%s:%d: %s\n\n\t\tgot: %#v\n\033[32m\t\texp: %#v\033[39m\n\n", filepath.Base(file), line, msg, actual, expected)
-	t.Fail()
+	t.Helper()
+	if e, g, ok := byteSlices(expected, actual); ok && string(e) != string(g) {
+		report(t, msg, strings.Join(hexdiff(e, g), "\n\t\t"), "")
+		return
+	}
+	if lines := diff(expected, actual); len(lines) > 0 {
+		report(t, msg, "diff:\n\t\t"+strings.Join(lines, "\n\t\t"), "")
+		return
+	}
+	report(t, msg, fmt.Sprintf("got: %#v", actual), fmt.Sprintf("%#v", expected))
+}
+
+// errorContains fails t with the container, truncated,
+// and the element along with the message.
+func errorContains(t testing.TB, msg string, container, element interface{}) {
+	t.Helper()
+	report(t, msg, fmt.Sprintf("in: %s\n\t\telement: %#v", truncate(fmt.Sprintf("%#v", container)), element), "")
+}
+
+// truncate cuts s down to maxShown bytes.
+func truncate(s string) string {
+	if len(s) > maxShown {
+		return s[:maxShown] + fmt.Sprintf("... (%d more bytes)", len(s)-maxShown)
+	}
+	return s
+}
+
+// errorTruncated is errorSingle with the object truncated.
+func errorTruncated(t testing.TB, msg string, obj interface{}) {
+	t.Helper()
+	report(t, msg, truncate(fmt.Sprintf("%#v", obj)), "")
+}
+
+// lengthOf returns the length of object, a string, slice, array, map
+// or channel; ok is false if it is none of them.
+func lengthOf(object interface{}) (n int, ok bool) {
+	v := reflect.ValueOf(object)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return v.Len(), true
+	}
+	return 0, false
+}
+
+// isEmpty tells if object is nil, of no length if a collection,
+// pointing to an empty one, or else the zero value.
+func isEmpty(object interface{}) bool {
+	if object == nil {
+		return true
+	}
+	if n, ok := lengthOf(object); ok {
+		return n == 0
+	}
+	v := reflect.ValueOf(object)
+	if v.Kind() == reflect.Ptr {
+		return v.IsNil() || isEmpty(v.Elem().Interface())
+	}
+	return v.IsZero()
+}
+
+// identity returns the address object refers to, a pointer, map,
+// channel, func (its code) or slice (its data, with its length);
+// ok is false if it is none of them.
+func identity(object interface{}) (p uintptr, n int, ok bool) {
+	v := reflect.ValueOf(object)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return v.Pointer(), 0, true
+	case reflect.Slice:
+		return v.Pointer(), v.Len(), true
+	}
+	return 0, 0, false
+}
+
+// identical tells if expected and actual are of the same type and identity;
+// ok is false if either has none.
+func identical(expected, actual interface{}) (same, ok bool) {
+	p, n, ok := identity(expected)
+	q, m, ok2 := identity(actual)
+	if !ok || !ok2 {
+		return false, false
+	}
+	return reflect.TypeOf(expected) == reflect.TypeOf(actual) && p == q && n == m, true
 }
 
-func (a *Assert) True(cond bool, msg string) {
+// errorSame fails t with the addresses of both objects,
+// or tells which has none, along with the message.
+func errorSame(t testing.TB, msg string, expected, actual interface{}) {
+	t.Helper()
+	for _, obj := range []interface{}{expected, actual} {
+		if _, _, ok := identity(obj); !ok {
+			errorSingle(t, fmt.Sprintf("%s: %T has no identity, not a pointer", msg, obj), obj)
+			return
+		}
+	}
+	p, _, _ := identity(actual)
+	q, _, _ := identity(expected)
+	report(t, msg, fmt.Sprintf("got: %#x (%T)", p, actual), fmt.Sprintf("%#x (%T)", q, expected))
+}
+
+// isZero tells if object is nil or the zero value of its type,
+// by its IsZero method if it has one, e.g., time.Time ignoring the
+// location; a non-nil pointer is not zero, even to a zero value.
+func isZero(object interface{}) bool {
+	if object == nil {
+		return true
+	}
+	v := reflect.ValueOf(object)
+	if v.Kind() == reflect.Ptr {
+		return v.IsNil()
+	}
+	if z, ok := object.(interface{ IsZero() bool }); ok {
+		return z.IsZero()
+	}
+	return v.IsZero()
+}
+
+// didPanic runs fn, telling if it panicked with value and where.
+// A runtime.Goexit is not recovered but goes on.
+func didPanic(fn func()) (panicked bool, value interface{}, stack string) {
+	panicked = true
+	defer func() {
+		if panicked {
+			value = recover()
+			stack = string(debug.Stack())
+		}
+	}()
+	fn()
+	panicked = false
+	return
+}
+
+// errorPanic fails t with the recovered value and its stack
+// along with the message.
+func errorPanic(t testing.TB, msg string, value interface{}, stack string) {
+	t.Helper()
+	report(t, msg, fmt.Sprintf("panic: %#v\n\n%s", value, stack), "")
+}
+
+// includes tells if container, a string, slice, array or map (by key),
+// includes element; ok is false if it is none of them.
+func includes(container, element interface{}) (found, ok bool) {
+	c := reflect.ValueOf(container)
+	switch c.Kind() {
+	case reflect.String:
+		sub, ok := element.(string)
+		if !ok {
+			return false, false
+		}
+		return strings.Contains(c.String(), sub), true
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < c.Len(); i++ {
+			if ObjectsAreEqual(c.Index(i).Interface(), element) {
+				return true, true
+			}
+		}
+		return false, true
+	case reflect.Map:
+		for _, k := range c.MapKeys() {
+			if ObjectsAreEqual(k.Interface(), element) {
+				return true, true
+			}
+		}
+		return false, true
+	}
+	return false, false
+}
+
+func (a *Assert) True(cond bool, msg string) bool {
+	a.t.Helper()
+	return a.Truef(cond, "%s", msg)
+}
+
+func (a *Assert) Truef(cond bool, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.True", format, args)
 	if !cond {
-		errorSingle(a.t, msg, cond)
+		errorSingle(a.t, msg.String(), cond)
+		return false
 	}
+	return true
+}
+
+func (a *Assert) Condition(cond func() (ok bool, detail string), msg string) bool {
+	a.t.Helper()
+	return a.Conditionf(cond, "%s", msg)
 }
 
-func (a *Assert) Equal(expected, actual interface{}, msg string) {
+func (a *Assert) Conditionf(cond func() (ok bool, detail string), format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.Condition", format, args)
+	if ok, detail := cond(); !ok {
+		if detail == "" {
+			detail = "false"
+		}
+		report(a.t, msg.String(), detail, "")
+		return false
+	}
+	return true
+}
+
+func (a *Assert) ConditionBool(cond func() bool, msg string) bool {
+	a.t.Helper()
+	return a.ConditionBoolf(cond, "%s", msg)
+}
+
+func (a *Assert) ConditionBoolf(cond func() bool, format string, args ...interface{}) bool {
+	a.t.Helper()
+	return a.Conditionf(func() (bool, string) { return cond(), "" }, format, args...)
+}
+
+func (a *Assert) Equal(expected, actual interface{}, msg string) bool {
+	a.t.Helper()
+	return a.Equalf(expected, actual, "%s", msg)
+}
+
+func (a *Assert) Equalf(expected, actual interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.Equal", format, args)
 	if !ObjectsAreEqual(expected, actual) {
-		errorCompare(a.t, msg, expected, actual)
+		errorCompare(a.t, msg.String(), expected, actual)
+		return false
 	}
+	return true
+}
+
+func (a *Assert) NotEqual(expected, actual interface{}, msg string) bool {
+	a.t.Helper()
+	return a.NotEqualf(expected, actual, "%s", msg)
 }
 
-func (a *Assert) NotEqual(expected, actual interface{}, msg string) {
+func (a *Assert) NotEqualf(expected, actual interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.NotEqual", format, args)
 	if ObjectsAreEqual(expected, actual) {
-		errorCompare(a.t, msg, expected, actual)
+		errorCompare(a.t, msg.String(), expected, actual)
+		return false
+	}
+	return true
+}
+
+func (a *Assert) Same(expected, actual interface{}, msg string) bool {
+	a.t.Helper()
+	return a.Samef(expected, actual, "%s", msg)
+}
+
+func (a *Assert) Samef(expected, actual interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.Same", format, args)
+	if same, ok := identical(expected, actual); !same || !ok {
+		errorSame(a.t, msg.String(), expected, actual)
+		return false
+	}
+	return true
+}
+
+func (a *Assert) NotSame(expected, actual interface{}, msg string) bool {
+	a.t.Helper()
+	return a.NotSamef(expected, actual, "%s", msg)
+}
+
+func (a *Assert) NotSamef(expected, actual interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.NotSame", format, args)
+	if same, ok := identical(expected, actual); same || !ok {
+		errorSame(a.t, msg.String(), expected, actual)
+		return false
 	}
+	return true
 }
 
-func (a *Assert) NoError(err error, msg string) {
+func (a *Assert) NoError(err error, msg string) bool {
+	a.t.Helper()
+	return a.NoErrorf(err, "%s", msg)
+}
+
+func (a *Assert) NoErrorf(err error, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.NoError", format, args)
 	if err != nil {
-		errorSingle(a.t, msg, err)
+		errorSingle(a.t, msg.String(), err)
+		return false
 	}
+	return true
 }
 
-func (a *Assert) Nil(obj interface{}, msg string) {
+func (a *Assert) Nil(obj interface{}, msg string) bool {
+	a.t.Helper()
+	return a.Nilf(obj, "%s", msg)
+}
+
+func (a *Assert) Nilf(obj interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.Nil", format, args)
 	if !IsNil(obj) {
-		errorSingle(a.t, msg, obj)
+		errorSingle(a.t, msg.String(), obj)
+		return false
 	}
+	return true
 }
 
-func (a *Assert) NotNil(obj interface{}, msg string) {
+func (a *Assert) NotNil(obj interface{}, msg string) bool {
+	a.t.Helper()
+	return a.NotNilf(obj, "%s", msg)
+}
+
+func (a *Assert) NotNilf(obj interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.NotNil", format, args)
 	if IsNil(obj) {
-		errorSingle(a.t, msg, obj)
+		errorSingle(a.t, msg.String(), obj)
+		return false
+	}
+	return true
+}
+
+func (a *Assert) Contains(container, element interface{}, msg string) bool {
+	a.t.Helper()
+	return a.Containsf(container, element, "%s", msg)
+}
+
+func (a *Assert) Containsf(container, element interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.Contains", format, args)
+	found, ok := includes(container, element)
+	if !ok {
+		errorSingle(a.t, fmt.Sprintf("%s: cannot look for %T in %T", msg, element, container), container)
+		return false
+	}
+	if !found {
+		errorContains(a.t, msg.String(), container, element)
+		return false
+	}
+	return true
+}
+
+func (a *Assert) NotContains(container, element interface{}, msg string) bool {
+	a.t.Helper()
+	return a.NotContainsf(container, element, "%s", msg)
+}
+
+func (a *Assert) NotContainsf(container, element interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.NotContains", format, args)
+	found, ok := includes(container, element)
+	if !ok {
+		errorSingle(a.t, fmt.Sprintf("%s: cannot look for %T in %T", msg, element, container), container)
+		return false
+	}
+	if found {
+		errorContains(a.t, msg.String(), container, element)
+		return false
+	}
+	return true
+}
+
+func (a *Assert) Len(object interface{}, length int, msg string) bool {
+	a.t.Helper()
+	return a.Lenf(object, length, "%s", msg)
+}
+
+func (a *Assert) Lenf(object interface{}, length int, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.Len", format, args)
+	n, ok := lengthOf(object)
+	if !ok {
+		errorTruncated(a.t, fmt.Sprintf("%s: %T has no length", msg, object), object)
+		return false
+	}
+	if n != length {
+		errorTruncated(a.t, fmt.Sprintf("%s: len %d, want %d", msg, n, length), object)
+		return false
+	}
+	return true
+}
+
+func (a *Assert) Empty(object interface{}, msg string) bool {
+	a.t.Helper()
+	return a.Emptyf(object, "%s", msg)
+}
+
+func (a *Assert) Emptyf(object interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.Empty", format, args)
+	if !isEmpty(object) {
+		errorTruncated(a.t, msg.String(), object)
+		return false
+	}
+	return true
+}
+
+func (a *Assert) NotEmpty(object interface{}, msg string) bool {
+	a.t.Helper()
+	return a.NotEmptyf(object, "%s", msg)
+}
+
+func (a *Assert) NotEmptyf(object interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.NotEmpty", format, args)
+	if isEmpty(object) {
+		errorTruncated(a.t, msg.String(), object)
+		return false
+	}
+	return true
+}
+
+func (a *Assert) Zero(object interface{}, msg string) bool {
+	a.t.Helper()
+	return a.Zerof(object, "%s", msg)
+}
+
+func (a *Assert) Zerof(object interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.Zero", format, args)
+	if !isZero(object) {
+		errorSingle(a.t, fmt.Sprintf("%s: %T not zero", msg, object), object)
+		return false
+	}
+	return true
+}
+
+func (a *Assert) NotZero(object interface{}, msg string) bool {
+	a.t.Helper()
+	return a.NotZerof(object, "%s", msg)
+}
+
+func (a *Assert) NotZerof(object interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.NotZero", format, args)
+	if isZero(object) {
+		errorSingle(a.t, fmt.Sprintf("%s: %T zero", msg, object), object)
+		return false
+	}
+	return true
+}
+
+func (a *Assert) Panics(fn func(), msg string) bool {
+	a.t.Helper()
+	return a.Panicsf(fn, "%s", msg)
+}
+
+func (a *Assert) Panicsf(fn func(), format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.Panics", format, args)
+	if panicked, _, _ := didPanic(fn); !panicked {
+		errorSingle(a.t, msg.String()+": did not panic", fn)
+		return false
+	}
+	return true
+}
+
+func (a *Assert) PanicsWithValue(expected interface{}, fn func(), msg string) bool {
+	a.t.Helper()
+	return a.PanicsWithValuef(expected, fn, "%s", msg)
+}
+
+func (a *Assert) PanicsWithValuef(expected interface{}, fn func(), format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.PanicsWithValue", format, args)
+	panicked, value, stack := didPanic(fn)
+	if !panicked {
+		errorSingle(a.t, msg.String()+": did not panic", fn)
+		return false
+	}
+	if !ObjectsAreEqual(expected, value) {
+		errorPanic(a.t, fmt.Sprintf("%s: want panic %#v", msg, expected), value, stack)
+		return false
+	}
+	return true
+}
+
+func (a *Assert) NotPanics(fn func(), msg string) bool {
+	a.t.Helper()
+	return a.NotPanicsf(fn, "%s", msg)
+}
+
+func (a *Assert) NotPanicsf(fn func(), format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.NotPanics", format, args)
+	if panicked, value, stack := didPanic(fn); panicked {
+		errorPanic(a.t, msg.String(), value, stack)
+		return false
 	}
+	return true
 }
 
 // NewAssert provides an Assert instance.
@@ -1,7 +1,15 @@
 package assert_test
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
+	"unsafe"
 
 	. "github.com/ShevaXu/web-utils/assert"
 )
@@ -48,4 +56,443 @@ func TestIsNil(t *testing.T) {
 	if IsNil(vs0) {
 		t.Error("Zero slice should not be nil")
 	}
+
+	var vp *codeError
+	var ve error = vp
+	if !IsNil(ve) {
+		t.Error("Error holding a nil pointer should be nil")
+	}
+	vi = vp
+	if !IsNil(vi) {
+		t.Error("Interface holding a nil pointer should be nil")
+	}
+	if ve = (&codeError{500}); IsNil(ve) {
+		t.Error("Error holding a pointer should not be nil")
+	}
+
+	var vu unsafe.Pointer
+	if !IsNil(vu) {
+		t.Error("Empty unsafe.Pointer should be nil")
+	}
+	if vu = unsafe.Pointer(&v); IsNil(vu) {
+		t.Error("unsafe.Pointer should not be nil")
+	}
+
+	var vf func()
+	if !IsNil(vf) {
+		t.Error("Empty func should be nil")
+	}
+}
+
+// mockT records the failures of the assertions on it.
+type mockT struct {
+	testing.TB
+	helpers  int
+	errors   []string
+	failed   bool
+	logs     []string
+	cleanups []func()
+}
+
+func (m *mockT) Helper() {
+	m.helpers++
+}
+
+func (m *mockT) Errorf(format string, args ...interface{}) {
+	m.errors = append(m.errors, fmt.Sprintf(format, args...))
+}
+
+func (m *mockT) Fail() {
+	m.failed = true
+}
+
+func (m *mockT) Log(args ...interface{}) {
+	m.logs = append(m.logs, fmt.Sprint(args...))
+}
+
+func (m *mockT) Cleanup(f func()) {
+	m.cleanups = append(m.cleanups, f)
+}
+
+// finish runs the cleanups as the test finishing does.
+func (m *mockT) finish() {
+	for i := len(m.cleanups) - 1; i >= 0; i-- {
+		m.cleanups[i]()
+	}
+}
+
+func TestAssert_Errorf(t *testing.T) {
+	m := &mockT{}
+	a := NewAssert(m)
+	a.True(true, "passes")
+	a.Equal(1, 1, "passes")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertions failed: %q", m.errors)
+	}
+
+	a.True(false, "true fails")
+	a.Equal(1, 2, "equal fails")
+	a.NotEqual(1, 1, "not equal fails")
+	a.NoError(errors.New("boom"), "no error fails")
+	a.Nil(1, "nil fails")
+	a.NotNil(nil, "not nil fails")
+	if len(m.errors) != 6 {
+		t.Fatalf("Failures not reported by Errorf: %q", m.errors)
+	}
+	for i, msg := range []string{"true fails", "equal fails", "not equal fails", "no error fails", "nil fails", "not nil fails"} {
+		if !strings.Contains(m.errors[i], msg) {
+			t.Errorf("Failure %d %q without its message %q", i, m.errors[i], msg)
+		}
+	}
+	if !strings.Contains(m.errors[1], "got: 2") || !strings.Contains(m.errors[1], "exp: 1") {
+		t.Errorf("Compared objects not reported: %q", m.errors[1])
+	}
+	if m.helpers < 2*len(m.errors) {
+		t.Errorf("Assertions not marked as helpers: %d calls", m.helpers)
+	}
+}
+
+// panicky panics if formatted, as a message should not be on success.
+type panicky struct{}
+
+func (panicky) String() string {
+	panic("formatted")
+}
+
+func TestAssert_Formatted(t *testing.T) {
+	m := &mockT{}
+	a := NewAssert(m)
+	a.Truef(true, "lazy %v", panicky{})
+	a.Equalf(1, 1, "lazy %v", panicky{})
+	a.Lenf("ab", 2, "lazy %v", panicky{})
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertions failed: %q", m.errors)
+	}
+
+	a.Equalf(1, 2, "case %d of %s", 3, "equal")
+	a.Lenf("ab", 1, "case %d", 4)
+	a.Equal(1, 2, "100% literal")
+	a.Equal(1, 2, "")
+	a.Lenf("ab", 1, "")
+	if len(m.errors) != 5 {
+		t.Fatalf("Failures not reported: %q", m.errors)
+	}
+	for i, msg := range []string{"case 3 of equal", "case 4: len 2, want 1", "100% literal", "assert.Equal", "assert.Len: len 2"} {
+		if !strings.Contains(m.errors[i], msg) {
+			t.Errorf("Failure %d %q without its message %q", i, m.errors[i], msg)
+		}
+	}
+}
+
+func TestAssert_FormattedVariants(t *testing.T) {
+	typ := reflect.TypeOf(&Assert{})
+	for i := 0; i < typ.NumMethod(); i++ {
+		name := typ.Method(i).Name
+		if strings.HasSuffix(name, "f") {
+			continue
+		}
+		if _, ok := typ.MethodByName(name + "f"); !ok {
+			t.Errorf("No %sf for %s", name, name)
+		}
+	}
+}
+
+func TestAssert_Returns(t *testing.T) {
+	never := func() bool { return false }
+	passing := map[string]func(a *Assert) bool{
+		"True":     func(a *Assert) bool { return a.True(true, "") },
+		"Equalf":   func(a *Assert) bool { return a.Equalf(1, 1, "") },
+		"NoError":  func(a *Assert) bool { return a.NoError(nil, "") },
+		"Contains": func(a *Assert) bool { return a.Contains("abc", "b", "") },
+		"Len":      func(a *Assert) bool { return a.Len([]int{1}, 1, "") },
+		"Panics":   func(a *Assert) bool { return a.Panics(func() { panic(1) }, "") },
+		"ErrorAs":  func(a *Assert) bool { var e *os.PathError; return a.ErrorAs(&os.PathError{}, &e, "") },
+		"InDelta":  func(a *Assert) bool { return a.InDelta(1, 1.1, 0.2, "") },
+		"Less":     func(a *Assert) bool { return a.Less(1, 2, "") },
+		"Never":    func(a *Assert) bool { return a.Never(never, time.Millisecond, time.Millisecond, "") },
+		"JSONEq":   func(a *Assert) bool { return a.JSONEq(`{"a":1}`, `{ "a": 1 }`, "") },
+		"Match":    func(a *Assert) bool { return a.Match(`^a`, "ab", "") },
+		"WithinRange": func(a *Assert) bool {
+			now := time.Now()
+			return a.WithinRange(now, now, now, "")
+		},
+	}
+	failing := map[string]func(a *Assert) bool{
+		"True":     func(a *Assert) bool { return a.True(false, "") },
+		"Equalf":   func(a *Assert) bool { return a.Equalf(1, 2, "") },
+		"NoError":  func(a *Assert) bool { return a.NoError(errors.New("x"), "") },
+		"Contains": func(a *Assert) bool { return a.Contains(1, 1, "") },
+		"Len":      func(a *Assert) bool { return a.Len(1, 1, "") },
+		"Panics":   func(a *Assert) bool { return a.Panics(func() {}, "") },
+		"ErrorAs":  func(a *Assert) bool { return a.ErrorAs(errors.New("x"), nil, "") },
+		"InDelta":  func(a *Assert) bool { return a.InDelta("1", 1, 1, "") },
+		"Less":     func(a *Assert) bool { return a.Less(2, 1, "") },
+		"JSONEq":   func(a *Assert) bool { return a.JSONEq(`{`, `{}`, "") },
+		"Match":    func(a *Assert) bool { return a.Match(`(`, "", "") },
+		"WithinRange": func(a *Assert) bool {
+			return a.WithinRange(time.Time{}, time.Now(), time.Now(), "")
+		},
+	}
+	for name, f := range passing {
+		m := &mockT{}
+		if !f(NewAssert(m)) || len(m.errors) != 0 {
+			t.Errorf("%s: passing returned false: %q", name, m.errors)
+		}
+	}
+	for name, f := range failing {
+		m := &mockT{}
+		if f(NewAssert(m)) || len(m.errors) != 1 {
+			t.Errorf("%s: failing returned true: %q", name, m.errors)
+		}
+	}
+}
+
+func TestAssert_Contains(t *testing.T) {
+	cases := []struct {
+		name               string
+		container, element interface{}
+		contains           bool
+	}{
+		{"substring", "hello world", "lo w", true},
+		{"no substring", "hello world", "bye", false},
+		{"slice element", []int{1, 2, 3}, 2, true},
+		{"no slice element", []int{1, 2, 3}, 4, false},
+		{"deep element", []map[string]int{{"a": 1}}, map[string]int{"a": 1}, true},
+		{"array element", [2]string{"a", "b"}, "b", true},
+		{"map key", map[string]int{"a": 1}, "a", true},
+		{"no map key", map[string]int{"a": 1}, 1, false},
+	}
+	for _, c := range cases {
+		m := &mockT{}
+		a := NewAssert(m)
+		a.Contains(c.container, c.element, c.name)
+		a.NotContains(c.container, c.element, c.name)
+		if len(m.errors) != 1 {
+			t.Fatalf("%s: not failed once: %q", c.name, m.errors)
+		}
+		if !strings.Contains(m.errors[0], fmt.Sprintf("element: %#v", c.element)) {
+			t.Errorf("%s: element not shown: %q", c.name, m.errors[0])
+		}
+		m.errors = nil
+		if a.Contains(c.container, c.element, c.name); (len(m.errors) == 0) != c.contains {
+			t.Errorf("%s: Contains is %v, not %v", c.name, len(m.errors) == 0, c.contains)
+		}
+	}
+
+	m := &mockT{}
+	a := NewAssert(m)
+	a.Contains(42, 4, "unsupported")
+	a.NotContains("42", 4, "not a substring")
+	if len(m.errors) != 2 || !strings.Contains(m.errors[0], "cannot look for int in int") ||
+		!strings.Contains(m.errors[1], "cannot look for int in string") {
+		t.Errorf("Unsupported types not failed: %q", m.errors)
+	}
+
+	m = &mockT{}
+	NewAssert(m).Contains(strings.Repeat("a", 1000), "b", "long")
+	if !strings.Contains(m.errors[0], "... (802 more bytes)") {
+		t.Errorf("Container not truncated: %q", m.errors[0])
+	}
+}
+
+func TestAssert_Len(t *testing.T) {
+	ch := make(chan int, 3)
+	ch <- 1
+	cases := []struct {
+		name   string
+		object interface{}
+		len    int
+	}{
+		{"string", "abc", 3},
+		{"slice", []int{1, 2}, 2},
+		{"array", [4]bool{}, 4},
+		{"map", map[string]int{"a": 1}, 1},
+		{"chan", ch, 1},
+		{"nil slice", []int(nil), 0},
+	}
+	for _, c := range cases {
+		m := &mockT{}
+		a := NewAssert(m)
+		a.Len(c.object, c.len, c.name)
+		a.Len(c.object, c.len+1, c.name)
+		if len(m.errors) != 1 {
+			t.Fatalf("%s: not failed once: %q", c.name, m.errors)
+		}
+		if want := fmt.Sprintf("len %d, want %d", c.len, c.len+1); !strings.Contains(m.errors[0], want) {
+			t.Errorf("%s: %q missing in %q", c.name, want, m.errors[0])
+		}
+	}
+
+	m := &mockT{}
+	a := NewAssert(m)
+	a.Len(42, 0, "unsupported")
+	a.Len(strings.Repeat("a", 1000), 1, "long")
+	if len(m.errors) != 2 || !strings.Contains(m.errors[0], "int has no length") ||
+		!strings.Contains(m.errors[1], "more bytes)") {
+		t.Errorf("Unsupported or long not failed well: %q", m.errors)
+	}
+}
+
+func TestAssert_Empty(t *testing.T) {
+	var nilPtr *int
+	zero, one := 0, 1
+	empties := []interface{}{nil, "", []int{}, []int(nil), map[string]int{}, make(chan int), [0]int{},
+		0, false, struct{ A int }{}, nilPtr, &zero, error(nil)}
+	nonEmpties := []interface{}{"a", []int{0}, map[string]int{"": 0}, [1]int{}, 1, true,
+		struct{ A int }{1}, &one, errors.New("x")}
+
+	for _, obj := range empties {
+		m := &mockT{}
+		a := NewAssert(m)
+		a.Empty(obj, "empty")
+		a.NotEmpty(obj, "not empty")
+		if len(m.errors) != 1 || !strings.Contains(m.errors[0], "not empty") {
+			t.Errorf("%#v: not empty: %q", obj, m.errors)
+		}
+	}
+	for _, obj := range nonEmpties {
+		m := &mockT{}
+		a := NewAssert(m)
+		a.NotEmpty(obj, "not empty")
+		a.Empty(obj, "empty")
+		if len(m.errors) != 1 || strings.Contains(m.errors[0], "not empty") {
+			t.Errorf("%#v: empty: %q", obj, m.errors)
+		}
+	}
+}
+
+func TestAssert_Zero(t *testing.T) {
+	var nilPtr *struct{ A int }
+	var nilErr error
+	zeros := []interface{}{nil, 0, 0.0, uint8(0), "", false, struct{ A int }{}, nilPtr, nilErr,
+		[]int(nil), time.Time{}, time.Time{}.In(time.FixedZone("X", 3600)), (*time.Time)(nil)}
+	nonZeros := []interface{}{1, -0.5, uint8(1), "a", true, struct{ A int }{1}, &struct{ A int }{},
+		[]int{}, errors.New(""), time.Unix(0, 0), &time.Time{}}
+
+	for _, obj := range zeros {
+		m := &mockT{}
+		a := NewAssert(m)
+		a.Zero(obj, "zero")
+		a.NotZero(obj, "not zero")
+		if len(m.errors) != 1 || !strings.Contains(m.errors[0], fmt.Sprintf("not zero: %T zero", obj)) {
+			t.Errorf("%#v: not zero: %q", obj, m.errors)
+		}
+	}
+	for _, obj := range nonZeros {
+		m := &mockT{}
+		a := NewAssert(m)
+		a.NotZero(obj, "not zero")
+		a.Zero(obj, "zero")
+		if len(m.errors) != 1 || !strings.Contains(m.errors[0], fmt.Sprintf("zero: %T not zero", obj)) {
+			t.Errorf("%#v: zero: %q", obj, m.errors)
+		}
+	}
+}
+
+func TestAssert_Panics(t *testing.T) {
+	errBoom := errors.New("boom")
+	m := &mockT{}
+	a := NewAssert(m)
+	a.Panics(func() { panic("boom") }, "panics")
+	a.PanicsWithValue("boom", func() { panic("boom") }, "panics with value")
+	a.PanicsWithValue(errBoom, func() { panic(errBoom) }, "panics with error")
+	a.NotPanics(func() {}, "not panics")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertions failed: %q", m.errors)
+	}
+
+	a.Panics(func() {}, "no panic")
+	a.PanicsWithValue("boom", func() {}, "no panic with value")
+	a.PanicsWithValue("boom", func() { panic(errBoom) }, "other value")
+	a.NotPanics(func() { panic("oops") }, "panicked")
+	if len(m.errors) != 4 {
+		t.Fatalf("Failing assertions not failed: %q", m.errors)
+	}
+	for i, want := range []string{"no panic: did not panic", "no panic with value: did not panic",
+		`other value: want panic "boom"`, `panic: "oops"`} {
+		if !strings.Contains(m.errors[i], want) {
+			t.Errorf("%q missing in %q", want, m.errors[i])
+		}
+	}
+	if !strings.Contains(m.errors[3], "goroutine ") || !strings.Contains(m.errors[2], "errorString") {
+		t.Errorf("Recovered value or stack not shown: %q", m.errors[2:])
+	}
+
+	// runtime.Goexit goes on
+	exited := make(chan bool)
+	go func() {
+		defer func() { exited <- true }()
+		NewAssert(m).NotPanics(runtime.Goexit, "goexit")
+		exited <- false
+	}()
+	if !<-exited {
+		t.Error("Goexit swallowed")
+	}
+}
+
+func TestAssert_Same(t *testing.T) {
+	type box struct{ N int }
+	p, q := &box{1}, &box{1}
+	s := []int{1, 2, 3}
+	m1 := map[string]int{}
+	var err error = &os.PathError{}
+
+	m := &mockT{}
+	a := NewAssert(m)
+	a.Same(p, p, "pointer")
+	a.Same(interface{}(p), p, "interface holding a pointer")
+	a.Same(err, err, "error")
+	a.Same(m1, m1, "map")
+	a.Same(s, s, "slice")
+	a.NotSame(p, q, "equal pointers")
+	a.NotSame(s, s[:2], "shorter slice")
+	a.NotSame(s, s[1:], "sliced data")
+	a.NotSame(p, (*struct{ N int })(nil), "other type")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertions failed: %q", m.errors)
+	}
+
+	a.Same(p, q, "distinct")
+	a.NotSame(p, p, "identical")
+	a.Same(*p, *p, "values")
+	a.NotSame(1, p, "int")
+	if len(m.errors) != 4 {
+		t.Fatalf("Failures not reported: %q", m.errors)
+	}
+	addrs := []string{fmt.Sprintf("got: %p (*assert_test.box)", q), fmt.Sprintf("exp: %p (*assert_test.box)", p)}
+	for _, s := range addrs {
+		if !strings.Contains(m.errors[0], s) {
+			t.Errorf("Address %q not shown: %q", s, m.errors[0])
+		}
+	}
+	if !strings.Contains(m.errors[1], fmt.Sprintf("got: %p", p)) ||
+		!strings.Contains(m.errors[2], "values: assert_test.box has no identity, not a pointer") ||
+		!strings.Contains(m.errors[3], "int: int has no identity") {
+		t.Errorf("Failures not told well: %q", m.errors[1:])
+	}
+}
+
+func TestAssert_Condition(t *testing.T) {
+	calls := 0
+	retries := func() (bool, string) {
+		calls++
+		return false, "expected 3 retries within 200ms, observed 5 in 612ms"
+	}
+	m := &mockT{}
+	a := NewAssert(m)
+	a.Condition(func() (bool, string) { return true, "unused" }, "holds")
+	a.ConditionBool(func() bool { return true }, "holds")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertions failed: %q", m.errors)
+	}
+
+	a.Condition(retries, "retried")
+	a.ConditionBoolf(func() bool { calls++; return false }, "bool %d", 2)
+	if calls != 2 {
+		t.Errorf("Closures called %d times, not once each", calls)
+	}
+	if len(m.errors) != 2 || !strings.Contains(m.errors[0], "retried\n\n\t\texpected 3 retries within 200ms, observed 5 in 612ms") ||
+		!strings.Contains(m.errors[1], "bool 2\n\n\t\tfalse") {
+		t.Errorf("Failures not told well: %q", m.errors)
+	}
 }
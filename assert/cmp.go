@@ -0,0 +1,123 @@
+package assert
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// CmpOption configures how EqualOpt compares;
+// it returns an error if the setting cannot be applied.
+type CmpOption func(o *cmpOptions) error
+
+type cmpOptions struct {
+	ignored     map[string]bool
+	comparers   map[reflect.Type]reflect.Value
+	equateEmpty bool
+}
+
+// IgnoreFields ignores the struct fields at the paths, dotted field
+// names from the compared values through any pointers, slices and
+// maps, e.g., "Spec.Created" of a Deployment or of each in a slice.
+func IgnoreFields(paths ...string) CmpOption {
+	return func(o *cmpOptions) error {
+		for _, p := range paths {
+			if p == "" || strings.HasPrefix(p, ".") || strings.HasSuffix(p, ".") {
+				return fmt.Errorf("bad field path %q", p)
+			}
+			o.ignored[p] = true
+		}
+		return nil
+	}
+}
+
+// Comparer compares the values of type T by f, a func(a, b T) bool,
+// instead of walking them; T is matched exactly, and values read
+// through unexported fields are walked still.
+func Comparer(f interface{}) CmpOption {
+	return func(o *cmpOptions) error {
+		v := reflect.ValueOf(f)
+		if v.Kind() != reflect.Func || v.IsNil() {
+			return fmt.Errorf("comparer %T is no func", f)
+		}
+		if t := v.Type(); t.NumIn() != 2 || t.In(0) != t.In(1) || t.NumOut() != 1 || t.Out(0).Kind() != reflect.Bool {
+			return fmt.Errorf("comparer %T is no func(a, b T) bool", f)
+		}
+		o.comparers[v.Type().In(0)] = v
+		return nil
+	}
+}
+
+// EquateEmpty compares a nil slice or map equal to an empty one.
+func EquateEmpty() CmpOption {
+	return func(o *cmpOptions) error {
+		o.equateEmpty = true
+		return nil
+	}
+}
+
+func newCmpOptions(opts []CmpOption) (*cmpOptions, error) {
+	o := &cmpOptions{
+		ignored:   map[string]bool{},
+		comparers: map[reflect.Type]reflect.Value{},
+	}
+	for _, opt := range opts {
+		if opt == nil {
+			return nil, errors.New("nil option")
+		}
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+func (o *cmpOptions) ignores(fields string) bool {
+	return o != nil && o.ignored[fields]
+}
+
+// compare compares exp and got by their comparer; ok is false
+// if they have none.
+func (o *cmpOptions) compare(exp, got reflect.Value) (equal, ok bool) {
+	if o == nil {
+		return false, false
+	}
+	f, ok := o.comparers[exp.Type()]
+	if !ok || !exp.CanInterface() || !got.CanInterface() {
+		return false, false
+	}
+	return f.Call([]reflect.Value{exp, got})[0].Bool(), true
+}
+
+func (o *cmpOptions) bothEmpty(exp, got reflect.Value) bool {
+	return o != nil && o.equateEmpty && exp.Len() == 0 && got.Len() == 0
+}
+
+// EqualOpt asserts expected equals actual as compared by the options,
+// walking them as the diff of Equal does, which tells where they differ.
+func (a *Assert) EqualOpt(expected, actual interface{}, msg string, opts ...CmpOption) bool {
+	a.t.Helper()
+	return a.EqualOptf(expected, actual, opts, "%s", msg)
+}
+
+// EqualOptf is EqualOpt with a formatted message.
+func (a *Assert) EqualOptf(expected, actual interface{}, opts []CmpOption, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.EqualOpt", format, args)
+	o, err := newCmpOptions(opts)
+	if err != nil {
+		errorSingle(a.t, fmt.Sprintf("%s: bad option: %v", msg, err), opts)
+		return false
+	}
+	d := &differ{opts: o}
+	d.walk("", "", reflect.ValueOf(expected), reflect.ValueOf(actual))
+	if len(d.lines) == 0 {
+		return true
+	}
+	if d.more > 0 {
+		d.lines = append(d.lines, fmt.Sprintf("... and %d more", d.more))
+	}
+	report(a.t, msg.String(), "diff:\n\t\t"+strings.Join(d.lines, "\n\t\t"), "")
+	return false
+}
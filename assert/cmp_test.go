@@ -0,0 +1,85 @@
+package assert_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils/assert"
+)
+
+type item struct {
+	ID      string
+	Name    string
+	Created time.Time
+	Tags    []string
+}
+
+type order struct {
+	ID    string
+	Items []item
+	Meta  map[string]int
+}
+
+func TestAssert_EqualOpt(t *testing.T) {
+	now := time.Now()
+	exp := order{ID: "o1", Items: []item{{ID: "i1", Name: "pen"}, {ID: "i2", Name: "ink"}}}
+	got := order{ID: "o2", Items: []item{{ID: "x1", Name: "pen", Created: now}, {ID: "x2", Name: "ink", Created: now}},
+		Meta: map[string]int{}}
+	for i := range got.Items {
+		got.Items[i].Tags = []string{}
+	}
+	sameDay := Comparer(func(a, b time.Time) bool { return a.Truncate(24 * time.Hour).Equal(b.Truncate(24 * time.Hour)) })
+	caseless := Comparer(func(a, b string) bool { return strings.EqualFold(a, b) })
+
+	m := &mockT{}
+	a := NewAssert(m)
+	a.EqualOpt(exp, got, "all", IgnoreFields("ID", "Items.ID", "Items.Created"), EquateEmpty())
+	a.EqualOpt(now, now.Add(time.Nanosecond).Truncate(24*time.Hour), "comparer", sameDay)
+	a.EqualOpt([]string{"A", "b"}, []string{"a", "B"}, "comparer in slice", caseless)
+	a.EqualOpt(item{Name: "PEN", Tags: []string{}}, item{Name: "pen"}, "combined", caseless, EquateEmpty())
+	a.EqualOpt(nil, nil, "nil")
+	a.EqualOpt(1, 1, "scalar")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertions failed: %q", m.errors)
+	}
+
+	for _, c := range []struct {
+		name     string
+		opts     []CmpOption
+		lines    []string
+		notLines []string
+	}{
+		{"none", nil, []string{".ID: got \"o2\", want \"o1\"", ".Items[0].ID:", ".Items[1].Created.wall:", ".Meta: got map[string]int{}, want map[string]int(nil)", ".Items[0].Tags:"}, nil},
+		{"ignored", []CmpOption{IgnoreFields("ID", "Items.ID", "Items.Created")},
+			[]string{".Meta:", ".Items[0].Tags:"}, []string{".ID:", ".Items[0].ID:", "Created"}},
+		{"equate empty", []CmpOption{EquateEmpty()}, []string{".ID:"}, []string{".Meta:", "Tags"}},
+		{"comparer", []CmpOption{Comparer(func(a, b []item) bool { return len(a) == len(b) })}, []string{".ID:"}, []string{"Items"}},
+	} {
+		m := &mockT{}
+		if NewAssert(m).EqualOpt(exp, got, c.name, c.opts...) || len(m.errors) != 1 {
+			t.Fatalf("%s: not failed once: %q", c.name, m.errors)
+		}
+		for _, l := range c.lines {
+			if !strings.Contains(m.errors[0], "\t\t"+l) {
+				t.Errorf("%s: %q missing in %q", c.name, l, m.errors[0])
+			}
+		}
+		for _, l := range c.notLines {
+			if strings.Contains(m.errors[0], l) {
+				t.Errorf("%s: %q not ignored in %q", c.name, l, m.errors[0])
+			}
+		}
+	}
+
+	m = &mockT{}
+	a = NewAssert(m)
+	a.EqualOpt("A", "b", "by comparer", caseless)
+	a.EqualOpt(1, 1, "bad comparer", Comparer(func(a int) bool { return true }))
+	a.EqualOpt(1, 1, "bad path", IgnoreFields("Items."))
+	if len(m.errors) != 3 || !strings.Contains(m.errors[0], `got "b", want "A", by the comparer`) ||
+		!strings.Contains(m.errors[1], "bad option: comparer func(int) bool is no func(a, b T) bool") ||
+		!strings.Contains(m.errors[2], `bad option: bad field path "Items."`) {
+		t.Errorf("Failures not told well: %q", m.errors)
+	}
+}
@@ -0,0 +1,73 @@
+package assert
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// collector tallies the assertions on a test and their failures.
+type collector struct {
+	mu       sync.Mutex
+	total    int
+	failures []string
+}
+
+// collectors are those of the tests collecting, by their testing.TB.
+var collectors sync.Map
+
+func collectorOf(t testing.TB) *collector {
+	if c, ok := collectors.Load(t); ok {
+		return c.(*collector)
+	}
+	return nil
+}
+
+func (c *collector) count() {
+	c.mu.Lock()
+	c.total++
+	c.mu.Unlock()
+}
+
+func (c *collector) fail(msg string) {
+	c.mu.Lock()
+	c.failures = append(c.failures, msg)
+	c.mu.Unlock()
+}
+
+// summary tells how many assertions failed and which, e.g.,
+// "7 assertions, 3 failed:" and a line of each message.
+func (c *collector) summary() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := fmt.Sprintf("%d assertions, %d failed", c.total, len(c.failures))
+	if len(c.failures) > 0 {
+		s += ":\n\t" + strings.Join(c.failures, "\n\t")
+	}
+	return s
+}
+
+// begin counts an assertion on a.t if collecting,
+// returning its message.
+func (a *Assert) begin(def, format string, args []interface{}) message {
+	if c := collectorOf(a.t); c != nil {
+		c.count()
+	}
+	return message{def, format, args}
+}
+
+// NewCollectingAssert provides an Assert that, with any other on t,
+// has its assertions tallied, and logs how many failed and which
+// when t finishes; each failure still fails t as it happens.
+// Tests, e.g., parallel subtests, collect on their own.
+func NewCollectingAssert(t testing.TB) *Assert {
+	if _, loaded := collectors.LoadOrStore(t, &collector{}); !loaded {
+		t.Cleanup(func() {
+			c := collectorOf(t)
+			collectors.Delete(t)
+			t.Log(c.summary())
+		})
+	}
+	return NewAssert(t)
+}
@@ -0,0 +1,73 @@
+package assert_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils/assert"
+)
+
+func TestNewCollectingAssert(t *testing.T) {
+	m := &mockT{}
+	a := NewCollectingAssert(m)
+	a.Equal(1, 1, "one")
+	a.Equal(1, 2, "two")
+	a.True(true, "three")
+	a.Len("abc", 2, "four")
+	a.JSONEq(`{}`, `{}`, "five")
+	NewAssert(m).Nil(1, "six by another")
+	a.NoError(nil, "seven")
+	if len(m.errors) != 3 {
+		t.Fatalf("Failures not reported as they happen: %q", m.errors)
+	}
+	if len(m.logs) != 0 {
+		t.Fatalf("Summary before the test finished: %q", m.logs)
+	}
+
+	m.finish()
+	if len(m.logs) != 1 || m.logs[0] != "7 assertions, 3 failed:\n\ttwo\n\tfour: len 3, want 2\n\tsix by another" {
+		t.Errorf("Summary not told: %q", m.logs)
+	}
+	NewAssert(m).True(false, "after")
+	if len(m.logs) != 1 || len(m.cleanups) != 1 {
+		t.Errorf("Still collecting after finished: %q", m.logs)
+	}
+
+	passing := &mockT{}
+	NewCollectingAssert(passing).True(true, "once")
+	NewCollectingAssert(passing).True(true, "twice")
+	passing.finish()
+	if len(passing.logs) != 1 || passing.logs[0] != "2 assertions, 0 failed" {
+		t.Errorf("Not one collector for the test: %q", passing.logs)
+	}
+}
+
+func TestNewCollectingAssert_Parallel(t *testing.T) {
+	subtests := make([]*mockT, 4)
+	var wg sync.WaitGroup
+	for i := range subtests {
+		m := &mockT{}
+		subtests[i] = m
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			a := NewCollectingAssert(m)
+			for j := 0; j < 10; j++ {
+				a.True(j >= n, "small")
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, m := range subtests {
+		m.finish()
+		want := "10 assertions, 0 failed"
+		if i > 0 {
+			want = fmt.Sprintf("10 assertions, %d failed:%s", i, strings.Repeat("\n\tsmall", i))
+		}
+		if len(m.logs) != 1 || m.logs[0] != want {
+			t.Errorf("Subtest %d: %q, want %q", i, m.logs, want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package assert
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+const (
+	maxDepth   = 32 // of the values walked, against cycles
+	maxDiffs   = 20 // lines of a diff
+	maxElide   = 64 // of a string or bytes shown whole
+	elideWidth = 16 // shown around where they differ
+)
+
+// diff returns the paths where actual differs from expected, e.g.,
+// ".Spec.Replicas: got 3, want 5", for composite values or long
+// strings; nil for others, shown whole instead.
+func diff(expected, actual interface{}) []string {
+	exp, got := reflect.ValueOf(expected), reflect.ValueOf(actual)
+	if !exp.IsValid() || !got.IsValid() || exp.Type() != got.Type() {
+		return nil
+	}
+	switch exp.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Ptr:
+	case reflect.String:
+		if exp.Len() <= maxElide && got.Len() <= maxElide {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	d := &differ{}
+	d.walk("", "", exp, got)
+	if d.more > 0 {
+		d.lines = append(d.lines, fmt.Sprintf("... and %d more", d.more))
+	}
+	return d.lines
+}
+
+type differ struct {
+	lines []string
+	more  int // differences not in lines
+	depth int
+	opts  *cmpOptions // of EqualOpt, if any
+}
+
+func (d *differ) add(path, format string, args ...interface{}) {
+	if len(d.lines) >= maxDiffs {
+		d.more++
+		return
+	}
+	if path != "" {
+		format = path + ": " + format
+	}
+	d.lines = append(d.lines, fmt.Sprintf(format, args...))
+}
+
+// walk diffs exp and got at path, where fields names the struct
+// fields walked through, e.g., "Spec.Ports" for ".Spec.Ports[0]".
+func (d *differ) walk(path, fields string, exp, got reflect.Value) {
+	if !exp.IsValid() || !got.IsValid() {
+		if exp.IsValid() != got.IsValid() {
+			d.add(path, "got %s, want %s", show(got), show(exp))
+		}
+		return
+	}
+	if exp.Type() != got.Type() {
+		d.add(path, "got %s (%s), want %s (%s)", show(got), got.Type(), show(exp), exp.Type())
+		return
+	}
+	if d.depth >= maxDepth {
+		d.add(path, "differ too deep")
+		return
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+	if equal, ok := d.opts.compare(exp, got); ok {
+		if !equal {
+			d.add(path, "got %s, want %s, by the comparer", show(got), show(exp))
+		}
+		return
+	}
+
+	switch exp.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if exp.IsNil() || got.IsNil() {
+			if exp.IsNil() != got.IsNil() {
+				d.add(path, "got %s, want %s", show(got), show(exp))
+			}
+			return
+		}
+		if exp.Kind() == reflect.Ptr && exp.Pointer() == got.Pointer() {
+			return
+		}
+		d.walk(path, fields, exp.Elem(), got.Elem())
+	case reflect.Struct:
+		for i := 0; i < exp.NumField(); i++ {
+			name := exp.Type().Field(i).Name
+			at := name
+			if fields != "" {
+				at = fields + "." + name
+			}
+			if d.opts.ignores(at) {
+				continue
+			}
+			d.walk(path+"."+name, at, exp.Field(i), got.Field(i))
+		}
+	case reflect.Map:
+		if exp.IsNil() != got.IsNil() && !d.opts.bothEmpty(exp, got) {
+			d.add(path, "got %s, want %s", show(got), show(exp))
+			return
+		}
+		for _, k := range mapKeys(exp, got) {
+			key := fmt.Sprintf("%s[%s]", path, show(k))
+			e, g := exp.MapIndex(k), got.MapIndex(k)
+			switch {
+			case !g.IsValid():
+				d.add(key, "missing, want %s", show(e))
+			case !e.IsValid():
+				d.add(key, "got %s, not wanted", show(g))
+			default:
+				d.walk(key, fields, e, g)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if exp.Kind() == reflect.Slice && exp.IsNil() != got.IsNil() && !d.opts.bothEmpty(exp, got) {
+			d.add(path, "got %s, want %s", show(got), show(exp))
+			return
+		}
+		if exp.Type().Elem().Kind() == reflect.Uint8 {
+			d.bytes(path, exp, got)
+			return
+		}
+		n := exp.Len()
+		if got.Len() < n {
+			n = got.Len()
+		}
+		for i := 0; i < n; i++ {
+			d.walk(fmt.Sprintf("%s[%d]", path, i), fields, exp.Index(i), got.Index(i))
+		}
+		if exp.Len() != got.Len() {
+			d.add(path, "got len %d, want len %d", got.Len(), exp.Len())
+		}
+	case reflect.String:
+		if e, g := exp.String(), got.String(); e != g {
+			i := firstDiff(len(e), len(g), func(i int) bool { return e[i] == g[i] })
+			if len(e) <= maxElide && len(g) <= maxElide {
+				d.add(path, "got %s, want %s", show(got), show(exp))
+			} else {
+				d.add(path, "differ at index %d: got %s, want %s", i, elide(g, i), elide(e, i))
+			}
+		}
+	default:
+		if !scalarsEqual(exp, got) {
+			d.add(path, "got %s, want %s", show(got), show(exp))
+		}
+	}
+}
+
+// bytes diffs byte slices or arrays by their first difference.
+func (d *differ) bytes(path string, exp, got reflect.Value) {
+	e, g := make([]byte, exp.Len()), make([]byte, got.Len())
+	reflect.Copy(reflect.ValueOf(e), exp)
+	reflect.Copy(reflect.ValueOf(g), got)
+	if string(e) == string(g) {
+		return
+	}
+	i := firstDiff(len(e), len(g), func(i int) bool { return e[i] == g[i] })
+	if len(e) <= maxElide && len(g) <= maxElide {
+		d.add(path, "got %q, want %q", g, e)
+		return
+	}
+	d.add(path, "differ at index %d of len %d, want len %d: got %s, want %s",
+		i, len(g), len(e), elide(string(g), i), elide(string(e), i))
+}
+
+// firstDiff returns the first index where the values differ.
+func firstDiff(m, n int, same func(i int) bool) int {
+	i := 0
+	for ; i < m && i < n && same(i); i++ {
+	}
+	return i
+}
+
+// elide quotes s around index i.
+func elide(s string, i int) string {
+	start, end := i-elideWidth, i+elideWidth
+	prefix, suffix := "...", "..."
+	if start <= 0 {
+		start, prefix = 0, ""
+	}
+	if end >= len(s) {
+		end, suffix = len(s), ""
+	}
+	return prefix + fmt.Sprintf("%q", s[start:end]) + suffix
+}
+
+// mapKeys returns the keys of both maps, sorted as shown.
+func mapKeys(a, b reflect.Value) []reflect.Value {
+	seen := map[string]bool{}
+	var keys []reflect.Value
+	for _, m := range []reflect.Value{a, b} {
+		for _, k := range m.MapKeys() {
+			if s := show(k); !seen[s] {
+				seen[s] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return show(keys[i]) < show(keys[j]) })
+	return keys
+}
+
+// scalarsEqual compares scalar values, exported or not.
+func scalarsEqual(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() == b.Float()
+	case reflect.Complex64, reflect.Complex128:
+		return a.Complex() == b.Complex()
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return a.Pointer() == b.Pointer()
+	}
+	return false
+}
+
+// show formats v as Equal does, nil for the invalid.
+func show(v reflect.Value) string {
+	if !v.IsValid() {
+		return "nil"
+	}
+	return fmt.Sprintf("%#v", v)
+}
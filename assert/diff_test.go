@@ -0,0 +1,79 @@
+package assert_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils/assert"
+)
+
+type spec struct {
+	Replicas int
+	Labels   map[string]string
+	Ports    []int
+}
+
+type deployment struct {
+	Name string
+	Spec *spec
+}
+
+func TestAssert_EqualDiff(t *testing.T) {
+	long := strings.Repeat("a", 100)
+	cases := []struct {
+		name             string
+		expected, actual interface{}
+		diff             []string // lines expected, nil to be shown whole
+	}{
+		{"struct", deployment{"web", &spec{Replicas: 5}}, deployment{"web", &spec{Replicas: 3}},
+			[]string{".Spec.Replicas: got 3, want 5"}},
+		{"map", map[string]int{"a": 1, "b": 2}, map[string]int{"a": 1, "c": 3},
+			[]string{`["b"]: missing, want 2`, `["c"]: got 3, not wanted`}},
+		{"nested", deployment{Spec: &spec{Labels: map[string]string{"app": "web"}, Ports: []int{80, 443}}},
+			deployment{Spec: &spec{Labels: map[string]string{"app": "api"}, Ports: []int{80}}},
+			[]string{`.Spec.Labels["app"]: got "api", want "web"`, ".Spec.Ports: got len 1, want len 2"}},
+		{"nil pointer", deployment{Spec: &spec{}}, deployment{},
+			[]string{".Spec: got (*assert_test.spec)(nil), want &assert_test.spec{"}},
+		{"long string", long + "b" + long, long + "c" + long,
+			[]string{`differ at index 100: got ..."aaaaaaaaaaaaaaaacaaaaaaaaaaaaaaa"..., want ..."aaaaaaaaaaaaaaaabaaaaaaaaaaaaaaa"...`}},
+		{"nested bytes", struct{ Body []byte }{[]byte(long + "b")}, struct{ Body []byte }{[]byte(long + "bc")},
+			[]string{".Body: differ at index 101 of len 102, want len 101"}},
+		{"short string", "a", "b", nil},
+		{"scalar", 1, 2, nil},
+	}
+	for _, c := range cases {
+		m := &mockT{}
+		NewAssert(m).Equal(c.expected, c.actual, c.name)
+		if len(m.errors) != 1 {
+			t.Fatalf("%s: not failed once: %q", c.name, m.errors)
+		}
+		out := m.errors[0]
+		if c.diff == nil {
+			if strings.Contains(out, "diff:") || !strings.Contains(out, "got: ") {
+				t.Errorf("%s: not shown whole: %q", c.name, out)
+			}
+			continue
+		}
+		if !strings.Contains(out, "diff:") || strings.Contains(out, "got: ") {
+			t.Errorf("%s: not a diff: %q", c.name, out)
+		}
+		for _, line := range c.diff {
+			if !strings.Contains(out, "\t\t"+line) {
+				t.Errorf("%s: %q missing in %q", c.name, line, out)
+			}
+		}
+	}
+}
+
+func TestAssert_EqualDiffTruncated(t *testing.T) {
+	exp, got := make([]int, 50), make([]int, 50)
+	for i := range got {
+		got[i] = i + 1
+	}
+	m := &mockT{}
+	NewAssert(m).Equal(exp, got, "many")
+	if !strings.Contains(m.errors[0], "[19]: got 20, want 0") || strings.Contains(m.errors[0], "[20]:") ||
+		!strings.Contains(m.errors[0], "... and 30 more") {
+		t.Errorf("Diff not truncated: %q", m.errors[0])
+	}
+}
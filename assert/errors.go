@@ -0,0 +1,124 @@
+package assert
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// chain returns the errors err wraps, err itself first, each Unwrap
+// one level deeper; those joined are walked one by one.
+func chain(err error) []string {
+	var lines []string
+	var walk func(err error, depth int)
+	walk = func(err error, depth int) {
+		for ; err != nil; depth++ {
+			lines = append(lines, fmt.Sprintf("%s%T: %v", strings.Repeat("  ", depth), err, err))
+			switch u := err.(type) {
+			case interface{ Unwrap() error }:
+				err = u.Unwrap()
+			case interface{ Unwrap() []error }:
+				for _, e := range u.Unwrap() {
+					walk(e, depth+1)
+				}
+				return
+			default:
+				return
+			}
+		}
+	}
+	walk(err, 0)
+	return lines
+}
+
+// innermost returns the error at the end of the chain of err,
+// or nil if it has any joined.
+func innermost(err error) error {
+	for {
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			if next := u.Unwrap(); next != nil {
+				err = next
+				continue
+			}
+		case interface{ Unwrap() []error }:
+			return nil
+		}
+		return err
+	}
+}
+
+// showError shows err by its %+v text, then its chain and
+// the type of the innermost.
+func showError(err error) string {
+	if err == nil {
+		return "<nil>"
+	}
+	lines := append([]string{fmt.Sprintf("%+v", err), "chain:"}, chain(err)...)
+	if in := innermost(err); in != nil {
+		lines = append(lines, fmt.Sprintf("innermost: %T", in))
+	}
+	return strings.Join(lines, "\n\t\t")
+}
+
+// errorChain fails t with the chain of err along with the message.
+func errorChain(t testing.TB, msg string, err error) {
+	t.Helper()
+	report(t, msg, showError(err), "")
+}
+
+func (a *Assert) ErrorIs(err, target error, msg string) bool {
+	a.t.Helper()
+	return a.ErrorIsf(err, target, "%s", msg)
+}
+
+func (a *Assert) ErrorIsf(err, target error, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.ErrorIs", format, args)
+	if !errors.Is(err, target) {
+		errorChain(a.t, fmt.Sprintf("%s: not %v in chain", msg, target), err)
+		return false
+	}
+	return true
+}
+
+func (a *Assert) NotErrorIs(err, target error, msg string) bool {
+	a.t.Helper()
+	return a.NotErrorIsf(err, target, "%s", msg)
+}
+
+func (a *Assert) NotErrorIsf(err, target error, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.NotErrorIs", format, args)
+	if errors.Is(err, target) {
+		errorChain(a.t, fmt.Sprintf("%s: %v in chain", msg, target), err)
+		return false
+	}
+	return true
+}
+
+// ErrorAs asserts errors.As(err, target), which sets target if so;
+// target is a non-nil pointer to an error type or interface.
+func (a *Assert) ErrorAs(err error, target interface{}, msg string) bool {
+	a.t.Helper()
+	return a.ErrorAsf(err, target, "%s", msg)
+}
+
+// ErrorAsf is ErrorAs with a formatted message.
+func (a *Assert) ErrorAsf(err error, target interface{}, format string, args ...interface{}) (ok bool) {
+	a.t.Helper()
+	msg := a.begin("assert.ErrorAs", format, args)
+	defer func() {
+		// by a target errors.As takes not
+		if r := recover(); r != nil {
+			errorSingle(a.t, fmt.Sprintf("%s: %v", msg, r), target)
+			ok = false
+		}
+	}()
+	if !errors.As(err, target) {
+		errorChain(a.t, fmt.Sprintf("%s: no %T in chain", msg, target), err)
+		return false
+	}
+	return true
+}
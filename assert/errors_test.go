@@ -0,0 +1,106 @@
+package assert_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils/assert"
+)
+
+type codeError struct {
+	Code int
+}
+
+func (e *codeError) Error() string {
+	return fmt.Sprintf("code %d", e.Code)
+}
+
+func TestAssert_ErrorIs(t *testing.T) {
+	err := fmt.Errorf("reading: %w", fmt.Errorf("body: %w", io.ErrUnexpectedEOF))
+	m := &mockT{}
+	a := NewAssert(m)
+	a.ErrorIs(err, io.ErrUnexpectedEOF, "in chain")
+	a.NotErrorIs(err, io.EOF, "not in chain")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertions failed: %q", m.errors)
+	}
+
+	a.ErrorIs(err, io.EOF, "is")
+	a.NotErrorIs(err, io.ErrUnexpectedEOF, "not is")
+	a.ErrorIs(nil, io.EOF, "nil")
+	if len(m.errors) != 3 {
+		t.Fatalf("Failing assertions not failed: %q", m.errors)
+	}
+	for _, want := range []string{
+		"is: not EOF in chain",
+		"*fmt.wrapError: reading: body: unexpected EOF",
+		"\t\t  *fmt.wrapError: body: unexpected EOF",
+		"\t\t    *errors.errorString: unexpected EOF",
+	} {
+		if !strings.Contains(m.errors[0], want) {
+			t.Errorf("%q missing in %q", want, m.errors[0])
+		}
+	}
+	if !strings.Contains(m.errors[1], "not is: unexpected EOF in chain") || !strings.Contains(m.errors[2], "<nil>") {
+		t.Errorf("Chains not shown: %q", m.errors[1:])
+	}
+
+	joined := errors.Join(io.EOF, fmt.Errorf("wrapped: %w", os.ErrNotExist))
+	m.errors = nil
+	a.ErrorIs(joined, os.ErrClosed, "joined")
+	if !strings.Contains(m.errors[0], "\t\t    *errors.errorString: file does not exist") {
+		t.Errorf("Joined chain not shown: %q", m.errors[0])
+	}
+}
+
+func TestAssert_ErrorAs(t *testing.T) {
+	err := fmt.Errorf("calling: %w", &codeError{404})
+	m := &mockT{}
+	a := NewAssert(m)
+
+	var target *codeError
+	a.ErrorAs(err, &target, "as code error")
+	if len(m.errors) != 0 || target == nil || target.Code != 404 {
+		t.Errorf("Target not populated: %v %q", target, m.errors)
+	}
+
+	var pathErr *os.PathError
+	a.ErrorAs(err, &pathErr, "as path error")
+	a.ErrorAs(err, target, "bad target")
+	if len(m.errors) != 2 || !strings.Contains(m.errors[0], "no **fs.PathError in chain") ||
+		!strings.Contains(m.errors[1], "bad target: errors: *target must be interface or implement error") {
+		t.Errorf("Failing assertions not failed well: %q", m.errors)
+	}
+}
+
+func TestAssert_NoErrorChain(t *testing.T) {
+	err := fmt.Errorf("handler: %w", fmt.Errorf("store: %w", &codeError{503}))
+	m := &mockT{}
+	a := NewAssert(m)
+	a.NoError(err, "wrapped")
+	a.Nil(err, "nil")
+	a.NoError(errors.Join(io.EOF, err), "joined")
+	if len(m.errors) != 3 {
+		t.Fatalf("Failures not reported: %q", m.errors)
+	}
+	for _, out := range m.errors[:2] {
+		for _, want := range []string{
+			"\t\thandler: store: code 503\n\t\tchain:",
+			"\t\t*fmt.wrapError: handler: store: code 503",
+			"\t\t  *fmt.wrapError: store: code 503",
+			"\t\t    *assert_test.codeError: code 503",
+			"\t\tinnermost: *assert_test.codeError",
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("%q missing in %q", want, out)
+			}
+		}
+	}
+	if out := m.errors[2]; !strings.Contains(out, "\t\t      *assert_test.codeError: code 503") || strings.Contains(out, "innermost") {
+		t.Errorf("Joined chain not shown: %q", out)
+	}
+}
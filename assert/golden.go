@@ -0,0 +1,149 @@
+package assert
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// EnvUpdateGolden, if true by strconv.ParseBool, rewrites the golden
+// files as the -update flag does.
+const EnvUpdateGolden = "UPDATE_GOLDEN"
+
+var (
+	updateOnce sync.Once
+	update     flag.Value // the -update flag, looked up on first use
+)
+
+// updating tells if the golden files are to be rewritten instead of
+// compared with. The -update flag is the test package's own if it
+// defines one, else it is defined on first use, too late for go test
+// to parse but still settable by flag.Set; EnvUpdateGolden works
+// without either.
+func updating() bool {
+	updateOnce.Do(func() {
+		if flag.Lookup("update") == nil {
+			flag.Bool("update", false, "rewrite the golden files of MatchesGolden")
+		}
+		update = flag.Lookup("update").Value
+	})
+	if on, err := strconv.ParseBool(update.String()); err == nil && on {
+		return true
+	}
+	on, _ := strconv.ParseBool(os.Getenv(EnvUpdateGolden))
+	return on
+}
+
+const goldenContext = 3 // lines around the differing region
+
+// goldenDiff renders where got differs from want as one unified hunk,
+// the lines between their common head and tail, at most maxDiffs each.
+func goldenDiff(path string, want, got []byte) []string {
+	w, g := strings.SplitAfter(string(want), "\n"), strings.SplitAfter(string(got), "\n")
+	head := firstDiff(len(w), len(g), func(i int) bool { return w[i] == g[i] })
+	tail := 0
+	for tail < len(w)-head && tail < len(g)-head && w[len(w)-1-tail] == g[len(g)-1-tail] {
+		tail++
+	}
+	from := head - goldenContext
+	if from < 0 {
+		from = 0
+	}
+	toW, toG := len(w)-tail+goldenContext, len(g)-tail+goldenContext
+	if toW > len(w) {
+		toW = len(w)
+	}
+	if toG > len(g) {
+		toG = len(g)
+	}
+
+	lines := []string{"--- " + path, "+++ actual",
+		fmt.Sprintf("@@ -%d,%d +%d,%d @@", from+1, toW-from, from+1, toG-from)}
+	quote := func(prefix string, ls []string) {
+		for i, l := range ls {
+			if i == maxDiffs {
+				lines = append(lines, fmt.Sprintf("%s... and %d more", prefix, len(ls)-i))
+				return
+			}
+			lines = append(lines, prefix+strings.TrimSuffix(l, "\n"))
+		}
+	}
+	quote(" ", w[from:head])
+	quote("-", w[head:len(w)-tail])
+	quote("+", g[head:len(g)-tail])
+	quote(" ", w[len(w)-tail:toW])
+	return lines
+}
+
+// golden compares actual with the golden file at path, normalizing
+// CRLF line endings of both if text, or rewrites it if -update.
+func (a *Assert) golden(actual []byte, path string, text bool, msg message) bool {
+	a.t.Helper()
+	if text {
+		actual = bytes.ReplaceAll(actual, []byte("\r\n"), []byte("\n"))
+	}
+	if updating() {
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(path, actual, 0644)
+		}
+		if err != nil {
+			errorSingle(a.t, fmt.Sprintf("%s: updating golden file", msg), err)
+			return false
+		}
+		return true
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		errorSingle(a.t, fmt.Sprintf("%s: golden file %s missing, run go test -update to create it", msg, path), err)
+		return false
+	}
+	if err != nil {
+		errorSingle(a.t, fmt.Sprintf("%s: reading golden file", msg), err)
+		return false
+	}
+	if text {
+		want = bytes.ReplaceAll(want, []byte("\r\n"), []byte("\n"))
+	}
+	if !bytes.Equal(want, actual) {
+		report(a.t, fmt.Sprintf("%s: differs from %s, run go test -update if intended", msg, path),
+			strings.Join(goldenDiff(path, want, actual), "\n\t\t"), "")
+		return false
+	}
+	return true
+}
+
+// MatchesGolden asserts actual is the content of the golden file at
+// goldenPath, e.g., "testdata/page.golden", which the -update flag
+// of go test (defined by the test package) or UPDATE_GOLDEN=1
+// rewrites instead, with any directories missing.
+func (a *Assert) MatchesGolden(actual []byte, goldenPath string, msg string) bool {
+	a.t.Helper()
+	return a.MatchesGoldenf(actual, goldenPath, "%s", msg)
+}
+
+// MatchesGoldenf is MatchesGolden with a formatted message.
+func (a *Assert) MatchesGoldenf(actual []byte, goldenPath string, format string, args ...interface{}) bool {
+	a.t.Helper()
+	return a.golden(actual, goldenPath, false, a.begin("assert.MatchesGolden", format, args))
+}
+
+// MatchesGoldenText is MatchesGolden for text, normalizing CRLF line
+// endings to LF, as checked out on Windows or sent over HTTP.
+func (a *Assert) MatchesGoldenText(actual []byte, goldenPath string, msg string) bool {
+	a.t.Helper()
+	return a.MatchesGoldenTextf(actual, goldenPath, "%s", msg)
+}
+
+// MatchesGoldenTextf is MatchesGoldenText with a formatted message.
+func (a *Assert) MatchesGoldenTextf(actual []byte, goldenPath string, format string, args ...interface{}) bool {
+	a.t.Helper()
+	return a.golden(actual, goldenPath, true, a.begin("assert.MatchesGoldenText", format, args))
+}
@@ -0,0 +1,81 @@
+package assert_test
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils/assert"
+)
+
+// update is the usual golden-file flag of a test package,
+// which MatchesGolden reuses.
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// updating sets the -update flag, restored when t finishes.
+func updating(t *testing.T) {
+	t.Cleanup(func() { *update = false })
+	*update = true
+}
+
+func TestAssert_MatchesGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "page.golden")
+	page := []byte(strings.Repeat("line\n", 10) + "<title>Home</title>\n" + strings.Repeat("line\n", 10))
+
+	m := &mockT{}
+	a := NewAssert(m)
+	a.MatchesGolden(page, path, "missing")
+	if len(m.errors) != 1 || !strings.Contains(m.errors[0], "missing: golden file "+path+" missing, run go test -update to create it") {
+		t.Fatalf("Missing golden file not told: %q", m.errors)
+	}
+
+	m.errors = nil
+	t.Run("create", func(t *testing.T) {
+		updating(t)
+		if !a.MatchesGolden(page, path, "created") {
+			t.Fatalf("Not created: %q", m.errors)
+		}
+	})
+	b, err := ioutil.ReadFile(path)
+	if err != nil || string(b) != string(page) {
+		t.Fatalf("Not written: %q %v", b, err)
+	}
+	a.MatchesGolden(page, path, "match")
+	a.MatchesGoldenText([]byte(strings.ReplaceAll(string(page), "\n", "\r\n")), path, "CRLF")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertions failed: %q", m.errors)
+	}
+
+	changed := []byte(strings.Replace(string(page), "Home", "About", 1))
+	a.MatchesGolden(changed, path, "mismatch")
+	if len(m.errors) != 1 {
+		t.Fatalf("Mismatch not reported: %q", m.errors)
+	}
+	for _, s := range []string{"mismatch: differs from " + path, "--- " + path, "+++ actual", "@@ -8,7 +8,7 @@",
+		"\t\t line\n\t\t-<title>Home</title>\n\t\t+<title>About</title>\n\t\t line"} {
+		if !strings.Contains(m.errors[0], s) {
+			t.Errorf("%q missing in %q", s, m.errors[0])
+		}
+	}
+
+	m.errors = nil
+	t.Run("update", func(t *testing.T) {
+		updating(t)
+		a.MatchesGolden(changed, path, "updated")
+	})
+	a.MatchesGolden(changed, path, "match after update")
+	if len(m.errors) != 0 {
+		t.Errorf("Not updated: %q", m.errors)
+	}
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv(EnvUpdateGolden, "1")
+		a.MatchesGolden(page, path, "updated by env")
+	})
+	a.MatchesGolden(page, path, "match after env update")
+	if len(m.errors) != 0 {
+		t.Errorf("Not updated by %s: %q", EnvUpdateGolden, m.errors)
+	}
+}
@@ -0,0 +1,89 @@
+package assert
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	hexWidth   = 8 // bytes a row
+	hexContext = 2 // rows shown before and after the first difference
+)
+
+// byteSlices returns expected and actual as bytes if both are
+// byte slices of the same type.
+func byteSlices(expected, actual interface{}) (e, g []byte, ok bool) {
+	ev, gv := reflect.ValueOf(expected), reflect.ValueOf(actual)
+	if !ev.IsValid() || !gv.IsValid() || ev.Type() != gv.Type() ||
+		ev.Kind() != reflect.Slice || ev.Type().Elem().Kind() != reflect.Uint8 {
+		return nil, nil, false
+	}
+	return ev.Bytes(), gv.Bytes(), true
+}
+
+// hexdiff renders got and want side by side as hexdumps, in a window
+// of rows around their first difference, marked by ">" and "^^".
+func hexdiff(want, got []byte) []string {
+	i := firstDiff(len(want), len(got), func(i int) bool { return want[i] == got[i] })
+	var lines []string
+	if len(want) == len(got) {
+		lines = append(lines, fmt.Sprintf("differ at offset %#x of len %d", i, len(got)))
+	} else {
+		lines = append(lines, fmt.Sprintf("differ at offset %#x: got len %d, want len %d", i, len(got), len(want)))
+	}
+	side := len(hexRow(nil, 0)) + 4 // at which want is shown
+	lines = append(lines, fmt.Sprintf("%12s%-*s%s", "", side, "got", "want"))
+
+	n := len(want)
+	if len(got) > n {
+		n = len(got)
+	}
+	row := i / hexWidth
+	first, last := row-hexContext, row+hexContext
+	if first < 0 {
+		first = 0
+	}
+	if rows := (n + hexWidth - 1) / hexWidth; last >= rows {
+		last = rows - 1
+	}
+	if first > 0 {
+		lines = append(lines, "  ...")
+	}
+	for r := first; r <= last; r++ {
+		mark := "  "
+		if r == row {
+			mark = "> "
+		}
+		off := r * hexWidth
+		lines = append(lines, fmt.Sprintf("%s%08x  %s    %s", mark, off, hexRow(got, off), hexRow(want, off)))
+		if r == row {
+			caret := strings.Repeat(" ", 3*(i-off)) + "^^"
+			lines = append(lines, fmt.Sprintf("%12s%-*s%s", "", side, caret, caret))
+		}
+	}
+	if last < (n-1)/hexWidth {
+		lines = append(lines, "  ...")
+	}
+	return lines
+}
+
+// hexRow renders the hexWidth bytes of b from off in hex and text,
+// blank past its end.
+func hexRow(b []byte, off int) string {
+	var hex, text strings.Builder
+	for j := off; j < off+hexWidth; j++ {
+		switch {
+		case j >= len(b):
+			hex.WriteString("   ")
+			text.WriteByte(' ')
+		case b[j] >= 0x20 && b[j] < 0x7f:
+			fmt.Fprintf(&hex, "%02x ", b[j])
+			text.WriteByte(b[j])
+		default:
+			fmt.Fprintf(&hex, "%02x ", b[j])
+			text.WriteByte('.')
+		}
+	}
+	return strings.TrimSuffix(hex.String(), " ") + "  |" + text.String() + "|"
+}
@@ -0,0 +1,44 @@
+package assert_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils/assert"
+)
+
+func TestAssert_EqualBytes(t *testing.T) {
+	frame := bytes.Repeat([]byte("0123456789abcdef"), 4)
+	flipped := append([]byte(nil), frame...)
+	flipped[0x1a] = 0xff
+
+	for _, c := range []struct {
+		name           string
+		expected, got  []byte
+		header, marked string
+	}{
+		{"same length", frame, flipped, "differ at offset 0x1a of len 64", "> 00000018  38 39 ff 62"},
+		{"shorter", frame, frame[:0x21], "differ at offset 0x21: got len 33, want len 64", "> 00000020  30"},
+		{"longer", frame[:3], frame[:5], "differ at offset 0x3: got len 5, want len 3", "> 00000000  30 31 32 33 34"},
+		{"empty", nil, []byte{0}, "differ at offset 0x0: got len 1, want len 0", "> 00000000  00"},
+	} {
+		m := &mockT{}
+		NewAssert(m).Equal(c.expected, c.got, c.name)
+		if len(m.errors) != 1 {
+			t.Fatalf("%s: not failed once: %q", c.name, m.errors)
+		}
+		for _, s := range []string{c.header, c.marked, "^^"} {
+			if !strings.Contains(m.errors[0], s) {
+				t.Errorf("%s: without %q:\n%s", c.name, s, m.errors[0])
+			}
+		}
+	}
+
+	m := &mockT{}
+	NewAssert(m).Equal(frame, flipped, "windowed")
+	if s := m.errors[0]; !strings.Contains(s, "|01234567|") || !strings.Contains(s, "|89.bcdef|") ||
+		strings.Contains(s, "00000000") || strings.Count(s, "...") != 2 {
+		t.Errorf("Not windowed around the difference:\n%s", s)
+	}
+}
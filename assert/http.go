@@ -0,0 +1,175 @@
+package assert
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serve runs handler on a request made by httptest.NewRequest,
+// recording the response.
+func serve(handler http.Handler, method, url string, body io.Reader) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(method, url, body))
+	return rec
+}
+
+// peekBody reads and closes the body of resp, replacing it
+// with the bytes read so it can be read again.
+func peekBody(resp *http.Response) ([]byte, error) {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return nil, nil
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return b, err
+}
+
+// errorResponse fails t with the status, headers and body, truncated,
+// of resp along with the message.
+func errorResponse(t testing.TB, msg string, resp *http.Response) {
+	t.Helper()
+	var h strings.Builder
+	resp.Header.Write(&h)
+	headers := strings.ReplaceAll(strings.TrimSuffix(h.String(), "\r\n"), "\r\n", "\n\t\t")
+	body, err := peekBody(resp)
+	shown := truncate(fmt.Sprintf("%q", body))
+	if err != nil {
+		shown += fmt.Sprintf(" (reading: %v)", err)
+	}
+	report(t, msg, fmt.Sprintf("status: %d %s\n\t\t%s\n\t\tbody: %s",
+		resp.StatusCode, http.StatusText(resp.StatusCode), headers, shown), "")
+}
+
+// HTTPStatus asserts handler responds to the request with wantStatus.
+func (a *Assert) HTTPStatus(handler http.Handler, method, url string, body io.Reader, wantStatus int, msg string) bool {
+	a.t.Helper()
+	return a.HTTPStatusf(handler, method, url, body, wantStatus, "%s", msg)
+}
+
+// HTTPStatusf is HTTPStatus with a formatted message.
+func (a *Assert) HTTPStatusf(handler http.Handler, method, url string, body io.Reader, wantStatus int, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.HTTPStatus", format, args)
+	if rec := serve(handler, method, url, body); rec.Code != wantStatus {
+		errorResponse(a.t, fmt.Sprintf("%s: status %d, want %d", msg, rec.Code, wantStatus), rec.Result())
+		return false
+	}
+	return true
+}
+
+// HTTPBodyContains asserts the body handler responds with
+// to the request contains substr.
+func (a *Assert) HTTPBodyContains(handler http.Handler, method, url string, body io.Reader, substr string, msg string) bool {
+	a.t.Helper()
+	return a.HTTPBodyContainsf(handler, method, url, body, substr, "%s", msg)
+}
+
+// HTTPBodyContainsf is HTTPBodyContains with a formatted message.
+func (a *Assert) HTTPBodyContainsf(handler http.Handler, method, url string, body io.Reader, substr string, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.HTTPBodyContains", format, args)
+	if rec := serve(handler, method, url, body); !strings.Contains(rec.Body.String(), substr) {
+		errorResponse(a.t, fmt.Sprintf("%s: body without %q", msg, substr), rec.Result())
+		return false
+	}
+	return true
+}
+
+// HTTPHeaderEqual asserts the response of handler to the request
+// has the header key of the value, the first if many.
+func (a *Assert) HTTPHeaderEqual(handler http.Handler, method, url string, body io.Reader, key, value string, msg string) bool {
+	a.t.Helper()
+	return a.HTTPHeaderEqualf(handler, method, url, body, key, value, "%s", msg)
+}
+
+// HTTPHeaderEqualf is HTTPHeaderEqual with a formatted message.
+func (a *Assert) HTTPHeaderEqualf(handler http.Handler, method, url string, body io.Reader, key, value string, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.HTTPHeaderEqual", format, args)
+	rec := serve(handler, method, url, body)
+	if got := rec.Result().Header.Get(key); got != value {
+		errorResponse(a.t, fmt.Sprintf("%s: header %s %q, want %q", msg, key, got, value), rec.Result())
+		return false
+	}
+	return true
+}
+
+// errorNilResponse fails t unless resp is non-nil; it returns whether it is.
+func errorNilResponse(t testing.TB, msg message, resp *http.Response) bool {
+	t.Helper()
+	if resp == nil {
+		errorSingle(t, fmt.Sprintf("%s: nil response", msg), resp)
+		return false
+	}
+	return true
+}
+
+// ResponseStatus asserts resp is of the status want.
+func (a *Assert) ResponseStatus(resp *http.Response, want int, msg string) bool {
+	a.t.Helper()
+	return a.ResponseStatusf(resp, want, "%s", msg)
+}
+
+// ResponseStatusf is ResponseStatus with a formatted message.
+func (a *Assert) ResponseStatusf(resp *http.Response, want int, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.ResponseStatus", format, args)
+	if !errorNilResponse(a.t, msg, resp) {
+		return false
+	}
+	if resp.StatusCode != want {
+		errorResponse(a.t, fmt.Sprintf("%s: status %d, want %d", msg, resp.StatusCode, want), resp)
+		return false
+	}
+	return true
+}
+
+// ResponseHeaderEqual asserts resp has the header key of want,
+// the first if many.
+func (a *Assert) ResponseHeaderEqual(resp *http.Response, key, want string, msg string) bool {
+	a.t.Helper()
+	return a.ResponseHeaderEqualf(resp, key, want, "%s", msg)
+}
+
+// ResponseHeaderEqualf is ResponseHeaderEqual with a formatted message.
+func (a *Assert) ResponseHeaderEqualf(resp *http.Response, key, want string, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.ResponseHeaderEqual", format, args)
+	if !errorNilResponse(a.t, msg, resp) {
+		return false
+	}
+	if got := resp.Header.Get(key); got != want {
+		errorResponse(a.t, fmt.Sprintf("%s: header %s %q, want %q", msg, key, got, want), resp)
+		return false
+	}
+	return true
+}
+
+// ResponseJSONEq asserts the body of resp is JSON equal to expectedJSON,
+// as JSONEq compares; the body is left to be read again.
+func (a *Assert) ResponseJSONEq(resp *http.Response, expectedJSON string, msg string) bool {
+	a.t.Helper()
+	return a.ResponseJSONEqf(resp, expectedJSON, "%s", msg)
+}
+
+// ResponseJSONEqf is ResponseJSONEq with a formatted message.
+func (a *Assert) ResponseJSONEqf(resp *http.Response, expectedJSON string, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.ResponseJSONEq", format, args)
+	if !errorNilResponse(a.t, msg, resp) {
+		return false
+	}
+	body, err := peekBody(resp)
+	if err != nil {
+		errorSingle(a.t, fmt.Sprintf("%s: reading body", msg), err)
+		return false
+	}
+	return a.jsonEq([]byte(expectedJSON), body, msg)
+}
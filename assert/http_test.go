@@ -0,0 +1,131 @@
+package assert_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils/assert"
+)
+
+// echo responds with the request body under a 201 for POST,
+// 405 otherwise.
+var echo = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	b, _ := ioutil.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, "echo %s", b)
+})
+
+func TestAssert_HTTPStatus(t *testing.T) {
+	m := &mockT{}
+	a := NewAssert(m)
+	a.HTTPStatus(echo, "POST", "/echo", strings.NewReader("hi"), http.StatusCreated, "created")
+	a.HTTPStatus(echo, "GET", "/echo", nil, http.StatusMethodNotAllowed, "not allowed")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertions failed: %q", m.errors)
+	}
+
+	a.HTTPStatus(echo, "GET", "/echo", nil, http.StatusOK, "get")
+	if len(m.errors) != 1 {
+		t.Fatalf("Failure not reported: %q", m.errors)
+	}
+	for _, s := range []string{"get: status 405, want 200", "405 Method Not Allowed", "Allow: POST", `body: "method not allowed\n"`} {
+		if !strings.Contains(m.errors[0], s) {
+			t.Errorf("Failure without %q: %q", s, m.errors[0])
+		}
+	}
+}
+
+func TestAssert_HTTPBodyContains(t *testing.T) {
+	m := &mockT{}
+	a := NewAssert(m)
+	a.HTTPBodyContains(echo, "POST", "/echo", strings.NewReader("hi"), "echo hi", "echoed")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertion failed: %q", m.errors)
+	}
+
+	a.HTTPBodyContains(echo, "POST", "/echo", strings.NewReader(strings.Repeat("x", 300)), "y", "long")
+	if len(m.errors) != 1 || !strings.Contains(m.errors[0], `long: body without "y"`) ||
+		!strings.Contains(m.errors[0], "more bytes)") || !strings.Contains(m.errors[0], "201 Created") {
+		t.Errorf("Failure not told well: %q", m.errors)
+	}
+}
+
+func TestAssert_HTTPHeaderEqual(t *testing.T) {
+	m := &mockT{}
+	a := NewAssert(m)
+	a.HTTPHeaderEqual(echo, "POST", "/echo", nil, "Content-Type", "text/plain", "typed")
+	a.HTTPHeaderEqual(echo, "GET", "/echo", nil, "allow", "POST", "canonical key")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertions failed: %q", m.errors)
+	}
+
+	a.HTTPHeaderEqual(echo, "POST", "/echo", nil, "Allow", "POST", "missing")
+	if len(m.errors) != 1 || !strings.Contains(m.errors[0], `missing: header Allow "", want "POST"`) ||
+		!strings.Contains(m.errors[0], "Content-Type: text/plain") {
+		t.Errorf("Failure not told well: %q", m.errors)
+	}
+}
+
+func TestAssert_Response(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"id": 7, "tags": ["a", "b"]}`)
+	}))
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	m := &mockT{}
+	a := NewAssert(m)
+	a.ResponseJSONEq(resp, `{"tags":["a","b"],"id":7}`, "JSON")
+	a.ResponseJSONEq(resp, `{"id":7,"tags":["a","b"]}`, "JSON again")
+	a.ResponseStatus(resp, http.StatusAccepted, "status")
+	a.ResponseHeaderEqual(resp, "content-type", "application/json", "header")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertions failed: %q", m.errors)
+	}
+
+	a.ResponseStatus(resp, http.StatusOK, "not ok")
+	a.ResponseHeaderEqual(resp, "ETag", `"v1"`, "no etag")
+	a.ResponseJSONEq(resp, `{"id":8,"tags":["a","b"]}`, "other id")
+	if len(m.errors) != 3 {
+		t.Fatalf("Failures not reported: %q", m.errors)
+	}
+	for i, s := range []string{"not ok: status 202, want 200", `no etag: header ETag "", want "\"v1\""`, `["id"]: got 7, want 8`} {
+		if !strings.Contains(m.errors[i], s) {
+			t.Errorf("Failure %d %q without %q", i, m.errors[i], s)
+		}
+	}
+	if !strings.Contains(m.errors[0], "202 Accepted") || !strings.Contains(m.errors[0], "Content-Type: application/json") ||
+		!strings.Contains(m.errors[0], `body: "{\"id\": 7`) {
+		t.Errorf("Response not shown: %q", m.errors[0])
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil || string(b) != `{"id": 7, "tags": ["a", "b"]}` {
+		t.Errorf("Body not restored: %q %v", b, err)
+	}
+
+	m.errors = nil
+	a.ResponseStatus(nil, http.StatusOK, "nil status")
+	a.ResponseHeaderEqual(nil, "ETag", "", "nil header")
+	a.ResponseJSONEq(nil, `{}`, "nil JSON")
+	if len(m.errors) != 3 || !strings.Contains(m.errors[0], "nil status: nil response") ||
+		!strings.Contains(m.errors[2], "nil JSON: nil response") {
+		t.Errorf("Nil responses not failed: %q", m.errors)
+	}
+}
@@ -0,0 +1,94 @@
+package assert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// parseJSON decodes s with its numbers normalized
+// to their exact values, so that 1.0 is 1 and 1e2 is 100.
+func parseJSON(s []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(s))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, fmt.Errorf("data after the value at offset %d", d.InputOffset())
+	}
+	return normalizeJSON(v), nil
+}
+
+// jsonNumber is a normalized JSON number, shown bare in diffs.
+type jsonNumber string
+
+func (n jsonNumber) GoString() string {
+	return string(n)
+}
+
+func normalizeJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if r, ok := new(big.Rat).SetString(string(v)); ok {
+			return jsonNumber(r.RatString())
+		}
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalizeJSON(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalizeJSON(e)
+		}
+	}
+	return v
+}
+
+func (a *Assert) JSONEq(expected, actual string, msg string) bool {
+	a.t.Helper()
+	return a.JSONEqf(expected, actual, "%s", msg)
+}
+
+func (a *Assert) JSONEqf(expected, actual string, format string, args ...interface{}) bool {
+	a.t.Helper()
+	return a.jsonEq([]byte(expected), []byte(actual), a.begin("assert.JSONEq", format, args))
+}
+
+func (a *Assert) JSONEqBytes(expected, actual []byte, msg string) bool {
+	a.t.Helper()
+	return a.JSONEqBytesf(expected, actual, "%s", msg)
+}
+
+func (a *Assert) JSONEqBytesf(expected, actual []byte, format string, args ...interface{}) bool {
+	a.t.Helper()
+	return a.jsonEq(expected, actual, a.begin("assert.JSONEqBytes", format, args))
+}
+
+func (a *Assert) jsonEq(expected, actual []byte, msg message) bool {
+	a.t.Helper()
+	exp, err := parseJSON(expected)
+	if err != nil {
+		errorSingle(a.t, fmt.Sprintf("%s: expected is invalid JSON: %v", msg, err), truncate(string(expected)))
+		return false
+	}
+	got, err := parseJSON(actual)
+	if err != nil {
+		errorSingle(a.t, fmt.Sprintf("%s: actual is invalid JSON: %v", msg, err), truncate(string(actual)))
+		return false
+	}
+	if !reflect.DeepEqual(exp, got) {
+		lines := diff(exp, got)
+		if len(lines) == 0 {
+			// scalars
+			lines = []string{fmt.Sprintf("got %s, want %s", actual, expected)}
+		}
+		report(a.t, msg.String(), "diff:\n\t\t"+strings.Join(lines, "\n\t\t"), "")
+		return false
+	}
+	return true
+}
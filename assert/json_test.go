@@ -0,0 +1,48 @@
+package assert_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils/assert"
+)
+
+func TestAssert_JSONEq(t *testing.T) {
+	cases := []struct {
+		name             string
+		expected, actual string
+		diff             string // in the failure, none if equal
+	}{
+		{"reordered", `{"a": 1, "b": [true, null]}`, "{\"b\":[true,null],\n\"a\":1}", ""},
+		{"numbers", `[1, 1.0, 1e2, -0, 0.1]`, `[1.00, 1, 100, 0, 1e-1]`, ""},
+		{"big ints", `12345678901234567890`, `12345678901234567891`, "got 12345678901234567891, want 12345678901234567890"},
+		{"value", `{"a": {"b": 1}}`, `{"a": {"b": 2}}`, `["a"]["b"]: got 2, want 1`},
+		{"missing key", `{"a": 1, "b": 2}`, `{"a": 1}`, `["b"]: missing, want 2`},
+		{"array", `[1, 2, 3]`, `[1, 2]`, "got len 2, want len 3"},
+		{"type", `{"a": "1"}`, `{"a": 1}`, `["a"]: got 1 (assert.jsonNumber), want "1" (string)`},
+	}
+	for _, c := range cases {
+		m := &mockT{}
+		a := NewAssert(m)
+		a.JSONEq(c.expected, c.actual, c.name)
+		a.JSONEqBytes([]byte(c.expected), []byte(c.actual), c.name)
+		if c.diff == "" {
+			if len(m.errors) != 0 {
+				t.Errorf("%s: equal failed: %q", c.name, m.errors)
+			}
+			continue
+		}
+		if len(m.errors) != 2 || !strings.Contains(m.errors[0], c.diff) {
+			t.Errorf("%s: %q missing in %q", c.name, c.diff, m.errors)
+		}
+	}
+
+	m := &mockT{}
+	a := NewAssert(m)
+	a.JSONEq(`{"a":`, `{}`, "bad expected")
+	a.JSONEq(`{}`, `{} {}`, "bad actual")
+	if len(m.errors) != 2 || !strings.Contains(m.errors[0], "bad expected: expected is invalid JSON: unexpected EOF") ||
+		!strings.Contains(m.errors[1], "bad actual: actual is invalid JSON: data after the value") {
+		t.Errorf("Invalid JSON not told: %q", m.errors)
+	}
+}
@@ -0,0 +1,146 @@
+package assert
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mapOf returns m as a map, a nil one being empty;
+// it fails t if m is no map.
+func mapOf(t testing.TB, msg message, m interface{}) (v reflect.Value, ok bool) {
+	t.Helper()
+	v = reflect.ValueOf(m)
+	if v.Kind() != reflect.Map {
+		errorTruncated(t, fmt.Sprintf("%s: %T is no map", msg, m), m)
+		return v, false
+	}
+	return v, true
+}
+
+// keyOf returns key as one of the map v; it fails t if it cannot be.
+func keyOf(t testing.TB, msg message, v reflect.Value, key interface{}) (k reflect.Value, ok bool) {
+	t.Helper()
+	typ := v.Type().Key()
+	k = reflect.ValueOf(key)
+	if !k.IsValid() && typ.Kind() == reflect.Interface {
+		return reflect.Zero(typ), true
+	}
+	if !k.IsValid() || !k.Type().AssignableTo(typ) {
+		errorSingle(t, fmt.Sprintf("%s: key %T is no %s", msg, key, typ), key)
+		return k, false
+	}
+	return k, true
+}
+
+// showKeys lists the keys of the map v, sorted and truncated.
+func showKeys(v reflect.Value) string {
+	keys := mapKeys(v, v)
+	shown := make([]string, len(keys))
+	for i, k := range keys {
+		shown[i] = show(k)
+	}
+	return truncate(fmt.Sprintf("keys (%d): [%s]", len(keys), strings.Join(shown, ", ")))
+}
+
+// MapContainsKey asserts the map m has key, a nil map none.
+func (a *Assert) MapContainsKey(m interface{}, key interface{}, msg string) bool {
+	a.t.Helper()
+	return a.MapContainsKeyf(m, key, "%s", msg)
+}
+
+// MapContainsKeyf is MapContainsKey with a formatted message.
+func (a *Assert) MapContainsKeyf(m interface{}, key interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.MapContainsKey", format, args)
+	v, ok := mapOf(a.t, msg, m)
+	if !ok {
+		return false
+	}
+	k, ok := keyOf(a.t, msg, v, key)
+	if !ok {
+		return false
+	}
+	if !v.MapIndex(k).IsValid() {
+		report(a.t, fmt.Sprintf("%s: no key %s", msg, show(k)), showKeys(v), "")
+		return false
+	}
+	return true
+}
+
+// MapContainsEntry asserts the map m has key of value, as Equal does.
+func (a *Assert) MapContainsEntry(m, key, value interface{}, msg string) bool {
+	a.t.Helper()
+	return a.MapContainsEntryf(m, key, value, "%s", msg)
+}
+
+// MapContainsEntryf is MapContainsEntry with a formatted message.
+func (a *Assert) MapContainsEntryf(m, key, value interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.MapContainsEntry", format, args)
+	v, ok := mapOf(a.t, msg, m)
+	if !ok {
+		return false
+	}
+	k, ok := keyOf(a.t, msg, v, key)
+	if !ok {
+		return false
+	}
+	e := v.MapIndex(k)
+	if !e.IsValid() {
+		report(a.t, fmt.Sprintf("%s: no key %s", msg, show(k)), showKeys(v), "")
+		return false
+	}
+	if !ObjectsAreEqual(value, e.Interface()) {
+		errorCompare(a.t, fmt.Sprintf("%s: value of key %s", msg, show(k)), value, e.Interface())
+		return false
+	}
+	return true
+}
+
+// MapKeysEqual asserts the map m has exactly the keys, a slice or
+// array of them in any order.
+func (a *Assert) MapKeysEqual(m interface{}, keys interface{}, msg string) bool {
+	a.t.Helper()
+	return a.MapKeysEqualf(m, keys, "%s", msg)
+}
+
+// MapKeysEqualf is MapKeysEqual with a formatted message.
+func (a *Assert) MapKeysEqualf(m interface{}, keys interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.MapKeysEqual", format, args)
+	v, ok := mapOf(a.t, msg, m)
+	if !ok {
+		return false
+	}
+	ks := reflect.ValueOf(keys)
+	if ks.Kind() != reflect.Slice && ks.Kind() != reflect.Array {
+		errorTruncated(a.t, fmt.Sprintf("%s: keys %T are no slice", msg, keys), keys)
+		return false
+	}
+
+	want := reflect.MakeMapWithSize(reflect.MapOf(v.Type().Key(), reflect.TypeOf(true)), ks.Len())
+	for i := 0; i < ks.Len(); i++ {
+		k, ok := keyOf(a.t, msg, v, ks.Index(i).Interface())
+		if !ok {
+			return false
+		}
+		want.SetMapIndex(k, reflect.ValueOf(true))
+	}
+	var missing, extra []string
+	for _, k := range mapKeys(v, want) {
+		switch {
+		case !v.MapIndex(k).IsValid():
+			missing = append(missing, show(k))
+		case !want.MapIndex(k).IsValid():
+			extra = append(extra, show(k))
+		}
+	}
+	if len(missing) > 0 || len(extra) > 0 {
+		report(a.t, fmt.Sprintf("%s: missing [%s], not wanted [%s]", msg,
+			truncate(strings.Join(missing, ", ")), truncate(strings.Join(extra, ", "))), showKeys(v), "")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package assert_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils/assert"
+)
+
+func TestAssert_MapContains(t *testing.T) {
+	headers := map[string]int{"accept": 1, "host": 2}
+	var none map[string]int
+	for _, c := range []struct {
+		name   string
+		check  func(a *Assert) bool
+		failed string // in the failure, empty if passing
+	}{
+		{"key hit", func(a *Assert) bool { return a.MapContainsKey(headers, "host", "hit") }, ""},
+		{"key miss", func(a *Assert) bool { return a.MapContainsKey(headers, "etag", "miss") },
+			`miss: no key "etag"` + "\n\n\t\t" + `keys (2): ["accept", "host"]`},
+		{"key of nil map", func(a *Assert) bool { return a.MapContainsKey(none, "host", "nil map") },
+			`nil map: no key "host"` + "\n\n\t\tkeys (0): []"},
+		{"key of other type", func(a *Assert) bool { return a.MapContainsKey(headers, 1, "int key") },
+			"int key: key int is no string"},
+		{"nil key", func(a *Assert) bool { return a.MapContainsKey(headers, nil, "nil key") },
+			"nil key: key <nil> is no string"},
+		{"nil interface key", func(a *Assert) bool { return a.MapContainsKey(map[interface{}]int{nil: 1}, nil, "") }, ""},
+		{"no map", func(a *Assert) bool { return a.MapContainsKey([]string{"host"}, "host", "slice") },
+			"slice: []string is no map"},
+		{"entry hit", func(a *Assert) bool { return a.MapContainsEntry(headers, "host", 2, "hit") }, ""},
+		{"entry miss", func(a *Assert) bool { return a.MapContainsEntry(headers, "etag", 2, "miss") },
+			`miss: no key "etag"`},
+		{"wrong value", func(a *Assert) bool { return a.MapContainsEntry(headers, "host", 3, "wrong") },
+			`wrong: value of key "host"` + "\n\n\t\tgot: 2"},
+		{"entry of nil map", func(a *Assert) bool { return a.MapContainsEntry(none, "host", 2, "nil map") },
+			`nil map: no key "host"`},
+	} {
+		m := &mockT{}
+		ok := c.check(NewAssert(m))
+		if c.failed == "" {
+			if !ok || len(m.errors) != 0 {
+				t.Errorf("%s: failed: %q", c.name, m.errors)
+			}
+			continue
+		}
+		if ok || len(m.errors) != 1 || !strings.Contains(m.errors[0], c.failed) {
+			t.Errorf("%s: %q not told: %q", c.name, c.failed, m.errors)
+		}
+	}
+}
+
+func TestAssert_MapKeysEqual(t *testing.T) {
+	headers := map[string]int{"accept": 1, "host": 2}
+	var none map[string]int
+	m := &mockT{}
+	a := NewAssert(m)
+	a.MapKeysEqual(headers, []string{"host", "accept"}, "any order")
+	a.MapKeysEqual(headers, [2]interface{}{"accept", "host"}, "array")
+	a.MapKeysEqual(none, []string{}, "nil map")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertions failed: %q", m.errors)
+	}
+
+	a.MapKeysEqual(headers, []string{"host", "etag"}, "differ")
+	a.MapKeysEqual(none, []string{"host"}, "nil map")
+	a.MapKeysEqual(headers, "host", "no slice")
+	a.MapKeysEqual(headers, []int{1}, "other type")
+	if len(m.errors) != 4 {
+		t.Fatalf("Failures not reported: %q", m.errors)
+	}
+	for i, s := range []string{`differ: missing ["etag"], not wanted ["accept"]`, `nil map: missing ["host"], not wanted []`,
+		"no slice: keys string are no slice", "other type: key int is no string"} {
+		if !strings.Contains(m.errors[i], s) {
+			t.Errorf("Failure %d %q without %q", i, m.errors[i], s)
+		}
+	}
+
+	huge := map[int]bool{}
+	for i := 0; i < 1000; i++ {
+		huge[i] = true
+	}
+	m.errors = nil
+	a.MapContainsKey(huge, -1, "huge")
+	if !strings.Contains(m.errors[0], "keys (1000): [0, 1, 10, 100") || !strings.Contains(m.errors[0], "more bytes)") {
+		t.Errorf("Keys not truncated: %q", m.errors)
+	}
+}
@@ -0,0 +1,96 @@
+package assert
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+// compiled caches the regexps compiled from pattern strings.
+var compiled sync.Map // string to *regexp.Regexp
+
+// regexpOf returns pattern, a *regexp.Regexp or a string compiled once.
+func regexpOf(pattern interface{}) (*regexp.Regexp, error) {
+	switch p := pattern.(type) {
+	case *regexp.Regexp:
+		return p, nil
+	case string:
+		if re, ok := compiled.Load(p); ok {
+			return re.(*regexp.Regexp), nil
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		compiled.Store(p, re)
+		return re, nil
+	}
+	return nil, fmt.Errorf("pattern %T is no string or *regexp.Regexp", pattern)
+}
+
+// textOf returns value as text if it is a string, []byte,
+// fmt.Stringer or error.
+func textOf(value interface{}) (s string, ok bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	case fmt.Stringer:
+		return v.String(), true
+	case error:
+		return v.Error(), true
+	}
+	return "", false
+}
+
+// match fails t unless the match of pattern in value is want;
+// it returns whether it is.
+func match(t testing.TB, msg message, pattern, value interface{}, want bool) bool {
+	t.Helper()
+	re, err := regexpOf(pattern)
+	if err != nil {
+		errorSingle(t, fmt.Sprintf("%s: bad pattern: %v", msg, err), pattern)
+		return false
+	}
+	s, ok := textOf(value)
+	if !ok {
+		errorTruncated(t, fmt.Sprintf("%s: cannot match %T", msg, value), value)
+		return false
+	}
+	if re.MatchString(s) != want {
+		verb := "not matching"
+		if !want {
+			verb = "matching"
+		}
+		report(t, fmt.Sprintf("%s: %s /%s/", msg, verb, re), truncate(fmt.Sprintf("%q", s)), "")
+		return false
+	}
+	return true
+}
+
+// Match asserts value matches pattern, a *regexp.Regexp or a string
+// compiled once; value is a string, []byte, fmt.Stringer or error.
+func (a *Assert) Match(pattern, value interface{}, msg string) bool {
+	a.t.Helper()
+	return a.Matchf(pattern, value, "%s", msg)
+}
+
+// Matchf is Match with a formatted message.
+func (a *Assert) Matchf(pattern, value interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	return match(a.t, a.begin("assert.Match", format, args), pattern, value, true)
+}
+
+// NotMatch asserts value does not match pattern, as of Match.
+func (a *Assert) NotMatch(pattern, value interface{}, msg string) bool {
+	a.t.Helper()
+	return a.NotMatchf(pattern, value, "%s", msg)
+}
+
+// NotMatchf is NotMatch with a formatted message.
+func (a *Assert) NotMatchf(pattern, value interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	return match(a.t, a.begin("assert.NotMatch", format, args), pattern, value, false)
+}
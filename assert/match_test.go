@@ -0,0 +1,43 @@
+package assert_test
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils/assert"
+)
+
+func TestAssert_Match(t *testing.T) {
+	id := regexp.MustCompile(`^[0-9a-f]{8}$`)
+	m := &mockT{}
+	a := NewAssert(m)
+	a.Match(`^GET /things \d+$`, "GET /things 200", "string")
+	a.Match(id, []byte("deadbeef"), "bytes by regexp")
+	a.Match(`^10\.0\.0\.1$`, net.IPv4(10, 0, 0, 1), "stringer")
+	a.Match(`timeout`, errors.New("dial: timeout"), "error")
+	a.NotMatch(id, "DEADBEEF", "not matching")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertions failed: %q", m.errors)
+	}
+
+	a.Match(id, strings.Repeat("x", 300), "long")
+	a.NotMatch(`^GET`, "GET /", "matching")
+	a.Match(`(`, "(", "bad pattern")
+	a.Match(`1`, 1, "no text")
+	a.Match(1, "1", "no pattern")
+	if len(m.errors) != 5 {
+		t.Fatalf("Failures not reported: %q", m.errors)
+	}
+	for i, msg := range []string{"long: not matching /^[0-9a-f]{8}$/", `matching: matching /^GET/`,
+		"bad pattern: bad pattern: error parsing regexp", "no text: cannot match int", "no pattern: bad pattern: pattern int"} {
+		if !strings.Contains(m.errors[i], msg) {
+			t.Errorf("Failure %d %q without %q", i, m.errors[i], msg)
+		}
+	}
+	if !strings.Contains(m.errors[0], "more bytes)") || !strings.Contains(m.errors[1], `"GET /"`) {
+		t.Errorf("Value not shown: %q", m.errors[:2])
+	}
+}
@@ -0,0 +1,70 @@
+package assert
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// typeOf returns the static type T, an interface type if it is one.
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// typedNil reports whether obj of the static type is the trap of
+// a non-nil interface holding a nil, e.g., an error being a
+// (*MyErr)(nil), which compares unequal to nil.
+func typedNil(static reflect.Type, obj interface{}) bool {
+	return static.Kind() == reflect.Interface && obj != nil && IsNil(obj)
+}
+
+// errorNilValue fails t with both the static type T and
+// the dynamic type of obj, along with the message.
+func errorNilValue(t testing.TB, msg string, static reflect.Type, obj interface{}) {
+	t.Helper()
+	types := fmt.Sprintf("static type %s, dynamic type %T", static, obj)
+	if typedNil(static, obj) {
+		types += fmt.Sprintf(": a typed nil, the %s is not nil but holds a nil %T", static, obj)
+	}
+	report(t, msg, types, "")
+}
+
+// NilValue asserts value is nil as Go compares it to nil by its
+// static type T: an interface must hold nothing at all, so unlike Nil,
+// an error being a (*MyErr)(nil) fails, stating both types.
+func NilValue[T any](a *Assert, value T, msg string) bool {
+	a.t.Helper()
+	return NilValuef(a, value, "%s", msg)
+}
+
+// NilValuef is NilValue with a formatted message.
+func NilValuef[T any](a *Assert, value T, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.NilValue", format, args)
+	obj := interface{}(value)
+	if typedNil(typeOf[T](), obj) || !IsNil(obj) {
+		errorNilValue(a.t, fmt.Sprintf("%s: not nil", msg), typeOf[T](), obj)
+		return false
+	}
+	return true
+}
+
+// NotNilValue asserts value of the static type T is not nil,
+// an interface holding a nil, e.g., an error being a (*MyErr)(nil),
+// failing too, stating both types.
+func NotNilValue[T any](a *Assert, value T, msg string) bool {
+	a.t.Helper()
+	return NotNilValuef(a, value, "%s", msg)
+}
+
+// NotNilValuef is NotNilValue with a formatted message.
+func NotNilValuef[T any](a *Assert, value T, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.NotNilValue", format, args)
+	obj := interface{}(value)
+	if IsNil(obj) {
+		errorNilValue(a.t, fmt.Sprintf("%s: nil", msg), typeOf[T](), obj)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package assert_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils/assert"
+)
+
+func TestAssert_NilValue(t *testing.T) {
+	var none *codeError
+	var noErr error
+	var typedErr error = none
+	var typedAny interface{} = none
+	var someErr error = &codeError{500}
+	for _, c := range []struct {
+		name   string
+		check  func(a *Assert) bool
+		failed string // in the failure, empty if passing
+	}{
+		{"nil error", func(a *Assert) bool { return NilValue(a, noErr, "nil") }, ""},
+		{"nil pointer", func(a *Assert) bool { return NilValue(a, none, "nil") }, ""},
+		{"typed nil error", func(a *Assert) bool { return NilValue(a, typedErr, "typed") },
+			"typed: not nil\n\n\t\tstatic type error, dynamic type *assert_test.codeError: " +
+				"a typed nil, the error is not nil but holds a nil *assert_test.codeError"},
+		{"typed nil interface", func(a *Assert) bool { return NilValue(a, typedAny, "typed") },
+			"static type interface {}, dynamic type *assert_test.codeError: a typed nil"},
+		{"error", func(a *Assert) bool { return NilValue(a, someErr, "error") },
+			"error: not nil\n\n\t\tstatic type error, dynamic type *assert_test.codeError"},
+		{"int", func(a *Assert) bool { return NilValue(a, 0, "int") },
+			"static type int, dynamic type int"},
+		{"not nil error", func(a *Assert) bool { return NotNilValue(a, someErr, "error") }, ""},
+		{"not nil nil error", func(a *Assert) bool { return NotNilValue(a, noErr, "nil") },
+			"nil: nil\n\n\t\tstatic type error, dynamic type <nil>"},
+		{"not nil typed nil error", func(a *Assert) bool { return NotNilValue(a, typedErr, "typed") },
+			"typed: nil\n\n\t\tstatic type error, dynamic type *assert_test.codeError: a typed nil"},
+		{"not nil nil pointer", func(a *Assert) bool { return NotNilValue(a, none, "pointer") },
+			"static type *assert_test.codeError, dynamic type *assert_test.codeError"},
+		{"formatted", func(a *Assert) bool { return NilValuef(a, typedErr, "case %d", 1) },
+			"case 1: not nil"},
+	} {
+		m := &mockT{}
+		ok := c.check(NewAssert(m))
+		if c.failed == "" {
+			if !ok || len(m.errors) != 0 {
+				t.Errorf("%s: failed: %q", c.name, m.errors)
+			}
+			continue
+		}
+		if ok || len(m.errors) != 1 || !strings.Contains(m.errors[0], c.failed) {
+			t.Errorf("%s: %q not told: %q", c.name, c.failed, m.errors)
+		}
+	}
+}
+
+func TestAssert_NilTypedNil(t *testing.T) {
+	var none *codeError
+	var err error = none
+	var v interface{} = none
+	m := &mockT{}
+	a := NewAssert(m)
+	a.Nil(err, "error")
+	a.Nil(v, "interface")
+	a.NotNil(err, "error")
+	a.NotNil(v, "interface")
+	if len(m.errors) != 2 || !strings.Contains(m.errors[0], "error") || !strings.Contains(m.errors[1], "interface") {
+		t.Errorf("Nil should take a typed nil as nil: %q", m.errors)
+	}
+}
@@ -0,0 +1,113 @@
+package assert
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// toFloat converts a number of any numeric kind to float64.
+func toFloat(x interface{}) (float64, bool) {
+	v := reflect.ValueOf(x)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// floats converts expected and actual, failing t if either is no number.
+func floats(t testing.TB, msg message, expected, actual interface{}) (e, a float64, ok bool) {
+	t.Helper()
+	if e, ok = toFloat(expected); !ok {
+		errorSingle(t, fmt.Sprintf("%s: expected %T is no number", msg, expected), expected)
+		return
+	}
+	if a, ok = toFloat(actual); !ok {
+		errorSingle(t, fmt.Sprintf("%s: actual %T is no number", msg, actual), actual)
+	}
+	return
+}
+
+// sameSpecial tells, if either of e and a is NaN or infinite,
+// whether they match: both NaN, or the same infinity.
+func sameSpecial(e, a float64) (match, special bool) {
+	switch {
+	case math.IsNaN(e) || math.IsNaN(a):
+		return math.IsNaN(e) && math.IsNaN(a), true
+	case math.IsInf(e, 0) || math.IsInf(a, 0):
+		return e == a, true
+	}
+	return false, false
+}
+
+// InDelta asserts actual is within delta of expected, both of any
+// numeric type. NaN matches only NaN, and an infinity only itself.
+func (a *Assert) InDelta(expected, actual interface{}, delta float64, msg string) bool {
+	a.t.Helper()
+	return a.InDeltaf(expected, actual, delta, "%s", msg)
+}
+
+// InDeltaf is InDelta with a formatted message.
+func (a *Assert) InDeltaf(expected, actual interface{}, delta float64, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.InDelta", format, args)
+	e, g, ok := floats(a.t, msg, expected, actual)
+	if !ok {
+		return false
+	}
+	if match, special := sameSpecial(e, g); special {
+		if !match {
+			errorCompare(a.t, msg.String(), expected, actual)
+			return false
+		}
+		return true
+	}
+	if d := math.Abs(e - g); !(d <= delta) {
+		errorCompare(a.t, fmt.Sprintf("%s: difference %v over delta %v", msg, d, delta), expected, actual)
+		return false
+	}
+	return true
+}
+
+// InEpsilon asserts the relative error of actual to expected,
+// |expected-actual|/|expected|, is within epsilon; expected 0 takes
+// only 0. NaN matches only NaN, and an infinity only itself.
+func (a *Assert) InEpsilon(expected, actual interface{}, epsilon float64, msg string) bool {
+	a.t.Helper()
+	return a.InEpsilonf(expected, actual, epsilon, "%s", msg)
+}
+
+// InEpsilonf is InEpsilon with a formatted message.
+func (a *Assert) InEpsilonf(expected, actual interface{}, epsilon float64, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.InEpsilon", format, args)
+	e, g, ok := floats(a.t, msg, expected, actual)
+	if !ok {
+		return false
+	}
+	if match, special := sameSpecial(e, g); special {
+		if !match {
+			errorCompare(a.t, msg.String(), expected, actual)
+			return false
+		}
+		return true
+	}
+	if e == 0 {
+		if g != 0 {
+			errorCompare(a.t, msg.String()+": relative error to 0 undefined", expected, actual)
+			return false
+		}
+		return true
+	}
+	if r := math.Abs(e-g) / math.Abs(e); !(r <= epsilon) {
+		errorCompare(a.t, fmt.Sprintf("%s: relative error %v over epsilon %v", msg, r, epsilon), expected, actual)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package assert_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils/assert"
+)
+
+func TestAssert_InDelta(t *testing.T) {
+	nan, inf := math.NaN(), math.Inf(1)
+	cases := []struct {
+		expected, actual interface{}
+		delta            float64
+		ok               bool
+	}{
+		{0.3, 0.1 + 0.2, 1e-9, true},
+		{1.0, 1.1, 0.05, false},
+		{10, int64(11), 1, true},
+		{uint8(10), 12, 1, false},
+		{float32(1.5), 1.5, 0, true},
+		{nan, nan, 1, true},
+		{nan, 1.0, math.MaxFloat64, false},
+		{1.0, nan, math.MaxFloat64, false},
+		{inf, inf, 0, true},
+		{inf, math.Inf(-1), inf, false},
+		{inf, math.MaxFloat64, inf, false},
+		{1.0, 2.0, nan, false},
+	}
+	for _, c := range cases {
+		m := &mockT{}
+		ok := NewAssert(m).InDelta(c.expected, c.actual, c.delta, "in delta")
+		if ok != c.ok || (len(m.errors) == 0) != c.ok {
+			t.Errorf("InDelta(%v, %v, %v) is %v: %q", c.expected, c.actual, c.delta, !c.ok, m.errors)
+		}
+	}
+
+	m := &mockT{}
+	a := NewAssert(m)
+	a.InDelta(1.0, 1.5, 0.1, "far")
+	a.InDelta("1", 1, 0.1, "string")
+	if len(m.errors) != 2 || !strings.Contains(m.errors[0], "difference 0.5 over delta 0.1") ||
+		!strings.Contains(m.errors[1], "expected string is no number") {
+		t.Errorf("Failures not told well: %q", m.errors)
+	}
+}
+
+func TestAssert_InEpsilon(t *testing.T) {
+	nan, inf := math.NaN(), math.Inf(1)
+	cases := []struct {
+		expected, actual interface{}
+		epsilon          float64
+		ok               bool
+	}{
+		{100, 101, 0.01, true},
+		{100, 102, 0.01, false},
+		{-100.0, -101, 0.01, true},
+		{0, 0.0, 0, true},
+		{0, 1e-300, 1, false},
+		{nan, nan, 0, true},
+		{nan, 1, 1, false},
+		{inf, inf, 0, true},
+		{-inf, 1, 1, false},
+	}
+	for _, c := range cases {
+		m := &mockT{}
+		ok := NewAssert(m).InEpsilon(c.expected, c.actual, c.epsilon, "in epsilon")
+		if ok != c.ok || (len(m.errors) == 0) != c.ok {
+			t.Errorf("InEpsilon(%v, %v, %v) is %v: %q", c.expected, c.actual, c.epsilon, !c.ok, m.errors)
+		}
+	}
+
+	m := &mockT{}
+	NewAssert(m).InEpsilon(10, 12, 0.1, "far")
+	if !strings.Contains(m.errors[0], "relative error 0.2 over epsilon 0.1") {
+		t.Errorf("Relative error not told: %q", m.errors)
+	}
+}
@@ -0,0 +1,257 @@
+package assert
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// compare orders x and y: -1, 0 or 1 if less, equal or greater; ok is
+// false if incomparable. They may be numbers of any kinds, strings, or
+// of a type with a Before method, e.g., time.Time.
+func compare(x, y interface{}) (order int, ok bool) {
+	vx, vy := reflect.ValueOf(x), reflect.ValueOf(y)
+	if !vx.IsValid() || !vy.IsValid() {
+		return 0, false
+	}
+	kx, ky := numberKind(vx), numberKind(vy)
+	switch {
+	case kx == ky && kx == signed:
+		return order3(vx.Int() < vy.Int(), vx.Int() > vy.Int()), true
+	case kx == ky && kx == unsigned:
+		return order3(vx.Uint() < vy.Uint(), vx.Uint() > vy.Uint()), true
+	case kx == signed && ky == unsigned:
+		if vx.Int() < 0 {
+			return -1, true
+		}
+		return order3(uint64(vx.Int()) < vy.Uint(), uint64(vx.Int()) > vy.Uint()), true
+	case kx == unsigned && ky == signed:
+		if vy.Int() < 0 {
+			return 1, true
+		}
+		return order3(vx.Uint() < uint64(vy.Int()), vx.Uint() > uint64(vy.Int())), true
+	case kx != none && ky != none:
+		fx, _ := toFloat(x)
+		fy, _ := toFloat(y)
+		if math.IsNaN(fx) || math.IsNaN(fy) {
+			return 0, false
+		}
+		return order3(fx < fy, fx > fy), true
+	}
+
+	if vx.Type() != vy.Type() {
+		return 0, false
+	}
+	if vx.Kind() == reflect.String {
+		return order3(vx.String() < vy.String(), vx.String() > vy.String()), true
+	}
+	if before := vx.MethodByName("Before"); before.IsValid() {
+		t := before.Type()
+		if t.NumIn() == 1 && t.In(0) == vx.Type() && t.NumOut() == 1 && t.Out(0).Kind() == reflect.Bool {
+			less := before.Call([]reflect.Value{vy})[0].Bool()
+			greater := vy.MethodByName("Before").Call([]reflect.Value{vx})[0].Bool()
+			return order3(less, greater), true
+		}
+	}
+	return 0, false
+}
+
+const (
+	none = iota
+	signed
+	unsigned
+	float
+)
+
+func numberKind(v reflect.Value) int {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return signed
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return unsigned
+	case reflect.Float32, reflect.Float64:
+		return float
+	}
+	return none
+}
+
+func order3(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+// assertOrder fails t unless x op y holds, as told by their order;
+// it returns whether it does.
+func assertOrder(t testing.TB, msg message, op string, x, y interface{}, holds func(order int) bool) bool {
+	t.Helper()
+	order, ok := compare(x, y)
+	if !ok {
+		report(t, fmt.Sprintf("%s: cannot compare %T and %T", msg, x, y), fmt.Sprintf("%v %s %v", x, op, y), "")
+		return false
+	}
+	if !holds(order) {
+		report(t, msg.String(), fmt.Sprintf("%v %s %v failed", x, op, y), "")
+		return false
+	}
+	return true
+}
+
+// Greater asserts x > y.
+func (a *Assert) Greater(x, y interface{}, msg string) bool {
+	a.t.Helper()
+	return a.Greaterf(x, y, "%s", msg)
+}
+
+// Greaterf is Greater with a formatted message.
+func (a *Assert) Greaterf(x, y interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.Greater", format, args)
+	return assertOrder(a.t, msg, ">", x, y, func(o int) bool { return o > 0 })
+}
+
+// GreaterOrEqual asserts x >= y.
+func (a *Assert) GreaterOrEqual(x, y interface{}, msg string) bool {
+	a.t.Helper()
+	return a.GreaterOrEqualf(x, y, "%s", msg)
+}
+
+// GreaterOrEqualf is GreaterOrEqual with a formatted message.
+func (a *Assert) GreaterOrEqualf(x, y interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.GreaterOrEqual", format, args)
+	return assertOrder(a.t, msg, ">=", x, y, func(o int) bool { return o >= 0 })
+}
+
+// Less asserts x < y.
+func (a *Assert) Less(x, y interface{}, msg string) bool {
+	a.t.Helper()
+	return a.Lessf(x, y, "%s", msg)
+}
+
+// Lessf is Less with a formatted message.
+func (a *Assert) Lessf(x, y interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.Less", format, args)
+	return assertOrder(a.t, msg, "<", x, y, func(o int) bool { return o < 0 })
+}
+
+// LessOrEqual asserts x <= y.
+func (a *Assert) LessOrEqual(x, y interface{}, msg string) bool {
+	a.t.Helper()
+	return a.LessOrEqualf(x, y, "%s", msg)
+}
+
+// LessOrEqualf is LessOrEqual with a formatted message.
+func (a *Assert) LessOrEqualf(x, y interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.LessOrEqual", format, args)
+	return assertOrder(a.t, msg, "<=", x, y, func(o int) bool { return o <= 0 })
+}
+
+// sliceOf returns slice as a reflect.Value; it fails t if it is
+// no slice or array.
+func sliceOf(t testing.TB, msg message, slice interface{}) (v reflect.Value, ok bool) {
+	t.Helper()
+	v = reflect.ValueOf(slice)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		errorTruncated(t, fmt.Sprintf("%s: %T is no slice", msg, slice), slice)
+		return v, false
+	}
+	return v, true
+}
+
+// errorUnsorted fails t with the neighbors out of order at index i.
+func errorUnsorted(t testing.TB, msg message, op string, i int, x, y interface{}) {
+	t.Helper()
+	report(t, fmt.Sprintf("%s: out of order at index %d", msg, i), fmt.Sprintf("[%d] %v %s [%d] %v failed", i-1, x, op, i, y), "")
+}
+
+// assertSorted fails t unless every element of slice and the next
+// hold x op y, as told by their order; it returns whether they do.
+func assertSorted(t testing.TB, msg message, op string, slice interface{}, holds func(order int) bool) bool {
+	t.Helper()
+	v, ok := sliceOf(t, msg, slice)
+	if !ok {
+		return false
+	}
+	for i := 1; i < v.Len(); i++ {
+		x, y := v.Index(i-1).Interface(), v.Index(i).Interface()
+		order, ok := compare(x, y)
+		if !ok {
+			report(t, fmt.Sprintf("%s: cannot compare %T at index %d", msg, y, i), truncate(fmt.Sprintf("%#v", slice)), "")
+			return false
+		}
+		if !holds(order) {
+			errorUnsorted(t, msg, op, i, x, y)
+			return false
+		}
+	}
+	return true
+}
+
+// IsSorted asserts slice is increasing, each element greater than
+// the previous, as Greater compares them.
+func (a *Assert) IsSorted(slice interface{}, msg string) bool {
+	a.t.Helper()
+	return a.IsSortedf(slice, "%s", msg)
+}
+
+// IsSortedf is IsSorted with a formatted message.
+func (a *Assert) IsSortedf(slice interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	return assertSorted(a.t, a.begin("assert.IsSorted", format, args), "<", slice, func(o int) bool { return o < 0 })
+}
+
+// IsNonDecreasing asserts each element of slice is at least the previous.
+func (a *Assert) IsNonDecreasing(slice interface{}, msg string) bool {
+	a.t.Helper()
+	return a.IsNonDecreasingf(slice, "%s", msg)
+}
+
+// IsNonDecreasingf is IsNonDecreasing with a formatted message.
+func (a *Assert) IsNonDecreasingf(slice interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	return assertSorted(a.t, a.begin("assert.IsNonDecreasing", format, args), "<=", slice, func(o int) bool { return o <= 0 })
+}
+
+// IsDecreasing asserts each element of slice is less than the previous.
+func (a *Assert) IsDecreasing(slice interface{}, msg string) bool {
+	a.t.Helper()
+	return a.IsDecreasingf(slice, "%s", msg)
+}
+
+// IsDecreasingf is IsDecreasing with a formatted message.
+func (a *Assert) IsDecreasingf(slice interface{}, format string, args ...interface{}) bool {
+	a.t.Helper()
+	return assertSorted(a.t, a.begin("assert.IsDecreasing", format, args), ">", slice, func(o int) bool { return o > 0 })
+}
+
+// IsSortedFunc asserts slice is increasing by less, as of
+// sort.Slice, less(i-1, i) for every index i after the first.
+func (a *Assert) IsSortedFunc(slice interface{}, less func(i, j int) bool, msg string) bool {
+	a.t.Helper()
+	return a.IsSortedFuncf(slice, less, "%s", msg)
+}
+
+// IsSortedFuncf is IsSortedFunc with a formatted message.
+func (a *Assert) IsSortedFuncf(slice interface{}, less func(i, j int) bool, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.IsSortedFunc", format, args)
+	v, ok := sliceOf(a.t, msg, slice)
+	if !ok {
+		return false
+	}
+	for i := 1; i < v.Len(); i++ {
+		if !less(i-1, i) {
+			errorUnsorted(a.t, msg, "<", i, v.Index(i-1).Interface(), v.Index(i).Interface())
+			return false
+		}
+	}
+	return true
+}
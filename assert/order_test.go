@@ -0,0 +1,137 @@
+package assert_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils/assert"
+)
+
+// version orders by a Before method.
+type version struct {
+	major, minor int
+}
+
+func (v version) Before(u version) bool {
+	return v.major < u.major || v.major == u.major && v.minor < u.minor
+}
+
+func TestAssert_Order(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name         string
+		small, large interface{}
+	}{
+		{"ints", 1, 2},
+		{"mixed ints", int8(-1), int64(1)},
+		{"uints", uint(1), uint64(2)},
+		{"signed below unsigned", -1, uint(0)},
+		{"unsigned over signed", uint64(math.MaxUint64), int64(math.MaxInt64)},
+		{"floats", 1.5, float32(2.5)},
+		{"int and float", 1, 1.5},
+		{"strings", "abc", "abd"},
+		{"times", now, now.Add(time.Millisecond)},
+		{"before", version{1, 9}, version{2, 0}},
+	}
+	for _, c := range cases {
+		if c.name == "unsigned over signed" {
+			c.small, c.large = c.large, c.small
+		}
+		m := &mockT{}
+		a := NewAssert(m)
+		a.Less(c.small, c.large, c.name)
+		a.LessOrEqual(c.small, c.large, c.name)
+		a.LessOrEqual(c.small, c.small, c.name)
+		a.Greater(c.large, c.small, c.name)
+		a.GreaterOrEqual(c.large, c.small, c.name)
+		a.GreaterOrEqual(c.large, c.large, c.name)
+		if len(m.errors) != 0 {
+			t.Errorf("%s: passing failed: %q", c.name, m.errors)
+		}
+
+		a.Less(c.large, c.small, c.name)
+		a.Less(c.small, c.small, c.name)
+		a.LessOrEqual(c.large, c.small, c.name)
+		a.Greater(c.small, c.large, c.name)
+		a.Greater(c.large, c.large, c.name)
+		a.GreaterOrEqual(c.small, c.large, c.name)
+		if len(m.errors) != 6 {
+			t.Errorf("%s: failing passed: %q", c.name, m.errors)
+		}
+	}
+
+	m := &mockT{}
+	a := NewAssert(m)
+	a.Less(100*time.Millisecond, 50*time.Millisecond, "latency")
+	a.Greater("1", 0, "string and int")
+	a.Less(math.NaN(), 1, "NaN")
+	a.Less(struct{}{}, struct{}{}, "structs")
+	a.Greater(nil, 1, "nil")
+	if len(m.errors) != 5 || !strings.Contains(m.errors[0], "100ms < 50ms failed") ||
+		!strings.Contains(m.errors[1], "cannot compare string and int") {
+		t.Errorf("Failures not told well: %q", m.errors)
+	}
+	for _, e := range m.errors[2:] {
+		if !strings.Contains(e, "cannot compare") {
+			t.Errorf("Incomparable not told: %q", e)
+		}
+	}
+}
+
+func TestAssert_IsSorted(t *testing.T) {
+	now := time.Now()
+	m := &mockT{}
+	a := NewAssert(m)
+	a.IsSorted([]int{1, 2, 3}, "ints")
+	a.IsSorted([]time.Duration{100, 200, 400}, "durations")
+	a.IsSorted([3]float64{-1, 0.5, math.Inf(1)}, "floats array")
+	a.IsSorted([]string{"a", "b", "c"}, "strings")
+	a.IsSorted([]time.Time{now, now.Add(time.Second)}, "times")
+	a.IsSorted([]interface{}{1, uint(2), 2.5}, "mixed numbers")
+	a.IsSorted([]int{}, "empty")
+	a.IsNonDecreasing([]int{1, 1, 2}, "ties")
+	a.IsNonDecreasing([]time.Time{now, now}, "same times")
+	a.IsDecreasing([]string{"c", "b", "a"}, "decreasing")
+	a.IsDecreasing([]version{{2, 0}, {1, 3}, {1, 2}}, "by Before")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertions failed: %q", m.errors)
+	}
+
+	a.IsSorted([]int{1, 2, 2, 3}, "tie")
+	a.IsNonDecreasing([]time.Duration{100, 400, 200}, "backoff")
+	a.IsDecreasing([]time.Time{now, now.Add(time.Second)}, "later")
+	a.IsSorted([]interface{}{1, "2"}, "mixed")
+	a.IsSorted(1, "no slice")
+	if len(m.errors) != 5 {
+		t.Fatalf("Failures not reported: %q", m.errors)
+	}
+	for i, s := range []string{"tie: out of order at index 2\n\n\t\t[1] 2 < [2] 2 failed",
+		"backoff: out of order at index 2\n\n\t\t[1] 400ns <= [2] 200ns failed",
+		"later: out of order at index 1", "mixed: cannot compare string at index 1", "no slice: int is no slice"} {
+		if !strings.Contains(m.errors[i], s) {
+			t.Errorf("Failure %d %q without %q", i, m.errors[i], s)
+		}
+	}
+}
+
+func TestAssert_IsSortedFunc(t *testing.T) {
+	type page struct {
+		N    int
+		Name string
+	}
+	pages := []page{{1, "a"}, {2, "b"}, {4, "c"}}
+	m := &mockT{}
+	a := NewAssert(m)
+	a.IsSortedFunc(pages, func(i, j int) bool { return pages[i].N < pages[j].N }, "by number")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertion failed: %q", m.errors)
+	}
+
+	pages[2].N = 2
+	if a.IsSortedFunc(pages, func(i, j int) bool { return pages[i].N < pages[j].N }, "duplicate") ||
+		len(m.errors) != 1 || !strings.Contains(m.errors[0], "duplicate: out of order at index 2\n\n\t\t[1] {2 b} < [2] {2 c} failed") {
+		t.Errorf("Failure not told well: %q", m.errors)
+	}
+}
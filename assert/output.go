@@ -0,0 +1,83 @@
+package assert
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+// ColorMode tells whether failures are colored with ANSI escapes.
+type ColorMode int
+
+const (
+	// ColorAuto colors failures unless NO_COLOR is set
+	// or they are not written to a terminal.
+	ColorAuto ColorMode = iota
+	ColorOn
+	ColorOff
+)
+
+// Output and Color configure how every assertion reports a failure,
+// set before the tests run, e.g., in TestMain.
+var (
+	// Output is where failures are written, t failed without a
+	// message; failures go through t.Errorf if it is nil.
+	Output io.Writer
+	Color  = ColorAuto
+)
+
+const (
+	red   = "\033[31m"
+	green = "\033[32m"
+	reset = "\033[39m"
+)
+
+// colored tells if failures written to w are to be colored.
+func colored(w io.Writer) bool {
+	switch Color {
+	case ColorOn:
+		return true
+	case ColorOff:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// report fails t with the message and the details below it, then
+// what was expected if any; every failure is formatted here.
+func report(t testing.TB, msg, details, expected string) {
+	t.Helper()
+	if c := collectorOf(t); c != nil {
+		c.fail(msg)
+	}
+	w := Output
+	if w == nil {
+		// what go test prints the test log to
+		w = os.Stdout
+	}
+	open, exp, close := "", "", ""
+	if colored(w) {
+		open, exp, close = red, green, reset
+	}
+	s := open + msg + "\n\n\t\t" + details
+	if expected != "" {
+		s += "\n" + exp + "\t\texp: " + expected
+	}
+	s += close
+
+	if Output == nil {
+		t.Errorf("%s", s)
+		return
+	}
+	fmt.Fprintln(Output, s)
+	t.Fail()
+}
@@ -0,0 +1,65 @@
+package assert_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils/assert"
+)
+
+// configure sets Output and Color, restored when t finishes.
+func configure(t *testing.T, w io.Writer, c ColorMode) {
+	output, color := Output, Color
+	t.Cleanup(func() { Output, Color = output, color })
+	Output, Color = w, c
+}
+
+func TestOutput(t *testing.T) {
+	var buf bytes.Buffer
+	configure(t, &buf, ColorOff)
+	m := &mockT{}
+	a := NewAssert(m)
+	a.Equal(1, 2, "to output")
+	if !m.failed || len(m.errors) != 0 {
+		t.Errorf("Not failed without a message: %v %q", m.failed, m.errors)
+	}
+	if s := buf.String(); !strings.Contains(s, "to output") || !strings.Contains(s, "got: 2") ||
+		!strings.Contains(s, "exp: 1") {
+		t.Errorf("Failure not written: %q", s)
+	}
+}
+
+func TestColor(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		mode    ColorMode
+		noColor string
+		escaped bool
+	}{
+		{"on", ColorOn, "", true},
+		{"on despite NO_COLOR", ColorOn, "1", true},
+		{"off", ColorOff, "", false},
+		{"auto not terminal", ColorAuto, "", false},
+		{"auto NO_COLOR", ColorAuto, "1", false},
+	} {
+		var buf bytes.Buffer
+		configure(t, &buf, c.mode)
+		t.Setenv("NO_COLOR", c.noColor)
+		a := NewAssert(&mockT{})
+		a.Equal(1, 2, "compared")
+		a.True(false, "single")
+		if s := buf.String(); strings.Contains(s, "\033[") != c.escaped {
+			t.Errorf("%s: escapes %v: %q", c.name, !c.escaped, s)
+		}
+	}
+
+	configure(t, nil, ColorOn)
+	m := &mockT{}
+	NewAssert(m).Equal(1, 2, "through t")
+	if len(m.errors) != 1 || !strings.HasPrefix(m.errors[0], "\033[31mthrough t") ||
+		!strings.Contains(m.errors[0], "\033[32m\t\texp: 1\033[39m") {
+		t.Errorf("Not colored through t: %q", m.errors)
+	}
+}
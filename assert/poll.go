@@ -0,0 +1,77 @@
+package assert
+
+import (
+	"fmt"
+	"time"
+)
+
+// budget returns waitFor cut down to end a bit before the deadline
+// of the test, if it tells one.
+func (a *Assert) budget(waitFor time.Duration) time.Duration {
+	if d, ok := a.t.(interface{ Deadline() (time.Time, bool) }); ok {
+		if deadline, ok := d.Deadline(); ok {
+			// leave some for the failure to be reported
+			if left := time.Until(deadline) * 9 / 10; left < waitFor {
+				return left
+			}
+		}
+	}
+	return waitFor
+}
+
+// poll calls cond at once then every tick until it returns want
+// or waitFor passes, telling if it did and how long it took.
+func poll(cond func() bool, want bool, waitFor, tick time.Duration) (bool, time.Duration) {
+	start := time.Now()
+	timer := time.NewTimer(waitFor)
+	defer timer.Stop()
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		if cond() == want {
+			return true, time.Since(start)
+		}
+		select {
+		case <-timer.C:
+			return false, time.Since(start)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Eventually asserts cond returns true within waitFor,
+// called every tick; it returns once it does.
+func (a *Assert) Eventually(cond func() bool, waitFor, tick time.Duration, msg string) bool {
+	a.t.Helper()
+	return a.Eventuallyf(cond, waitFor, tick, "%s", msg)
+}
+
+// Eventuallyf is Eventually with a formatted message.
+func (a *Assert) Eventuallyf(cond func() bool, waitFor, tick time.Duration, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.Eventually", format, args)
+	waitFor = a.budget(waitFor)
+	if ok, waited := poll(cond, true, waitFor, tick); !ok {
+		errorSingle(a.t, fmt.Sprintf("%s: not true within %v, waited %v", msg, waitFor, waited.Round(time.Millisecond)), false)
+		return false
+	}
+	return true
+}
+
+// Never asserts cond keeps returning false for waitFor,
+// called every tick; it fails once it returns true.
+func (a *Assert) Never(cond func() bool, waitFor, tick time.Duration, msg string) bool {
+	a.t.Helper()
+	return a.Neverf(cond, waitFor, tick, "%s", msg)
+}
+
+// Neverf is Never with a formatted message.
+func (a *Assert) Neverf(cond func() bool, waitFor, tick time.Duration, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.Never", format, args)
+	if ok, waited := poll(cond, true, a.budget(waitFor), tick); ok {
+		errorSingle(a.t, fmt.Sprintf("%s: true after waiting %v", msg, waited.Round(time.Millisecond)), true)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package assert_test
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils/assert"
+)
+
+// flipper returns a condition flipped to true after d by a goroutine.
+func flipper(d time.Duration) func() bool {
+	var flipped int32
+	go func() {
+		time.Sleep(d)
+		atomic.StoreInt32(&flipped, 1)
+	}()
+	return func() bool {
+		return atomic.LoadInt32(&flipped) == 1
+	}
+}
+
+func TestAssert_Eventually(t *testing.T) {
+	m := &mockT{}
+	a := NewAssert(m)
+
+	start := time.Now()
+	a.Eventually(flipper(20*time.Millisecond), time.Second, time.Millisecond, "flipped")
+	if len(m.errors) != 0 || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Not returned once true: %v %q", time.Since(start), m.errors)
+	}
+
+	start = time.Now()
+	a.Eventually(flipper(time.Second), 30*time.Millisecond, time.Millisecond, "too late")
+	if len(m.errors) != 1 || !strings.Contains(m.errors[0], "too late: not true within 30ms, waited ") {
+		t.Errorf("Timeout not failed: %q", m.errors)
+	}
+	if waited := time.Since(start); waited < 30*time.Millisecond || waited > 500*time.Millisecond {
+		t.Errorf("Waited %v", waited)
+	}
+}
+
+func TestAssert_Never(t *testing.T) {
+	m := &mockT{}
+	a := NewAssert(m)
+
+	a.Never(flipper(time.Second), 30*time.Millisecond, time.Millisecond, "not flipped")
+	if len(m.errors) != 0 {
+		t.Errorf("Failed while false: %q", m.errors)
+	}
+
+	start := time.Now()
+	a.Never(flipper(20*time.Millisecond), time.Second, time.Millisecond, "flipped")
+	if len(m.errors) != 1 || !strings.Contains(m.errors[0], "flipped: true after waiting") {
+		t.Errorf("Flip not failed: %q", m.errors)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Not failed early: %v", time.Since(start))
+	}
+}
+
+// deadlineT is a mockT of a test near its deadline.
+type deadlineT struct {
+	mockT
+	deadline time.Time
+}
+
+func (d *deadlineT) Deadline() (time.Time, bool) {
+	return d.deadline, true
+}
+
+func TestAssert_EventuallyDeadline(t *testing.T) {
+	m := &deadlineT{deadline: time.Now().Add(50 * time.Millisecond)}
+	start := time.Now()
+	NewAssert(m).Eventually(func() bool { return false }, time.Hour, time.Millisecond, "deadline")
+	if len(m.errors) != 1 || time.Since(start) > time.Second {
+		t.Errorf("Test deadline not respected: %v %q", time.Since(start), m.errors)
+	}
+}
@@ -0,0 +1,66 @@
+package assert
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// errorZeroTime fails t if any of times is zero, as unset; ok is
+// false if it does.
+func errorZeroTime(t testing.TB, msg message, names []string, times ...time.Time) (ok bool) {
+	t.Helper()
+	for i, tm := range times {
+		if tm.IsZero() {
+			errorSingle(t, fmt.Sprintf("%s: %s time is zero, unset?", msg, names[i]), tm)
+			return false
+		}
+	}
+	return true
+}
+
+// WithinDuration asserts actual is within delta of expected,
+// ignoring monotonic clock readings; neither may be zero.
+func (a *Assert) WithinDuration(expected, actual time.Time, delta time.Duration, msg string) bool {
+	a.t.Helper()
+	return a.WithinDurationf(expected, actual, delta, "%s", msg)
+}
+
+// WithinDurationf is WithinDuration with a formatted message.
+func (a *Assert) WithinDurationf(expected, actual time.Time, delta time.Duration, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.WithinDuration", format, args)
+	if !errorZeroTime(a.t, msg, []string{"expected", "actual"}, expected, actual) {
+		return false
+	}
+	d := actual.Sub(expected)
+	if d < -delta || d > delta {
+		errorCompare(a.t, fmt.Sprintf("%s: %v apart, over %v", msg, d, delta), expected, actual)
+		return false
+	}
+	return true
+}
+
+// WithinRange asserts start <= actual <= end; none may be zero.
+func (a *Assert) WithinRange(actual, start, end time.Time, msg string) bool {
+	a.t.Helper()
+	return a.WithinRangef(actual, start, end, "%s", msg)
+}
+
+// WithinRangef is WithinRange with a formatted message.
+func (a *Assert) WithinRangef(actual, start, end time.Time, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.WithinRange", format, args)
+	if !errorZeroTime(a.t, msg, []string{"actual", "start", "end"}, actual, start, end) {
+		return false
+	}
+	switch {
+	case actual.Before(start):
+		errorSingle(a.t, fmt.Sprintf("%s: %v before start %v", msg, start.Sub(actual), start), actual)
+		return false
+	case actual.After(end):
+		errorSingle(a.t, fmt.Sprintf("%s: %v after end %v", msg, actual.Sub(end), end), actual)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package assert_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils/assert"
+)
+
+func TestAssert_WithinDuration(t *testing.T) {
+	now := time.Now()
+	m := &mockT{}
+	a := NewAssert(m)
+	a.WithinDuration(now, now.Round(0), 0, "monotonic stripped")
+	a.WithinDuration(now, now.Add(-time.Millisecond), time.Millisecond, "within delta")
+	a.WithinDuration(now.UTC(), now.Local().Add(time.Millisecond), time.Millisecond, "across zones")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertions failed: %q", m.errors)
+	}
+
+	a.WithinDuration(now, now.Add(2*time.Second), time.Second, "outside delta")
+	a.WithinDuration(now, time.Time{}, time.Hour, "unset")
+	if len(m.errors) != 2 || !strings.Contains(m.errors[0], "outside delta: 2s apart, over 1s") ||
+		!strings.Contains(m.errors[1], "unset: actual time is zero") {
+		t.Errorf("Failures not told well: %q", m.errors)
+	}
+}
+
+func TestAssert_WithinRange(t *testing.T) {
+	start := time.Now()
+	end := start.Add(time.Minute)
+	m := &mockT{}
+	a := NewAssert(m)
+	a.WithinRange(start, start, end, "at start")
+	a.WithinRange(end, start, end, "at end")
+	a.WithinRange(start.Add(time.Second), start, end, "inside")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertions failed: %q", m.errors)
+	}
+
+	a.WithinRange(start.Add(-time.Second), start, end, "early")
+	a.WithinRange(end.Add(time.Second), start, end, "late")
+	a.WithinRange(start, time.Time{}, end, "no start")
+	if len(m.errors) != 3 || !strings.Contains(m.errors[0], "early: 1s before start") ||
+		!strings.Contains(m.errors[1], "late: 1s after end") || !strings.Contains(m.errors[2], "start time is zero") {
+		t.Errorf("Failures not told well: %q", m.errors)
+	}
+}
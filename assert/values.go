@@ -0,0 +1,115 @@
+package assert
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// valuesDiff tells the keys missing from actual, those not expected,
+// and the values of each key differing, in any order, sorted by key.
+func valuesDiff(expected, actual url.Values) []string {
+	keys := make([]string, 0, len(expected)+len(actual))
+	for k := range expected {
+		keys = append(keys, k)
+	}
+	for k := range actual {
+		if _, ok := expected[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var lines []string
+	for _, k := range keys {
+		e, inE := expected[k]
+		g, inG := actual[k]
+		switch {
+		case !inG:
+			lines = append(lines, fmt.Sprintf("%q: missing, want %q", k, e))
+		case !inE:
+			lines = append(lines, fmt.Sprintf("%q: got %q, not wanted", k, g))
+		default:
+			if missing, extra := multisetDiff(e, g); len(missing)+len(extra) > 0 {
+				lines = append(lines, fmt.Sprintf("%q: got %q, want %q: missing %q, not wanted %q", k, g, e, missing, extra))
+			}
+		}
+	}
+	return lines
+}
+
+// multisetDiff returns the values of want not in got, and those of got
+// not in want, as many as they differ by.
+func multisetDiff(want, got []string) (missing, extra []string) {
+	counts := map[string]int{}
+	for _, v := range want {
+		counts[v]++
+	}
+	for _, v := range got {
+		counts[v]--
+	}
+	for _, v := range want {
+		if counts[v] > 0 {
+			missing = append(missing, v)
+			counts[v]--
+		}
+	}
+	for _, v := range got {
+		if counts[v] < 0 {
+			extra = append(extra, v)
+			counts[v]++
+		}
+	}
+	return missing, extra
+}
+
+// ValuesEqual asserts actual has the keys of expected, each with the
+// same values in any order, e.g., of a form or query.
+func (a *Assert) ValuesEqual(expected, actual url.Values, msg string) bool {
+	a.t.Helper()
+	return a.ValuesEqualf(expected, actual, "%s", msg)
+}
+
+// ValuesEqualf is ValuesEqual with a formatted message.
+func (a *Assert) ValuesEqualf(expected, actual url.Values, format string, args ...interface{}) bool {
+	a.t.Helper()
+	return a.valuesEqual(expected, actual, a.begin("assert.ValuesEqual", format, args))
+}
+
+// QueryEqual asserts the query strings, parsed, are ValuesEqual,
+// so encodings of the same, e.g., "a+b" and "a%20b", are equal.
+func (a *Assert) QueryEqual(expected, actual string, msg string) bool {
+	a.t.Helper()
+	return a.QueryEqualf(expected, actual, "%s", msg)
+}
+
+// QueryEqualf is QueryEqual with a formatted message.
+func (a *Assert) QueryEqualf(expected, actual string, format string, args ...interface{}) bool {
+	a.t.Helper()
+	msg := a.begin("assert.QueryEqual", format, args)
+	e, err := url.ParseQuery(expected)
+	if err != nil {
+		errorSingle(a.t, fmt.Sprintf("%s: expected is an invalid query", msg), err)
+		return false
+	}
+	g, err := url.ParseQuery(actual)
+	if err != nil {
+		errorSingle(a.t, fmt.Sprintf("%s: actual is an invalid query", msg), err)
+		return false
+	}
+	return a.valuesEqual(e, g, msg)
+}
+
+func (a *Assert) valuesEqual(expected, actual url.Values, msg message) bool {
+	a.t.Helper()
+	lines := valuesDiff(expected, actual)
+	if len(lines) == 0 {
+		return true
+	}
+	if len(lines) > maxDiffs {
+		lines = append(lines[:maxDiffs], fmt.Sprintf("... and %d more", len(lines)-maxDiffs))
+	}
+	report(a.t, msg.String(), "diff:\n\t\t"+strings.Join(lines, "\n\t\t"), "")
+	return false
+}
@@ -0,0 +1,41 @@
+package assert_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils/assert"
+)
+
+func TestAssert_ValuesEqual(t *testing.T) {
+	form := url.Values{"tag": {"a", "b", "a"}, "q": {"go"}}
+	m := &mockT{}
+	a := NewAssert(m)
+	a.ValuesEqual(form, url.Values{"q": {"go"}, "tag": {"a", "a", "b"}}, "reordered")
+	a.ValuesEqual(nil, url.Values{}, "empty")
+	a.QueryEqual("q=a+b&x=1", "x=1&q=a%20b", "encodings")
+	if len(m.errors) != 0 {
+		t.Errorf("Passing assertions failed: %q", m.errors)
+	}
+
+	a.ValuesEqual(form, url.Values{"tag": {"b", "a", "c"}, "page": {"2"}}, "differ")
+	a.QueryEqual("q=a+b", "q=a%2Bb", "plus encoded")
+	a.QueryEqual("q=%zz", "q=a", "invalid")
+	if len(m.errors) != 3 {
+		t.Fatalf("Failures not reported: %q", m.errors)
+	}
+	for _, s := range []string{
+		"\t\t\"page\": got [\"2\"], not wanted",
+		"\t\t\"q\": missing, want [\"go\"]",
+		"\t\t\"tag\": got [\"b\" \"a\" \"c\"], want [\"a\" \"b\" \"a\"]: missing [\"a\"], not wanted [\"c\"]",
+	} {
+		if !strings.Contains(m.errors[0], s) {
+			t.Errorf("%q missing in %q", s, m.errors[0])
+		}
+	}
+	if !strings.Contains(m.errors[1], `"q": got ["a+b"], want ["a b"]`) ||
+		!strings.Contains(m.errors[2], "invalid: expected is an invalid query") {
+		t.Errorf("Failures not told well: %q", m.errors[1:])
+	}
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseBackoff parses a Backoff and the max tries of a call from
+// "BASE[..MAX] [xTRIES] [STRATEGY]", e.g., "100ms..5s x5":
+// BASE and MAX are whole milliseconds as Go durations, MAX being
+// BASE if left out; maxTries is 0 if not given. STRATEGY is
+// "decorrelated" (the default, the jitter of Backoff.Next) or "const",
+// waiting BASE every time; others, e.g., "full", are not supported.
+// Fields are separated by spaces.
+func ParseBackoff(s string) (b Backoff, maxTries int, err error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return b, 0, fmt.Errorf("utils: backoff %q: empty", s)
+	}
+	errorf := func(format string, args ...interface{}) error {
+		return fmt.Errorf("utils: backoff %q: "+format, append([]interface{}{s}, args...)...)
+	}
+
+	base, max := fields[0], fields[0]
+	if i := strings.Index(fields[0], ".."); i >= 0 {
+		base, max = fields[0][:i], fields[0][i+2:]
+	}
+	if b.BaseSleep, err = parseMillis(base); err != nil {
+		return b, 0, errorf("base: %v", err)
+	}
+	if b.MaxSleep, err = parseMillis(max); err != nil {
+		return b, 0, errorf("max: %v", err)
+	}
+	if b.MaxSleep < b.BaseSleep {
+		return b, 0, errorf("max %s below base %s", max, base)
+	}
+
+	var strategy string
+	for _, f := range fields[1:] {
+		switch {
+		case strings.HasPrefix(f, "x") && maxTries == 0:
+			if maxTries, err = strconv.Atoi(f[1:]); err != nil || maxTries <= 0 {
+				return b, 0, errorf("tries %q not a positive number", f)
+			}
+		case strategy == "" && !strings.HasPrefix(f, "x"):
+			strategy = f
+		default:
+			return b, 0, errorf("unexpected %q", f)
+		}
+	}
+	switch strategy {
+	case "", "decorrelated":
+	case "const":
+		if b.MaxSleep != b.BaseSleep {
+			return b, 0, errorf("const takes no max")
+		}
+	default:
+		return b, 0, errorf("strategy %q not supported", strategy)
+	}
+	return b, maxTries, nil
+}
+
+// parseMillis parses a duration of whole milliseconds, at least one.
+func parseMillis(s string) (int, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < time.Millisecond || d%time.Millisecond != 0 {
+		return 0, fmt.Errorf("%s not a positive whole number of milliseconds", s)
+	}
+	return int(d / time.Millisecond), nil
+}
+
+// String returns b in the canonical form of ParseBackoff, "BASE..MAX".
+func (b Backoff) String() string {
+	return fmt.Sprintf("%v..%v", time.Duration(b.BaseSleep)*time.Millisecond, time.Duration(b.MaxSleep)*time.Millisecond)
+}
@@ -0,0 +1,68 @@
+package utils_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestParseBackoff(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	tests := []struct {
+		s        string
+		b        Backoff
+		maxTries int
+	}{
+		{"100ms..5s", Backoff{100, 5000}, 0},
+		{"100ms..5s x5", Backoff{100, 5000}, 5},
+		{"  100ms..5s \t x5  decorrelated ", Backoff{100, 5000}, 5},
+		{"1s..1m30s decorrelated x3", Backoff{1000, 90000}, 3},
+		{"250ms", Backoff{250, 250}, 0},
+		{"1s const x10", Backoff{1000, 1000}, 10},
+		{"1s..1s const", Backoff{1000, 1000}, 0},
+	}
+	for _, test := range tests {
+		b, maxTries, err := ParseBackoff(test.s)
+		a.NoErrorf(err, "Parsed %s", test.s)
+		a.Equalf(test.b, b, "Backoff of %s", test.s)
+		a.Equalf(test.maxTries, maxTries, "Tries of %s", test.s)
+
+		// the canonical form round-trips
+		again, _, err := ParseBackoff(b.String())
+		a.NoErrorf(err, "Parsed %v", b)
+		a.Equalf(b, again, "Round trip of %s", test.s)
+	}
+
+	malformed := []struct {
+		s, msg string
+	}{
+		{"", "empty"},
+		{"   ", "empty"},
+		{"fast", "base"},
+		{"100..5s", "base"},
+		{"100ms..", "max"},
+		{"1.5ms..5s", "whole number of milliseconds"},
+		{"0s..5s", "whole number of milliseconds"},
+		{"5s..100ms", "below base"},
+		{"100ms..5s x", "tries"},
+		{"100ms..5s x0", "tries"},
+		{"100ms..5s x5 x6", "unexpected"},
+		{"100ms..5s full", `strategy "full" not supported`},
+		{"100ms..5s decorrelated const", "unexpected"},
+		{"100ms..5s const", "const takes no max"},
+	}
+	for _, test := range malformed {
+		_, _, err := ParseBackoff(test.s)
+		a.Truef(err != nil && strings.Contains(err.Error(), test.msg), "Rejected %s", test.s)
+	}
+}
+
+func TestBackoff_String(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	a.Equal("100ms..5s", Backoff{100, 5000}.String(), "Canonical")
+	a.Equal("1s..1m30s", Backoff{1000, 90000}.String(), "Canonical")
+}
@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Defaults of a Batcher made by SafeClient.Batcher.
+const (
+	DefaultBatchSize  = 100
+	DefaultBatchDelay = time.Second
+	DefaultBatchTries = 3
+)
+
+// ErrBatcherClosed is returned adding to or closing a closed Batcher.
+var ErrBatcherClosed = errors.New("utils: batcher closed")
+
+// BatchResult is the outcome of a flush of Items, as tried by Send;
+// Err is an *HTTPError for a final status other than 2xx.
+type BatchResult struct {
+	Items  []interface{}
+	Tries  int
+	Status int
+	Err    error
+}
+
+// Batcher coalesces items into JSON POSTs of its Client to URL:
+// a batch is flushed once it has MaxSize items or its first item
+// has waited MaxDelay, each flush retried up to MaxTries times as usual.
+// Its fields must be set before the first Add; its methods are
+// safe for concurrent use.
+type Batcher struct {
+	Client   *SafeClient
+	URL      string
+	MaxSize  int
+	MaxDelay time.Duration
+	MaxTries int
+	NDJSON   bool        // post a line per item rather than an array
+	Hook     RequestHook // applied to every request, if set
+
+	// OnFlush, if set, is told of every flush, the failed ones
+	// being the only report of the items they carried.
+	OnFlush func(BatchResult)
+
+	once   sync.Once
+	mu     sync.RWMutex
+	closed bool
+	items  chan interface{}
+	stop   chan context.Context
+	done   chan error
+}
+
+// Batcher returns a Batcher posting to url over c with the defaults.
+func (c *SafeClient) Batcher(url string) *Batcher {
+	return &Batcher{
+		Client:   c,
+		URL:      url,
+		MaxSize:  DefaultBatchSize,
+		MaxDelay: DefaultBatchDelay,
+		MaxTries: DefaultBatchTries,
+	}
+}
+
+func (b *Batcher) start() {
+	b.once.Do(func() {
+		b.items = make(chan interface{})
+		b.stop = make(chan context.Context)
+		b.done = make(chan error, 1)
+		go b.loop()
+	})
+}
+
+// Add queues item for the next flush. It blocks while a full batch
+// is being flushed, and returns ctx.Err() if ctx is done first or
+// ErrBatcherClosed; an item is accepted, and is sure to be flushed,
+// only if it returns nil.
+func (b *Batcher) Add(ctx context.Context, item interface{}) error {
+	b.start()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrBatcherClosed
+	}
+	select {
+	case b.items <- item:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting items and flushes those accepted with ctx,
+// after any flush under way; it returns the error of that last flush.
+func (b *Batcher) Close(ctx context.Context) error {
+	b.start()
+	// wait for the Adds under way
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBatcherClosed
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	b.stop <- ctx
+	return <-b.done
+}
+
+func (b *Batcher) loop() {
+	var batch []interface{}
+	var timer *time.Timer
+	var expired <-chan time.Time
+	flush := func(ctx context.Context) error {
+		if timer != nil {
+			timer.Stop()
+			timer, expired = nil, nil
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		items := batch
+		batch = nil
+		return b.flush(ctx, items)
+	}
+
+	for {
+		select {
+		case item := <-b.items:
+			batch = append(batch, item)
+			if len(batch) >= b.MaxSize {
+				flush(context.Background())
+			} else if timer == nil {
+				timer = time.NewTimer(b.MaxDelay)
+				expired = timer.C
+			}
+		case <-expired:
+			flush(context.Background())
+		case ctx := <-b.stop:
+			b.done <- flush(ctx)
+			return
+		}
+	}
+}
+
+// flush posts items and reports the outcome to OnFlush.
+func (b *Batcher) flush(ctx context.Context, items []interface{}) error {
+	res := BatchResult{Items: items}
+	var data []byte
+	data, res.Err = b.encode(items)
+	if res.Err == nil {
+		contentType := "application/json; charset=utf-8"
+		if b.NDJSON {
+			contentType = "application/x-ndjson"
+		}
+		var r Response
+		res.Tries, r, res.Err = b.Client.Send(ctx, "POST", b.URL, data, b.MaxTries, func(req *http.Request) {
+			req.Header.Set("Content-Type", contentType)
+			if b.Hook != nil {
+				b.Hook(req)
+			}
+		})
+		res.Status = r.Status
+		if res.Err == nil && (r.Status < 200 || r.Status > 299) {
+			res.Err = &HTTPError{r.Status, r.Body}
+		}
+	}
+	if b.OnFlush != nil {
+		b.OnFlush(res)
+	}
+	return res.Err
+}
+
+func (b *Batcher) encode(items []interface{}) ([]byte, error) {
+	if !b.NDJSON {
+		return json.Marshal(items)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, item := range items {
+		// Encode ends each with a newline
+		if err := enc.Encode(item); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
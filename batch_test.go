@@ -0,0 +1,171 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+// batchServer records the bodies posted to it.
+type batchServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	bodies []string
+	types  []string
+}
+
+func newBatchServer(status int) *batchServer {
+	s := &batchServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		s.mu.Lock()
+		s.bodies = append(s.bodies, string(data))
+		s.types = append(s.types, r.Header.Get("Content-Type"))
+		s.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	return s
+}
+
+func (s *batchServer) posted() ([]string, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bodies, s.types
+}
+
+func TestBatcher_Size(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := newBatchServer(http.StatusOK)
+	defer server.Close()
+
+	results := make(chan BatchResult, 10)
+	b := StdClient().Batcher(server.URL)
+	b.MaxSize = 3
+	b.MaxDelay = time.Hour
+	b.OnFlush = func(r BatchResult) { results <- r }
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		a.NoError(b.Add(ctx, testContent{string(rune('a' + i))}), "Accepted")
+	}
+	r := <-results
+	bodies, types := server.posted()
+	a.NoError(r.Err, "No error")
+	a.Equal(http.StatusOK, r.Status, "Returns code")
+	a.Equal(3, len(r.Items), "Full batch")
+	a.Equal(`[{"data":"a"},{"data":"b"},{"data":"c"}]`, bodies[0], "Posted as an array")
+	a.Equal("application/json; charset=utf-8", types[0], "Content type")
+
+	a.NoError(b.Close(ctx), "Nothing left")
+	bodies, _ = server.posted()
+	a.Equal(1, len(bodies), "No empty flush")
+}
+
+func TestBatcher_Delay(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := newBatchServer(http.StatusOK)
+	defer server.Close()
+
+	results := make(chan BatchResult, 10)
+	b := StdClient().Batcher(server.URL)
+	b.MaxDelay = 20 * time.Millisecond
+	b.NDJSON = true
+	b.OnFlush = func(r BatchResult) { results <- r }
+
+	ctx := context.Background()
+	start := time.Now()
+	b.Add(ctx, testContent{"a"})
+	b.Add(ctx, testContent{"b"})
+	r := <-results
+	bodies, types := server.posted()
+	a.True(time.Since(start) >= b.MaxDelay, "Flushed after the delay")
+	a.Equal(2, len(r.Items), "Partial batch")
+	a.Equal("{\"data\":\"a\"}\n{\"data\":\"b\"}\n", bodies[0], "Posted a line per item")
+	a.Equal("application/x-ndjson", types[0], "Content type")
+	b.Close(ctx)
+}
+
+func TestBatcher_Close(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := newBatchServer(http.StatusOK)
+	defer server.Close()
+
+	b := StdClient().Batcher(server.URL)
+	b.MaxDelay = time.Hour
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		b.Add(ctx, i)
+	}
+	a.NoError(b.Close(ctx), "Flushed on close")
+	bodies, _ := server.posted()
+	a.Equal([]string{"[0,1,2,3,4]"}, bodies, "Nothing lost")
+
+	a.Equal(ErrBatcherClosed, b.Add(ctx, 5), "Add after close")
+	a.Equal(ErrBatcherClosed, b.Close(ctx), "Close twice")
+}
+
+func TestBatcher_Failure(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := newBatchServer(http.StatusBadRequest)
+	defer server.Close()
+
+	var failed []interface{}
+	b := StdClient().Batcher(server.URL)
+	b.OnFlush = func(r BatchResult) {
+		if r.Err != nil {
+			failed = append(failed, r.Items...)
+		}
+	}
+	ctx := context.Background()
+	b.Add(ctx, "x")
+	err := b.Close(ctx)
+	httpErr, ok := err.(*HTTPError)
+	a.True(ok, "Returns the flush error")
+	a.Equal(http.StatusBadRequest, httpErr.StatusCode, "Returns code")
+	a.Equal([]interface{}{"x"}, failed, "Items reported")
+
+	// the final flush runs with the context of Close
+	b = StdClient().Batcher(server.URL)
+	b.Add(ctx, "y")
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = b.Close(cancelled)
+	a.True(err != nil && strings.Contains(err.Error(), "canceled"), "Cancelled flush")
+
+	// an Add given up is not accepted
+	b = StdClient().Batcher(server.URL)
+	b.MaxSize = 1
+	block := make(chan struct{})
+	b.OnFlush = func(BatchResult) { <-block }
+	b.Add(ctx, "z") // its flush blocks the loop
+	a.Equal(context.Canceled, b.Add(cancelled, "lost"), "Not accepted")
+	close(block)
+	b.Close(ctx)
+}
+
+func TestBatcher_Encode(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := newBatchServer(http.StatusOK)
+	defer server.Close()
+
+	b := StdClient().Batcher(server.URL)
+	b.Add(context.Background(), func() {})
+	_, ok := b.Close(context.Background()).(*json.UnsupportedTypeError)
+	a.True(ok, "Encoding error")
+	bodies, _ := server.posted()
+	a.Equal(0, len(bodies), "Nothing posted")
+}
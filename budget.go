@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrBodyBudgetExceeded is returned once the tries of a call
+// have read more than MaxTotalBodyBytes.
+var ErrBodyBudgetExceeded = errors.New("utils: total body budget exceeded")
+
+// WithMaxTotalBodyBytes caps the response bytes read by all tries
+// of a call of RequestWithRetry, DoRequest and the methods built on
+// them at n: past it the call stops retrying, returning the last
+// response truncated to the budget and ErrBodyBudgetExceeded.
+// Streamed bodies, e.g., of Stream or Download, are not counted.
+func WithMaxTotalBodyBytes(n int64) Option {
+	return func(c *SafeClient) error {
+		c.MaxTotalBodyBytes = n
+		return nil
+	}
+}
+
+// budgetKey keys the *bodyBudget of a call in its request context.
+type budgetKey struct{}
+
+// bodyBudget is the bytes left to read by the tries of a call,
+// which are never concurrent.
+type bodyBudget struct {
+	left int64
+}
+
+// budgetReader reads r out of b.
+type budgetReader struct {
+	r io.Reader
+	b *bodyBudget
+}
+
+func (r *budgetReader) Read(p []byte) (int, error) {
+	if r.b.left <= 0 {
+		// one more byte tells a body of exactly the budget
+		var one [1]byte
+		n, err := r.r.Read(one[:])
+		if n > 0 {
+			return 0, ErrBodyBudgetExceeded
+		}
+		return 0, err
+	}
+	if int64(len(p)) > r.b.left {
+		p = p[:r.b.left]
+	}
+	n, err := r.r.Read(p)
+	r.b.left -= int64(n)
+	return n, err
+}
@@ -0,0 +1,44 @@
+package utils_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestWithMaxTotalBodyBytes(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	page := bytes.Repeat([]byte("x"), 1<<20)
+	var n int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(page)
+	}))
+	defer server.Close()
+
+	cl, err := NewClient(WithMaxTotalBodyBytes(3 << 19))
+	a.NoError(err, "No error")
+	cl.Backoff = testBackoff
+	tries, status, body, err := cl.DoRequest("GET", server.URL, nil, 5, nil)
+	a.Equal(ErrBodyBudgetExceeded, err, "Budget exceeded")
+	a.Equal(int32(2), atomic.LoadInt32(&n), "Two attempts")
+	a.Equal(1, tries, "Stopped retrying")
+	a.Equal(http.StatusInternalServerError, status, "Last status")
+	a.Equal(1<<19, len(body), "Last body truncated")
+
+	// every call has its own budget, exactly met by a body
+	cl.MaxTotalBodyBytes = 1 << 20
+	atomic.StoreInt32(&n, 0)
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	tries, _, body, err = cl.RequestWithRetry(req, 1)
+	a.NoError(err, "Within budget")
+	a.Equal(0, tries, "Tried once")
+	a.Equal(page, body, "Whole body")
+}
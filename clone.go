@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// CloneRequest returns a deep copy of req, with its own URL, headers,
+// trailer and a body that reads independently of the original's,
+// e.g., for hedging or mirroring a request. The body is taken from
+// GetBody when set; otherwise it is read into memory, the original's
+// restored, and GetBody set on both.
+func CloneRequest(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return clone, nil
+	}
+
+	if req.GetBody == nil {
+		data, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		}
+		req.Body, _ = req.GetBody()
+		clone.GetBody = req.GetBody
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	clone.Body = body
+	return clone, nil
+}
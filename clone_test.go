@@ -0,0 +1,64 @@
+package utils_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func readBody(t *testing.T, body io.Reader) string {
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCloneRequest(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	// a body without GetBody
+	req, _ := http.NewRequest("POST", "http://example.com/path?q=1", ioutil.NopCloser(strings.NewReader("payload")))
+	req.Host = "virtual.example.com"
+	req.ContentLength = 7
+	req.Header.Set("X-Foo", "bar")
+	req.Trailer = http.Header{"X-Sum": {"1"}}
+
+	clone, err := CloneRequest(req)
+	a.NoError(err, "No error")
+	a.Equal("virtual.example.com", clone.Host, "Host kept")
+	a.Equal(int64(7), clone.ContentLength, "ContentLength kept")
+	a.NotNil(req.GetBody, "GetBody set on the original")
+	a.NotNil(clone.GetBody, "GetBody set on the clone")
+
+	clone.Header.Set("X-Foo", "changed")
+	clone.Trailer.Set("X-Sum", "2")
+	clone.URL.Path = "/other"
+	a.Equal("bar", req.Header.Get("X-Foo"), "Original header untouched")
+	a.Equal("1", req.Trailer.Get("X-Sum"), "Original trailer untouched")
+	a.Equal("/path", req.URL.Path, "Original URL untouched")
+
+	a.Equal("payload", readBody(t, clone.Body), "Clone body")
+	a.Equal("payload", readBody(t, req.Body), "Original body restored")
+
+	// and vice versa, with GetBody
+	req, _ = http.NewRequest("PUT", "http://example.com", bytes.NewReader([]byte("data")))
+	clone, err = CloneRequest(req)
+	a.NoError(err, "No error")
+	req.Header.Set("X-Bar", "baz")
+	a.Equal("", clone.Header.Get("X-Bar"), "Clone header untouched")
+	a.Equal("data", readBody(t, req.Body), "Original body")
+	a.Equal("data", readBody(t, clone.Body), "Clone body independent")
+
+	// nil body
+	req, _ = http.NewRequest("GET", "http://example.com", nil)
+	clone, err = CloneRequest(req)
+	a.NoError(err, "No error")
+	a.Nil(clone.Body, "No body")
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Codec encodes request bodies and decodes response bodies in one
+// format for PostWithCodec and GetWithCodec; JSONCodec and FormCodec
+// are built in, other formats can live in their own packages.
+type Codec interface {
+	// ContentType is sent as the Content-Type of encoded bodies;
+	// its media type (without parameters) is sent as Accept.
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec is the Codec of encoding/json.
+var JSONCodec Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// FormCodec is the Codec of URL-encoded forms: it marshals url.Values
+// or a struct by EncodeQuery, and unmarshals into a *url.Values
+// or a struct pointer by DecodeQuery.
+var FormCodec Codec = formCodec{}
+
+type formCodec struct{}
+
+func (formCodec) ContentType() string {
+	return "application/x-www-form-urlencoded"
+}
+
+func (formCodec) Marshal(v interface{}) ([]byte, error) {
+	values, ok := v.(url.Values)
+	if !ok {
+		var err error
+		if values, err = EncodeQuery(v); err != nil {
+			return nil, err
+		}
+	}
+	return []byte(values.Encode()), nil
+}
+
+func (formCodec) Unmarshal(data []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	if p, ok := v.(*url.Values); ok {
+		*p = values
+		return nil
+	}
+	return DecodeQuery(values, v)
+}
+
+// GetWithCodec GETs url with retries and decodes a 2xx body into out
+// by codec; see PostWithCodec.
+func (c *SafeClient) GetWithCodec(ctx context.Context, codec Codec, url string, out interface{}, maxTries int, f RequestHook) (tries, status int, err error) {
+	return c.doCodec(ctx, codec, "GET", url, nil, out, maxTries, f)
+}
+
+// PostWithCodec POSTs in encoded by codec with retries and decodes
+// a 2xx body into out (if not nil) by codec. Content-Type and Accept
+// are set from codec, which the RequestHook may override. A 204 or
+// an empty (or whitespace-only) body leaves out untouched;
+// any other final status is an *HTTPError.
+func (c *SafeClient) PostWithCodec(ctx context.Context, codec Codec, url string, in, out interface{}, maxTries int, f RequestHook) (tries, status int, err error) {
+	data, err := codec.Marshal(in)
+	if err != nil {
+		return
+	}
+	return c.doCodec(ctx, codec, "POST", url, data, out, maxTries, func(req *http.Request) {
+		req.Header.Set("Content-Type", codec.ContentType())
+		if f != nil {
+			f(req)
+		}
+	})
+}
+
+func (c *SafeClient) doCodec(ctx context.Context, codec Codec, method, url string, content []byte, out interface{}, maxTries int, f RequestHook) (tries, status int, err error) {
+	accept := codec.ContentType()
+	if i := strings.Index(accept, ";"); i >= 0 {
+		accept = strings.TrimSpace(accept[:i])
+	}
+	tries, res, err := c.doRequest(ctx, method, url, content, maxTries, func(req *http.Request) {
+		req.Header.Set("Accept", accept)
+		if f != nil {
+			f(req)
+		}
+	})
+	status = res.Status
+	if err != nil {
+		return
+	}
+	if status < 200 || status > 299 {
+		err = &HTTPError{status, res.Body}
+		return
+	}
+	if out != nil && len(bytes.TrimSpace(res.Body)) > 0 {
+		if err = codec.Unmarshal(res.Body, out); err != nil {
+			err = fmt.Errorf("utils: decoding %s body: %w", accept, err)
+			return
+		}
+	}
+	if res.Fallback {
+		err = ErrFallback
+	}
+	return
+}
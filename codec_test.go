@@ -0,0 +1,108 @@
+package utils_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+// gobCodec is a trivial Codec for the tests.
+type gobCodec struct{}
+
+func (gobCodec) ContentType() string {
+	return "application/x-gob"
+}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type codecItem struct {
+	Name  string `url:"name"`
+	Count int    `url:"count"`
+}
+
+// echoHandler echoes the body and the content headers.
+var echoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+	w.Header().Set("X-Accept", r.Header.Get("Accept"))
+	body, _ := ioutil.ReadAll(r.Body)
+	w.Write(body)
+})
+
+func TestSafeClient_PostWithCodec(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		echoHandler(w, r)
+	}))
+	defer server.Close()
+
+	in := codecItem{"foo", 3}
+	var out codecItem
+	_, status, err := testTimeoutClient.PostWithCodec(context.Background(), gobCodec{}, server.URL, in, &out, 3, nil)
+	a.NoError(err, "No error")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal(in, out, "Round-trip")
+	a.Equal("application/x-gob", header.Get("Content-Type"), "Content-Type from codec")
+	a.Equal("application/x-gob", header.Get("Accept"), "Accept from codec")
+
+	// the form codec, both ways
+	out = codecItem{}
+	_, _, err = testTimeoutClient.PostWithCodec(context.Background(), FormCodec, server.URL, in, &out, 3, nil)
+	a.NoError(err, "No error")
+	a.Equal(in, out, "Form round-trip")
+	a.Equal("application/x-www-form-urlencoded", header.Get("Content-Type"), "Form Content-Type")
+
+	var values url.Values
+	_, _, err = testTimeoutClient.PostWithCodec(context.Background(), FormCodec, server.URL, url.Values{"a": {"1"}}, &values, 3, nil)
+	a.NoError(err, "No error")
+	a.Equal(url.Values{"a": {"1"}}, values, "Values round-trip")
+
+	// the JSON codec asks without the charset
+	_, _, err = testTimeoutClient.PostWithCodec(context.Background(), JSONCodec, server.URL, in, nil, 3, nil)
+	a.NoError(err, "No error")
+	a.Equal("application/json; charset=utf-8", header.Get("Content-Type"), "JSON Content-Type")
+	a.Equal("application/json", header.Get("Accept"), "JSON Accept")
+}
+
+func TestSafeClient_GetWithCodec(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	data, _ := gobCodec{}.Marshal(codecItem{"bar", 1})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/x-gob" {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	var out codecItem
+	_, status, err := testTimeoutClient.GetWithCodec(context.Background(), gobCodec{}, server.URL, &out, 3, nil)
+	a.NoError(err, "No error")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal(codecItem{"bar", 1}, out, "Decoded")
+
+	_, status, err = testTimeoutClient.GetWithCodec(context.Background(), FormCodec, server.URL, &out, 3, nil)
+	_, ok := err.(*HTTPError)
+	a.True(ok, "Non-2xx is *HTTPError")
+	a.Equal(http.StatusNotAcceptable, status, "Returns code")
+}
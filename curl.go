@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxCurlBody is the largest body CurlString writes out as is.
+const maxCurlBody = 64 << 10
+
+// CurlString renders req as a copy-pasteable curl command with its
+// method, headers and body, shell-quoted. The Authorization and Cookie
+// headers, and any in redactHeaders, have their values redacted;
+// a binary or oversized body is replaced by a placeholder.
+// The body is read from a CloneRequest copy, so req can still be sent.
+func CurlString(req *http.Request, redactHeaders ...string) (string, error) {
+	clone, err := CloneRequest(req)
+	if err != nil {
+		return "", err
+	}
+
+	redact := map[string]bool{"Authorization": true, "Cookie": true}
+	for _, h := range redactHeaders {
+		redact[http.CanonicalHeaderKey(h)] = true
+	}
+
+	var b strings.Builder
+	b.WriteString("curl")
+	if req.Method != "" && req.Method != "GET" {
+		b.WriteString(" -X " + req.Method)
+	}
+	b.WriteString(" " + shellQuote(req.URL.String()))
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if req.Host != "" && req.Host != req.URL.Host {
+		b.WriteString(" -H " + shellQuote("Host: "+req.Host))
+	}
+	for _, k := range keys {
+		for _, v := range req.Header[k] {
+			if redact[http.CanonicalHeaderKey(k)] {
+				v = "REDACTED"
+			}
+			b.WriteString(" -H " + shellQuote(k+": "+v))
+		}
+	}
+
+	if clone.Body != nil {
+		data, err := ioutil.ReadAll(clone.Body)
+		clone.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		if len(data) > 0 {
+			b.WriteString(" --data-binary ")
+			switch {
+			case len(data) > maxCurlBody:
+				b.WriteString(shellQuote(fmt.Sprintf("<%d bytes body omitted>", len(data))))
+			case !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0:
+				b.WriteString(shellQuote(fmt.Sprintf("<%d bytes binary body omitted>", len(data))))
+			default:
+				b.WriteString(shellQuote(string(data)))
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// WithCurlLog passes every try sent, as sent (e.g., with AttemptHeader
+// and DefaultHeaders), to fn as a curl command by CurlString, the
+// redactHeaders redacted too, for a debug dump; a body streamed without
+// GetBody (e.g., of PostMultipart) is not read for it but left out.
+// Failing to render a try is logged to ErrorLog, never failing the call.
+func WithCurlLog(fn func(curl string), redactHeaders ...string) Option {
+	return func(c *SafeClient) error {
+		c.CurlLog = fn
+		c.CurlRedactHeaders = redactHeaders
+		return nil
+	}
+}
+
+// logCurl passes req to CurlLog.
+func (c *SafeClient) logCurl(req *http.Request) {
+	r := req
+	streamed := req.Body != nil && req.Body != http.NoBody && req.GetBody == nil
+	if streamed {
+		// CurlString would read it into memory
+		cp := *req
+		cp.Body = nil
+		r = &cp
+	}
+	s, err := CurlString(r, c.CurlRedactHeaders...)
+	if err != nil {
+		c.logf("utils: curl of %s %s: %v", req.Method, req.URL, err)
+		return
+	}
+	if streamed {
+		s += " --data-binary " + shellQuote("<streamed body omitted>")
+	}
+	c.CurlLog(s)
+}
+
+// shellQuote single-quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
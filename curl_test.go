@@ -0,0 +1,94 @@
+package utils_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestCurlString(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	req, _ := NewJSONPost("http://example.com/api?q=1", testContent{"it's"}, func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("X-Api-Key", "key")
+		req.Header.Set("X-Trace", "abc")
+	})
+	s, err := CurlString(req, "x-api-key")
+	a.NoError(err, "No error")
+	a.Equal(`curl -X POST 'http://example.com/api?q=1'`+
+		` -H 'Authorization: REDACTED'`+
+		` -H 'Content-Type: application/json; charset=utf-8'`+
+		` -H 'X-Api-Key: REDACTED'`+
+		` -H 'X-Trace: abc'`+
+		` --data-binary '{"data":"it'\''s"}'`, s, "Curl command")
+
+	body, _ := ioutil.ReadAll(req.Body)
+	a.Equal(`{"data":"it's"}`, string(body), "Body not consumed")
+
+	req, _ = http.NewRequest("GET", "http://example.com", nil)
+	req.Header.Set("Cookie", "session=1")
+	s, _ = CurlString(req)
+	a.Equal(`curl 'http://example.com' -H 'Cookie: REDACTED'`, s, "GET without body")
+
+	req, _ = http.NewRequest("PUT", "http://example.com", bytes.NewReader([]byte{0xff, 0x00, 0x01}))
+	s, _ = CurlString(req)
+	a.True(strings.HasSuffix(s, `--data-binary '<3 bytes binary body omitted>'`), "Binary placeholder")
+}
+
+func TestWithCurlLog(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var tries int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&tries, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	var curls []string
+	cl, err := NewClient(WithCurlLog(func(curl string) {
+		curls = append(curls, curl)
+	}, "X-Api-Key"), WithAttemptHeader(""))
+	a.NoError(err, "No error")
+	cl.Sleep = (&fakeSleeper{}).Sleep
+
+	_, status, _, err := cl.DoRequest("PUT", server.URL, []byte("it's"), 3, func(req *http.Request) {
+		req.Header.Set("X-Api-Key", "key")
+	})
+	a.NoError(err, "No error")
+	a.Equal(http.StatusOK, status, "Retried")
+	if a.Equal(2, len(curls), "Every try") {
+		for i, n := range []string{"0", "1"} {
+			a.Equal(`curl -X PUT '`+server.URL+`'`+
+				` -H 'X-Api-Key: REDACTED'`+
+				` -H 'X-Retry-Attempt: `+n+`'`+
+				` --data-binary 'it'\''s'`, curls[i], "Try as sent")
+		}
+	}
+
+	// a streamed body is left unread
+	curls = nil
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("streamed"))
+		pw.Close()
+	}()
+	req, _ := http.NewRequest("POST", server.URL, pr)
+	_, _, _, err = cl.RequestWithRetry(req, 1)
+	a.NoError(err, "Body still sent")
+	if a.Equal(1, len(curls), "Logged") {
+		a.Equal(`curl -X POST '`+server.URL+`'`+
+			` -H 'X-Retry-Attempt: 0'`+
+			` --data-binary '<streamed body omitted>'`, curls[0], "Placeholder body")
+	}
+}
@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxDecodedBytes bounds a decoded body if WithDecompression
+// is given no limit.
+const DefaultMaxDecodedBytes = 64 << 20
+
+// ErrDecodedTooLarge is returned reading a decoded body past its limit,
+// e.g., a compression bomb.
+var ErrDecodedTooLarge = errors.New("utils: decoded body too large")
+
+// Decoder wraps a body in a Content-Encoding into its decoded content.
+type Decoder func(r io.Reader) (io.ReadCloser, error)
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[string]Decoder{
+		"gzip": func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+		"deflate": func(r io.Reader) (io.ReadCloser, error) {
+			return zlib.NewReader(r)
+		},
+	}
+)
+
+// RegisterDecoder makes the Content-Encoding (e.g., "br" or "zstd")
+// decodable by WithDecompression; gzip and deflate are built in.
+// It keeps their libraries out of this package: register them
+// from an init function of the program that wants them, as importing
+// github.com/ShevaXu/web-utils/decoders does for br and zstd.
+func RegisterDecoder(encoding string, d Decoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[strings.ToLower(encoding)] = d
+}
+
+func decoder(encoding string) Decoder {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	return decoders[strings.ToLower(encoding)]
+}
+
+// WithDecompression advertises the encodings in Accept-Encoding and
+// decodes the responses in them, leaving no Content-Encoding; each
+// encoding must be built in or registered by RegisterDecoder at the time
+// of the option. If none given, those of gzip, br and zstd registered are.
+// Stacked codings, e.g., "gzip, br", are decoded in turn, and one with
+// no decoder fails the request rather than return a still-encoded body.
+// Reading more than maxBytes (DefaultMaxDecodedBytes if not positive)
+// decoded bytes fails with ErrDecodedTooLarge.
+// A request with its own Accept-Encoding is left to the caller.
+func WithDecompression(maxBytes int64, encodings ...string) Option {
+	return func(c *SafeClient) error {
+		encs := encodings
+		if len(encs) == 0 {
+			for _, enc := range []string{"gzip", "br", "zstd"} {
+				if decoder(enc) != nil {
+					encs = append(encs, enc)
+				}
+			}
+		}
+		for _, enc := range encs {
+			if decoder(enc) == nil {
+				return fmt.Errorf("utils: no decoder registered for %q", enc)
+			}
+		}
+		max := maxBytes
+		if max <= 0 {
+			max = DefaultMaxDecodedBytes
+		}
+		if _, err := c.transport(); err != nil {
+			return err
+		}
+		c.Transport = &decodingTransport{
+			base:           c.Transport,
+			acceptEncoding: strings.Join(encs, ", "),
+			maxBytes:       max,
+		}
+		return nil
+	}
+}
+
+// decodingTransport is the RoundTripper installed by WithDecompression.
+type decodingTransport struct {
+	base           http.RoundTripper
+	acceptEncoding string
+	maxBytes       int64
+}
+
+func (t *decodingTransport) Unwrap() http.RoundTripper {
+	return t.base
+}
+
+func (t *decodingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") != "" {
+		return t.base.RoundTrip(req)
+	}
+	// RoundTrip must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", t.acceptEncoding)
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	encs := contentEncodings(resp.Header)
+	if len(encs) == 0 || req.Method == "HEAD" || resp.StatusCode == http.StatusNoContent {
+		return resp, nil
+	}
+
+	// undone in the reverse order they were applied
+	body := &decodedBody{closers: []io.Closer{resp.Body}, left: t.maxBytes}
+	r := io.Reader(resp.Body)
+	for i := len(encs) - 1; i >= 0; i-- {
+		d := decoder(encs[i])
+		if d == nil {
+			body.Close()
+			return nil, fmt.Errorf("utils: no decoder for Content-Encoding %q", encs[i])
+		}
+		rc, err := d(r)
+		if err != nil {
+			body.Close()
+			return nil, fmt.Errorf("utils: decoding %s body: %w", encs[i], err)
+		}
+		body.closers = append(body.closers, rc)
+		r = rc
+	}
+	body.r = r
+	resp.Body = body
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// contentEncodings lists the codings of the Content-Encoding of h
+// in the order they were applied, "identity" left out.
+func contentEncodings(h http.Header) []string {
+	var encs []string
+	for _, v := range h.Values("Content-Encoding") {
+		for _, enc := range strings.Split(v, ",") {
+			if enc = strings.TrimSpace(enc); enc != "" && !strings.EqualFold(enc, "identity") {
+				encs = append(encs, enc)
+			}
+		}
+	}
+	return encs
+}
+
+// decodedBody reads at most left decoded bytes and closes
+// the decoders and the raw body, the first of closers.
+type decodedBody struct {
+	r       io.Reader
+	closers []io.Closer
+	left    int64
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.left <= 0 {
+		// one more byte tells a body of exactly the limit
+		var one [1]byte
+		n, err := b.r.Read(one[:])
+		if n > 0 {
+			return 0, ErrDecodedTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.left {
+		p = p[:b.left]
+	}
+	n, err := b.r.Read(p)
+	b.left -= int64(n)
+	return n, err
+}
+
+func (b *decodedBody) Close() error {
+	for i := len(b.closers) - 1; i > 0; i-- {
+		b.closers[i].Close()
+	}
+	return b.closers[0].Close()
+}
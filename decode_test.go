@@ -0,0 +1,114 @@
+package utils_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+// a stand-in for br or zstd, whose libraries are not a dependency
+func init() {
+	RegisterDecoder("x-base64", func(r io.Reader) (io.ReadCloser, error) {
+		return ioutil.NopCloser(base64.NewDecoder(base64.StdEncoding, r)), nil
+	})
+}
+
+func encodeFixture(t *testing.T, enc string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch enc {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "x-base64":
+		w = base64.NewEncoder(base64.StdEncoding, &buf)
+	default:
+		t.Fatalf("no fixture for %s", enc)
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestWithDecompression(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	content := []byte(strings.Repeat("compress me ", 100))
+	fixtures := map[string][]byte{}
+	for _, enc := range []string{"gzip", "deflate", "x-base64"} {
+		fixtures[enc] = encodeFixture(t, enc, content)
+	}
+
+	var accepted string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted = r.Header.Get("Accept-Encoding")
+		enc := r.URL.Query().Get("enc")
+		w.Header().Set("Content-Encoding", enc)
+		w.Write(fixtures[enc])
+	}))
+	defer server.Close()
+
+	cl, err := NewClient(WithDecompression(0, "gzip", "deflate", "x-base64"))
+	a.NoError(err, "No error")
+	for enc := range fixtures {
+		_, status, body, err := cl.DoRequest("GET", server.URL+"?enc="+enc, nil, 1, nil)
+		a.NoError(err, "No error")
+		a.Equal(http.StatusOK, status, "Returns code")
+		a.Equalf(content, body, "Decoded %s", enc)
+	}
+	a.Equal("gzip, deflate, x-base64", accepted, "Advertised")
+
+	// the caller's Accept-Encoding passes through undecoded
+	_, _, body, _ := cl.DoRequest("GET", server.URL+"?enc=x-base64", nil, 1, func(req *http.Request) {
+		req.Header.Set("Accept-Encoding", "x-base64")
+	})
+	a.Equal(fixtures["x-base64"], body, "Left to the caller")
+
+	// stacked, applied in order
+	fixtures["gzip, x-base64"] = encodeFixture(t, "x-base64", fixtures["gzip"])
+	_, _, body, err = cl.DoRequest("GET", server.URL+"?enc="+url.QueryEscape("gzip, x-base64"), nil, 1, nil)
+	a.NoError(err, "No error")
+	a.Equal(content, body, "Decoded in reverse order")
+	fixtures["gzip, x-unknown"] = fixtures["gzip"]
+	_, _, _, err = cl.DoRequest("GET", server.URL+"?enc="+url.QueryEscape("gzip, x-unknown"), nil, 1, nil)
+	a.Match(`no decoder for Content-Encoding "x-unknown"`, err, "Undecodable coding told")
+
+	_, err = NewClient(WithDecompression(0, "gzip", "x-unknown"))
+	a.NotNil(err, "Unregistered encoding")
+
+	cl, _ = NewClient(WithDecompression(0))
+	cl.DoRequest("GET", server.URL, nil, 1, nil)
+	a.Equal("gzip", accepted, "Registered defaults only")
+}
+
+func TestWithDecompression_Limit(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	bomb := encodeFixture(t, "gzip", make([]byte, 1<<20))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(bomb)
+	}))
+	defer server.Close()
+
+	cl, _ := NewClient(WithDecompression(1024))
+	_, _, _, err := cl.DoRequest("GET", server.URL, nil, 1, nil)
+	a.Equal(ErrDecodedTooLarge, err, "Bomb stopped")
+
+	cl, _ = NewClient(WithDecompression(1 << 20))
+	_, _, body, err := cl.DoRequest("GET", server.URL, nil, 1, nil)
+	a.NoError(err, "Exactly the limit")
+	a.Equal(1<<20, len(body), "Fully decoded")
+}
@@ -0,0 +1,38 @@
+// Package decoders registers the br (Brotli) and zstd decoders of
+// utils.WithDecompression when imported, for side effects:
+//
+//	import _ "github.com/ShevaXu/web-utils/decoders"
+//
+// It is a module of its own, which keeps their libraries out of
+// the dependencies of the core package.
+package decoders
+
+import (
+	"io"
+	"io/ioutil"
+
+	utils "github.com/ShevaXu/web-utils"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func init() {
+	utils.RegisterDecoder("br", Brotli)
+	utils.RegisterDecoder("zstd", Zstd)
+}
+
+// Brotli is the Decoder of the br Content-Encoding.
+func Brotli(r io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(brotli.NewReader(r)), nil
+}
+
+// Zstd is the Decoder of the zstd Content-Encoding, decoding in the
+// reading goroutine and within the window a server can ask of a client
+// by RFC 9659, 8MB.
+func Zstd(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(8<<20))
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
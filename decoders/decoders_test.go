@@ -0,0 +1,59 @@
+package decoders_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	utils "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+	_ "github.com/ShevaXu/web-utils/decoders"
+)
+
+// the pre-compressed fixtures of testdata/content.txt
+var fixtures = map[string]string{"gzip": ".gz", "br": ".br", "zstd": ".zst"}
+
+func fixtureServer(accepted *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*accepted = r.Header.Get("Accept-Encoding")
+		enc := r.URL.Query().Get("enc")
+		w.Header().Set("Content-Encoding", enc)
+		http.ServeFile(w, r, filepath.Join("testdata", "content.txt"+fixtures[enc]))
+	}))
+}
+
+func TestDecoders(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	content, err := ioutil.ReadFile(filepath.Join("testdata", "content.txt"))
+	a.NoError(err, "Fixture read")
+	var accepted string
+	server := fixtureServer(&accepted)
+	defer server.Close()
+
+	cl, err := utils.NewClient(utils.WithDecompression(0))
+	a.NoError(err, "No error")
+	for enc := range fixtures {
+		_, status, body, err := cl.DoRequest("GET", server.URL+"?enc="+enc, nil, 1, nil)
+		a.NoErrorf(err, "%s: no error", enc)
+		a.Equalf(http.StatusOK, status, "%s: returns code", enc)
+		a.Equalf(string(content), string(body), "%s: decoded", enc)
+	}
+	a.Equal("gzip, br, zstd", accepted, "Registered by import")
+}
+
+func TestDecoders_Limit(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var accepted string
+	server := fixtureServer(&accepted)
+	defer server.Close()
+
+	cl, _ := utils.NewClient(utils.WithDecompression(1024))
+	for _, enc := range []string{"br", "zstd"} {
+		_, _, _, err := cl.DoRequest("GET", server.URL+"?enc="+enc, nil, 1, nil)
+		a.Equalf(utils.ErrDecodedTooLarge, err, "%s: bounded", enc)
+	}
+}
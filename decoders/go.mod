@@ -0,0 +1,11 @@
+module github.com/ShevaXu/web-utils/decoders
+
+go 1.24
+
+require (
+	github.com/ShevaXu/web-utils v0.0.0
+	github.com/andybalholm/brotli v1.2.0
+	github.com/klauspost/compress v1.18.0
+)
+
+replace github.com/ShevaXu/web-utils => ../
@@ -0,0 +1,452 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrBlockedAddress is returned (wrapped) when SSRF protection
+// refuses to dial an address; such errors are never retried.
+var ErrBlockedAddress = errors.New("utils: blocked address")
+
+// Resolver looks up a host's addresses; *net.Resolver implements it.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// dialer is the DialContext installed into the Transport by
+// the dial-level options; each option sets one of its fields.
+type dialer struct {
+	net.Dialer
+	resolver Resolver
+
+	// socket, if set, is dialed for every connection
+	// regardless of the requested address.
+	socket string
+
+	// overrides maps "host:port" or host to the address dialed instead.
+	overrides map[string]string
+
+	// blocked, if set, lists ranges never dialed
+	// unless also listed in allowed.
+	blocked, allowed []net.IPNet
+
+	// perAddr, if set, bounds the connect to each resolved address,
+	// and an address failed is tried last for cooldown.
+	perAddr, cooldown time.Duration
+
+	// prefer, if 4 or 6, is the IP version dialed first, with
+	// the other raced after FallbackDelay (happy eyeballs).
+	prefer int
+
+	mu     sync.Mutex
+	failed map[string]time.Time // host|ip -> when it failed
+}
+
+// newDialer returns a dialer with the same settings as
+// http.DefaultTransport's.
+func newDialer() *dialer {
+	return &dialer{
+		Dialer: net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		},
+		resolver: net.DefaultResolver,
+	}
+}
+
+func (d *dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.socket != "" {
+		return d.Dialer.DialContext(ctx, "unix", d.socket)
+	}
+	address = d.override(address)
+	if d.blocked == nil && d.perAddr == 0 && d.prefer == 0 {
+		return d.Dialer.DialContext(ctx, network, address)
+	}
+
+	// resolve here and dial the checked IPs only,
+	// so a second lookup cannot rebind the host
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := d.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if !d.permit(ip) {
+			return nil, fmt.Errorf("%w: %s resolves to %s", ErrBlockedAddress, host, ip)
+		}
+	}
+
+	ips = d.order(host, ips)
+	if d.prefer != 0 {
+		primaries, fallbacks := partitionIPs(ips, d.prefer)
+		if len(primaries) > 0 && len(fallbacks) > 0 && d.FallbackDelay >= 0 {
+			return d.dialRace(ctx, network, host, port, primaries, fallbacks)
+		}
+		ips = append(primaries, fallbacks...)
+	}
+	return d.dialSerial(ctx, network, host, port, ips)
+}
+
+// dialSerial dials the ips of host one by one until one connects.
+func (d *dialer) dialSerial(ctx context.Context, network, host, port string, ips []net.IP) (conn net.Conn, err error) {
+	for _, ip := range ips {
+		conn, err = d.dialAddr(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		d.fail(host, ip)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+// dialRace dials the primaries of host, and the fallbacks after
+// FallbackDelay (300ms if 0) or once the primaries failed, returning
+// the first connection made; the error is the primaries' if both fail.
+func (d *dialer) dialRace(ctx context.Context, network, host, port string, primaries, fallbacks []net.IP) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn    net.Conn
+		err     error
+		primary bool
+	}
+	results := make(chan result, 2)
+	pending := 0
+	start := func(ips []net.IP, primary bool) {
+		pending++
+		go func() {
+			conn, err := d.dialSerial(ctx, network, host, port, ips)
+			results <- result{conn, err, primary}
+		}()
+	}
+
+	delay := d.FallbackDelay
+	if delay == 0 {
+		delay = 300 * time.Millisecond
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	start(primaries, true)
+	fallbackStarted := false
+	var primaryErr, fallbackErr error
+	for {
+		select {
+		case <-timer.C:
+			if !fallbackStarted {
+				fallbackStarted = true
+				start(fallbacks, false)
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				if pending > 0 {
+					// close the loser, if it connects after all
+					go func() {
+						if res := <-results; res.conn != nil {
+							res.conn.Close()
+						}
+					}()
+				}
+				return res.conn, nil
+			}
+			if res.primary {
+				primaryErr = res.err
+			} else {
+				fallbackErr = res.err
+			}
+			if !fallbackStarted {
+				fallbackStarted = true
+				start(fallbacks, false)
+			}
+			if pending == 0 {
+				if primaryErr != nil {
+					return nil, primaryErr
+				}
+				return nil, fallbackErr
+			}
+		}
+	}
+}
+
+// partitionIPs splits ips into those of IP version v and the others,
+// keeping their order.
+func partitionIPs(ips []net.IP, v int) (primaries, fallbacks []net.IP) {
+	for _, ip := range ips {
+		if (ip.To4() != nil) == (v == 4) {
+			primaries = append(primaries, ip)
+		} else {
+			fallbacks = append(fallbacks, ip)
+		}
+	}
+	return
+}
+
+// override returns the address to dial for address by the overrides.
+func (d *dialer) override(address string) string {
+	if d.overrides == nil {
+		return address
+	}
+	if to, ok := d.overrides[address]; ok {
+		return to
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	to, ok := d.overrides[host]
+	if !ok {
+		return address
+	}
+	if _, _, err := net.SplitHostPort(to); err != nil {
+		// no port given, keep the requested one
+		return net.JoinHostPort(to, port)
+	}
+	return to
+}
+
+// dialAddr dials one resolved address, bounded by perAddr.
+func (d *dialer) dialAddr(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.perAddr > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.perAddr)
+		defer cancel()
+	}
+	return d.Dialer.DialContext(ctx, network, address)
+}
+
+// order moves the host's IPs failed within cooldown to the back.
+func (d *dialer) order(host string, ips []net.IP) []net.IP {
+	if d.cooldown <= 0 {
+		return ips
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	ordered := make([]net.IP, 0, len(ips))
+	var cooling []net.IP
+	for _, ip := range ips {
+		key := host + "|" + ip.String()
+		if t, ok := d.failed[key]; ok {
+			if now.Sub(t) < d.cooldown {
+				cooling = append(cooling, ip)
+				continue
+			}
+			delete(d.failed, key)
+		}
+		ordered = append(ordered, ip)
+	}
+	return append(ordered, cooling...)
+}
+
+// fail remembers that ip of host failed to connect.
+func (d *dialer) fail(host string, ip net.IP) {
+	if d.cooldown <= 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.failed == nil {
+		d.failed = make(map[string]time.Time)
+	}
+	d.failed[host+"|"+ip.String()] = time.Now()
+}
+
+// lookup resolves host, which may already be an IP.
+func (d *dialer) lookup(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	addrs, err := d.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP
+	}
+	return ips, nil
+}
+
+// permit tells if the ip is not blocked or is allowed explicitly.
+func (d *dialer) permit(ip net.IP) bool {
+	for _, n := range d.allowed {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	for _, n := range d.blocked {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// privateNets are loopback, RFC1918, link-local, ULA
+// and unspecified ranges.
+var privateNets = mustParseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+)
+
+func mustParseCIDRs(cidrs ...string) []net.IPNet {
+	nets := make([]net.IPNet, len(cidrs))
+	for i, s := range cidrs {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = *n
+	}
+	return nets
+}
+
+// WithUnixSocket makes the client dial the unix domain socket at path
+// for every request, whatever host the URL names.
+// By convention such URLs use "unix" as the host, e.g.,
+// http://unix/v1.40/containers/json sends GET /v1.40/containers/json.
+func WithUnixSocket(path string) Option {
+	return func(c *SafeClient) error {
+		if path == "" {
+			return errors.New("utils: empty unix socket path")
+		}
+		d, err := c.dialer()
+		if err != nil {
+			return err
+		}
+		d.socket = path
+		return nil
+	}
+}
+
+// WithResolver replaces the resolver used by the dial-level options.
+func WithResolver(r Resolver) Option {
+	return func(c *SafeClient) error {
+		d, err := c.dialer()
+		if err != nil {
+			return err
+		}
+		d.resolver = r
+		return nil
+	}
+}
+
+// WithSSRFProtection refuses to connect to loopback, private (RFC1918),
+// link-local and unique local addresses, except those in allowlist.
+// The check is done on the resolved IPs at dial time, so it covers
+// redirects and cannot be bypassed by DNS rebinding;
+// refused requests fail with ErrBlockedAddress.
+func WithSSRFProtection(allowlist ...net.IPNet) Option {
+	return func(c *SafeClient) error {
+		d, err := c.dialer()
+		if err != nil {
+			return err
+		}
+		d.blocked = privateNets
+		d.allowed = allowlist
+		return nil
+	}
+}
+
+// WithDialFallback tries the resolved addresses of a host one by one,
+// giving each perAddr to connect, instead of waiting the full connect
+// timeout on the first; an address that failed is tried last
+// for cooldown afterwards.
+func WithDialFallback(perAddr, cooldown time.Duration) Option {
+	return func(c *SafeClient) error {
+		if perAddr <= 0 {
+			return errors.New("utils: non-positive per-address dial timeout")
+		}
+		d, err := c.dialer()
+		if err != nil {
+			return err
+		}
+		d.perAddr = perAddr
+		d.cooldown = cooldown
+		return nil
+	}
+}
+
+// WithHostOverrides dials the address overrides maps a "host:port"
+// (or host, for any port) to instead of resolving it, e.g., to point
+// a name at a local test server or a canary; the address may omit
+// the port to keep the requested one. Only the connection is redirected:
+// the Host header and TLS server name still are the URL's.
+func WithHostOverrides(overrides map[string]string) Option {
+	return func(c *SafeClient) error {
+		d, err := c.dialer()
+		if err != nil {
+			return err
+		}
+		if d.overrides == nil {
+			d.overrides = make(map[string]string, len(overrides))
+		}
+		for from, to := range overrides {
+			d.overrides[from] = to
+		}
+		return nil
+	}
+}
+
+// WithFallbackDelay sets how long a dual-stack dial waits on the
+// preferred IP version before racing the other (net.Dialer.FallbackDelay);
+// 0 is 300ms and a negative delay disables the race.
+func WithFallbackDelay(delay time.Duration) Option {
+	return func(c *SafeClient) error {
+		d, err := c.dialer()
+		if err != nil {
+			return err
+		}
+		d.FallbackDelay = delay
+		return nil
+	}
+}
+
+// WithPreferIPv4 dials the IPv4 addresses of a host first, racing its
+// IPv6 ones after the fallback delay, e.g., on networks with broken IPv6.
+func WithPreferIPv4() Option {
+	return withPrefer(4)
+}
+
+// WithPreferIPv6 dials the IPv6 addresses of a host first, racing its
+// IPv4 ones after the fallback delay.
+func WithPreferIPv6() Option {
+	return withPrefer(6)
+}
+
+func withPrefer(v int) Option {
+	return func(c *SafeClient) error {
+		d, err := c.dialer()
+		if err != nil {
+			return err
+		}
+		d.prefer = v
+		return nil
+	}
+}
@@ -0,0 +1,309 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+// newUnixServer starts a server listening on a socket in a temp dir.
+func newUnixServer(t *testing.T, h http.Handler) (*httptest.Server, string) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Error listen unix: %s", err)
+	}
+
+	server := httptest.NewUnstartedServer(h)
+	server.Listener.Close()
+	server.Listener = l
+	server.Start()
+	return server, path
+}
+
+func TestWithUnixSocket(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + r.URL.Path + " " + string(body)))
+	})
+	server, path := newUnixServer(t, echo)
+	defer server.Close()
+
+	cl, err := NewClient(WithUnixSocket(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, status, body, err := cl.DoRequest("GET", "http://unix/v1/info", nil, 3, nil)
+	a.NoError(err, "GET over socket")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal("GET /v1/info ", string(body), "Returns body")
+	a.Equal(0, n, "Report retried times")
+
+	v := url.Values{"foo": {"bar"}}
+	_, status, body, err = cl.PostFormWithRetry("http://unix/form", v, 3, nil)
+	a.NoError(err, "POST over socket")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal("POST /form foo=bar", string(body), "Returns body")
+}
+
+func TestWithUnixSocket_Retry(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server, path := newUnixServer(t, Status5xxHandlerFunc)
+	defer server.Close()
+
+	cl, err := NewClient(WithUnixSocket(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl.Backoff = testBackoff
+
+	n, status, body, err := cl.DoRequest("GET", "http://unix/", nil, 3, nil)
+	a.NoError(err, "No transport error")
+	a.Equal(http.StatusInternalServerError, status, "Returns code")
+	a.Equal([]byte(internalErr), body, "Returns body")
+	a.Equal(2, n, "Retries apply unchanged")
+}
+
+func TestWithUnixSocket_Invalid(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	_, err := NewClient(WithUnixSocket(""))
+	a.True(err != nil, "Empty path is rejected")
+
+	_, err = NewClient(func(c *SafeClient) error {
+		c.Transport = http.NewFileTransport(http.Dir("."))
+		return nil
+	}, WithUnixSocket("/tmp/x.sock"))
+	a.True(err != nil, "Non-*http.Transport is rejected")
+}
+
+// fakeResolver maps hosts to IPs without touching DNS.
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	addrs := make([]net.IPAddr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.IPAddr{IP: net.ParseIP(ip)}
+	}
+	return addrs, nil
+}
+
+// countingHandler counts the requests served by h.
+func countingHandler(n *int32, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(n, 1)
+		h.ServeHTTP(w, r)
+	})
+}
+
+func mustCIDR(s string) net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return *n
+}
+
+func TestWithSSRFProtection(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var hits int32
+	server := httptest.NewServer(countingHandler(&hits, OkHandlerFunc))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	resolver := fakeResolver{
+		"internal.example.com": {"93.184.216.34", "10.0.0.1"},
+		"metadata.example.com": {"169.254.169.254"},
+		"ula.example.com":      {"fd00::1"},
+		"api.example.com":      {"127.0.0.1"},
+	}
+
+	cl, err := NewClient(WithResolver(resolver), WithSSRFProtection())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl.TimeoutOnly = false // any other error is retried
+	cl.Backoff = testBackoff
+
+	urls := []string{
+		server.URL, // direct loopback IP
+		"http://internal.example.com:" + port,
+		"http://metadata.example.com/latest/meta-data/",
+		"http://ula.example.com:" + port,
+		"http://[::1]:" + port,
+	}
+	for _, u := range urls {
+		n, _, _, err := cl.DoRequest("GET", u, nil, 3, nil)
+		a.Truef(errors.Is(err, ErrBlockedAddress), "Blocked: %s", u)
+		a.Equalf(0, n, "Never retried: %s", u)
+	}
+	a.Equal(int32(0), atomic.LoadInt32(&hits), "Nothing reaches the server")
+
+	// allowlisted
+	cl, err = NewClient(WithResolver(resolver), WithSSRFProtection(mustCIDR("127.0.0.1/32")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, status, _, err := cl.DoRequest("GET", "http://api.example.com:"+port, nil, 3, nil)
+	a.NoError(err, "Allowlisted address passes")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal(int32(1), atomic.LoadInt32(&hits), "Reaches the server")
+}
+
+func TestWithSSRFProtection_Redirect(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	l, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("Cannot listen on 127.0.0.2: %s", err)
+	}
+	var hits int32
+	private := httptest.NewUnstartedServer(countingHandler(&hits, OkHandlerFunc))
+	private.Listener.Close()
+	private.Listener = l
+	private.Start()
+	defer private.Close()
+
+	public := httptest.NewServer(http.RedirectHandler(private.URL, http.StatusFound))
+	defer public.Close()
+
+	cl, err := NewClient(WithSSRFProtection(mustCIDR("127.0.0.1/32")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, err = cl.DoRequest("GET", public.URL, nil, 3, nil)
+	a.True(errors.Is(err, ErrBlockedAddress), "Redirect into blocked range fails")
+	a.Equal(int32(0), atomic.LoadInt32(&hits), "Redirect target never reached")
+}
+
+func TestWithDialFallback(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(OkHandlerFunc)
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// the first address is black-holed (or unreachable), the second refuses
+	resolver := fakeResolver{"api.example.com": {"2001:db8::1", "127.0.0.2", "127.0.0.1"}}
+	noKeepAlive := func(c *SafeClient) error {
+		c.Transport.(*http.Transport).DisableKeepAlives = true
+		return nil
+	}
+	cl, err := NewClient(WithResolver(resolver), WithDialFallback(100*time.Millisecond, time.Minute), noKeepAlive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, status, _, err := cl.DoRequest("GET", "http://api.example.com:"+port, nil, 1, nil)
+	a.NoError(err, "Falls back to the working address")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.True(time.Since(start) < time.Second, "Bounded by the per-address timeout")
+
+	// failed addresses cool down at the back
+	start = time.Now()
+	_, status, _, err = cl.DoRequest("GET", "http://api.example.com:"+port, nil, 1, nil)
+	a.NoError(err, "Still works")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.True(time.Since(start) < 50*time.Millisecond, "Working address dialed first")
+
+	_, err = NewClient(WithDialFallback(0, 0))
+	a.True(err != nil, "Needs a per-address timeout")
+}
+
+func TestWithHostOverrides(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var host, serverName string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, serverName = r.Host, r.TLS.ServerName
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// trust the test cert, which is for example.com
+	trust := func(c *SafeClient) error {
+		c.Transport = server.Client().Transport.(*http.Transport).Clone()
+		return nil
+	}
+	cl, err := NewClient(trust, WithHostOverrides(map[string]string{
+		"example.com": "127.0.0.1",
+	}))
+	a.NoError(err, "No error")
+
+	u := "https://example.com:" + port + "/"
+	_, status, body, err := cl.DoRequest("GET", u, nil, 1, nil)
+	a.NoError(err, "No error")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal("OK", string(body), "Served via the override")
+	a.Equal("example.com:"+port, host, "Host header kept")
+	a.Equal("example.com", serverName, "SNI kept")
+
+	// host:port takes the address as is
+	cl, _ = NewClient(trust, WithHostOverrides(map[string]string{
+		"example.com:443": server.Listener.Addr().String(),
+	}))
+	_, status, _, err = cl.DoRequest("GET", "https://example.com/", nil, 1, nil)
+	a.NoError(err, "No error")
+	a.Equal(http.StatusOK, status, "Port overridden")
+}
+
+func TestWithPreferIPv6_Fallback(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(OkHandlerFunc)
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	noKeepAlive := func(c *SafeClient) error {
+		c.Transport.(*http.Transport).DisableKeepAlives = true
+		return nil
+	}
+
+	// broken IPv6: the v6 address is black-holed (or unreachable)
+	resolver := fakeResolver{"dual.example.com": {"2001:db8::1", "127.0.0.1"}}
+	cl, err := NewClient(WithResolver(resolver), WithPreferIPv6(), WithFallbackDelay(20*time.Millisecond), noKeepAlive)
+	a.NoError(err, "No error")
+	start := time.Now()
+	_, status, _, err := cl.DoRequest("GET", "http://dual.example.com:"+port, nil, 1, nil)
+	a.NoError(err, "Raced to IPv4")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.True(time.Since(start) < time.Second, "Not waiting for IPv6 to time out")
+
+	cl, _ = NewClient(WithResolver(resolver), WithPreferIPv4(), WithFallbackDelay(10*time.Second), noKeepAlive)
+	start = time.Now()
+	_, status, _, err = cl.DoRequest("GET", "http://dual.example.com:"+port, nil, 1, nil)
+	a.NoError(err, "IPv4 first")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.True(time.Since(start) < time.Second, "No fallback needed")
+
+	// both fail: the error of the preferred is returned as is
+	closed := httptest.NewServer(OkHandlerFunc)
+	closed.Close()
+	_, port, _ = net.SplitHostPort(closed.Listener.Addr().String())
+	cl, _ = NewClient(WithResolver(resolver), WithPreferIPv6(), WithDialFallback(50*time.Millisecond, 0), noKeepAlive)
+	_, _, _, err = cl.DoRequest("GET", "http://dual.example.com:"+port, nil, 1, nil)
+	a.NotNil(err, "Both fail")
+	a.True(strings.Contains(err.Error(), "[2001:db8::1]"), "Error of the preferred")
+}
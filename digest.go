@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+)
+
+// The body digests of WithBodyDigest.
+const (
+	DigestMD5    = "md5"     // sent as Content-MD5
+	DigestSHA256 = "sha-256" // sent as "Digest: sha-256=..."
+)
+
+// WithBodyDigest sends the digest alg of every request body made
+// by DoRequest (and the methods built on it) in a header, computed
+// once for all tries; streamed bodies, e.g., of PostMultipart, have it
+// computed while sent instead, in a trailer. Requests of the caller,
+// e.g., to RequestWithRetry, are left as they are.
+func WithBodyDigest(alg string) Option {
+	return func(c *SafeClient) error {
+		if _, key := newDigest(alg); key == "" {
+			return fmt.Errorf("utils: unknown body digest %q", alg)
+		}
+		c.BodyDigest = alg
+		return nil
+	}
+}
+
+// newDigest returns the hash of alg and the header it goes in.
+func newDigest(alg string) (hash.Hash, string) {
+	switch alg {
+	case DigestMD5:
+		return md5.New(), "Content-Md5"
+	case DigestSHA256:
+		return sha256.New(), "Digest"
+	}
+	return nil, ""
+}
+
+func digestValue(alg string, sum []byte) string {
+	v := base64.StdEncoding.EncodeToString(sum)
+	if alg == DigestMD5 {
+		return v
+	}
+	return alg + "=" + v
+}
+
+// digest returns the header and value of the BodyDigest of content,
+// none if either is empty.
+func (c *SafeClient) digest(content []byte) (string, string) {
+	if c.BodyDigest == "" || len(content) == 0 {
+		return "", ""
+	}
+	h, key := newDigest(c.BodyDigest)
+	h.Write(content)
+	return key, digestValue(c.BodyDigest, h.Sum(nil))
+}
+
+// digestTrailer has the BodyDigest of req.Body computed as it is read
+// and sent in a trailer.
+func (c *SafeClient) digestTrailer(req *http.Request) {
+	if c.BodyDigest == "" || req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	h, key := newDigest(c.BodyDigest)
+	if req.Trailer == nil {
+		req.Trailer = make(http.Header)
+	}
+	// declared now, set at EOF; trailers need chunking
+	req.Trailer[key] = nil
+	req.ContentLength = -1
+	req.Body = &digestBody{req.Body, h, req.Trailer, key, c.BodyDigest}
+}
+
+type digestBody struct {
+	io.ReadCloser
+	h       hash.Hash
+	trailer http.Header
+	key     string
+	alg     string
+}
+
+func (b *digestBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.h.Write(p[:n])
+	if err == io.EOF {
+		b.trailer.Set(b.key, digestValue(b.alg, b.h.Sum(nil)))
+	}
+	return n, err
+}
@@ -0,0 +1,77 @@
+package utils_test
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+// digestHandler answers whether the digest sent, in a header or
+// a trailer, matches the body received.
+var digestHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	data, _ := ioutil.ReadAll(r.Body)
+	md5Sum := md5.Sum(data)
+	shaSum := sha256.Sum256(data)
+	for _, h := range []http.Header{r.Header, r.Trailer} {
+		switch {
+		case h.Get("Content-Md5") != "":
+			if h.Get("Content-Md5") != base64.StdEncoding.EncodeToString(md5Sum[:]) {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			w.Write([]byte("md5"))
+			return
+		case h.Get("Digest") != "":
+			if h.Get("Digest") != "sha-256="+base64.StdEncoding.EncodeToString(shaSum[:]) {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			w.Write([]byte("sha-256"))
+			return
+		}
+	}
+	w.Write([]byte("none"))
+})
+
+func TestWithBodyDigest(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var n int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n++; n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		digestHandler(w, r)
+	}))
+	defer server.Close()
+
+	for _, alg := range []string{DigestMD5, DigestSHA256} {
+		cl, err := NewClient(WithBodyDigest(alg))
+		a.NoError(err, "No error")
+		cl.Backoff = testBackoff
+		n = 0
+		tries, status, body, err := cl.DoRequest("PUT", server.URL, []byte("artifact"), 2, nil)
+		a.NoError(err, "No error")
+		a.Equal(1, tries, "Retried once")
+		a.Equal(http.StatusOK, status, "Digest matches on retry")
+		a.Equal(alg, string(body), "Header sent")
+
+		_, res, err := cl.PostMultipart(context.Background(), server.URL, []Part{FieldPart("a", "b"), streamPart("data", 1<<20, true)}, 2, nil)
+		a.NoError(err, "No error")
+		a.Equal(http.StatusOK, res.Status, "Streamed digest matches")
+		a.Equal(alg, string(res.Body), "Trailer sent")
+
+		_, _, body, _ = cl.DoRequest("GET", server.URL, nil, 1, nil)
+		a.Equal("none", string(body), "No body, no digest")
+	}
+
+	_, err := NewClient(WithBodyDigest("crc32"))
+	a.NotNil(err, "Unknown digest")
+}
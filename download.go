@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ErrChecksumMismatch is matched (errors.Is) by every *ChecksumError.
+var ErrChecksumMismatch = errors.New("utils: checksum mismatch")
+
+// Checksum is the expected digest of a download.
+type Checksum struct {
+	// Algorithm is one of "md5", "sha1", "sha256" and "sha512".
+	Algorithm string
+	// Hex is the hex-encoded digest.
+	Hex string
+}
+
+// ChecksumError reports the digest computed for a download
+// that differs from the expected one.
+type ChecksumError struct {
+	Algorithm string
+	Got, Want string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("utils: %s checksum mismatch: got %s, want %s", e.Algorithm, e.Got, e.Want)
+}
+
+func (e *ChecksumError) Is(target error) bool {
+	return target == ErrChecksumMismatch
+}
+
+func newHash(algorithm string) (hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case "md5":
+		return md5.New(), nil
+	case "sha1", "sha":
+		return sha1.New(), nil
+	case "sha256", "sha-256":
+		return sha256.New(), nil
+	case "sha512", "sha-512":
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("utils: unsupported checksum algorithm %q", algorithm)
+}
+
+// headerChecksum returns the first supported digest announced by
+// the response's Digest (RFC 3230) or Content-MD5 header, or nil.
+func headerChecksum(h http.Header) *Checksum {
+	for _, v := range strings.Split(h.Get("Digest"), ",") {
+		i := strings.Index(v, "=")
+		if i < 0 {
+			continue
+		}
+		alg := strings.TrimSpace(v[:i])
+		if _, err := newHash(alg); err != nil {
+			continue
+		}
+		if sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v[i+1:])); err == nil {
+			return &Checksum{alg, hex.EncodeToString(sum)}
+		}
+	}
+	if v := h.Get("Content-MD5"); v != "" {
+		if sum, err := base64.StdEncoding.DecodeString(v); err == nil {
+			return &Checksum{"md5", hex.EncodeToString(sum)}
+		}
+	}
+	return nil
+}
+
+// download does one GET and streams a 2xx body into the file at path,
+// hashing it on the way; the file is removed if verification fails.
+// Without want, the response's Digest or Content-MD5 is verified if any.
+func (c *SafeClient) download(req *http.Request, path string, want *Checksum) (status int, header http.Header, err error) {
+	resp, err := c.do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	status, header = resp.StatusCode, resp.Header
+	if status < 200 || status > 299 {
+		return
+	}
+
+	if want == nil {
+		want = headerChecksum(resp.Header)
+	}
+	var h hash.Hash
+	if want != nil {
+		if h, err = newHash(want.Algorithm); err != nil {
+			return
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	var w io.Writer = file
+	if h != nil {
+		w = io.MultiWriter(file, h)
+	}
+	_, err = io.Copy(w, resp.Body)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || h == nil {
+		return
+	}
+
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, want.Hex) {
+		os.Remove(path)
+		err = &ChecksumError{want.Algorithm, got, strings.ToLower(want.Hex)}
+	}
+	return
+}
+
+// DownloadWithRetry GETs url into the file at path, retrying like
+// DoRequestContext until ctx is done; the file is truncated at the start
+// of every try.
+// If want is given (or else the server sends a Digest or Content-MD5 header)
+// the content is hashed as it streams, and a mismatch is retried once
+// (the transfer may have been corrupted) before failing with a *ChecksumError.
+func (c *SafeClient) DownloadWithRetry(ctx context.Context, url, path string, maxTries int, f RequestHook, want *Checksum) (tries, status int, err error) {
+	corrupted := false
+
+	tries, err = c.retry(ctx, maxTries, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return Permanent(err)
+		}
+
+		if err = applyHook(f, req); err != nil {
+			return Permanent(err)
+		}
+
+		var header http.Header
+		status, header, err = c.download(req, path, want)
+		if errors.Is(err, ErrChecksumMismatch) {
+			if corrupted {
+				return Permanent(err)
+			}
+			corrupted = true
+			return err
+		}
+		return c.classify(status, header, err)
+	})
+	return
+}
@@ -0,0 +1,149 @@
+package utils_test
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+var artifact = []byte("artifact content v1.0.0")
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// garblingHandler serves artifact, flipping a byte in the first n responses.
+func garblingHandler(n int32, header http.Header) (http.Handler, *int32) {
+	var served int32
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		data := append([]byte{}, artifact...)
+		if atomic.AddInt32(&served, 1) <= n {
+			data[0] ^= 0xff
+		}
+		w.Write(data)
+	}), &served
+}
+
+func TestSafeClient_DownloadWithRetry(t *testing.T) {
+	a := assert.NewAssert(t)
+	path := filepath.Join(t.TempDir(), "artifact")
+
+	h, _ := garblingHandler(0, nil)
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	// match
+	n, status, err := testTimeoutClient.DownloadWithRetry(context.Background(), server.URL, path, 3, nil, &Checksum{"sha256", sha256Hex(artifact)})
+	a.NoError(err, "Checksum matches")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal(0, n, "No retry")
+	data, _ := ioutil.ReadFile(path)
+	a.Equal(artifact, data, "File written")
+
+	// mismatch
+	want := sha256Hex([]byte("something else"))
+	n, _, err = testTimeoutClient.DownloadWithRetry(context.Background(), server.URL, path, 3, nil, &Checksum{"SHA256", want})
+	a.True(errors.Is(err, ErrChecksumMismatch), "Mismatch reported")
+	var ce *ChecksumError
+	if errors.As(err, &ce) {
+		a.Equal(sha256Hex(artifact), ce.Got, "Reports got")
+		a.Equal(want, ce.Want, "Reports want")
+	} else {
+		t.Error("Should be a *ChecksumError")
+	}
+	a.Equal(1, n, "Retried once only")
+	_, err = os.Stat(path)
+	a.True(os.IsNotExist(err), "Corrupted file removed")
+
+	// unknown algorithm
+	_, _, err = testTimeoutClient.DownloadWithRetry(context.Background(), server.URL, path, 3, nil, &Checksum{"crc32", "00"})
+	a.True(err != nil && !errors.Is(err, ErrChecksumMismatch), "Unsupported algorithm")
+}
+
+func TestSafeClient_DownloadWithRetry_Corruption(t *testing.T) {
+	a := assert.NewAssert(t)
+	path := filepath.Join(t.TempDir(), "artifact")
+
+	h, served := garblingHandler(1, nil)
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	n, _, err := testTimeoutClient.DownloadWithRetry(context.Background(), server.URL, path, 3, nil, &Checksum{"sha256", sha256Hex(artifact)})
+	a.NoError(err, "Second transfer is intact")
+	a.Equal(1, n, "Retried after corruption")
+	a.Equal(int32(2), atomic.LoadInt32(served), "Served twice")
+	data, _ := ioutil.ReadFile(path)
+	a.Equal(artifact, data, "File truncated and rewritten")
+
+	// corrupted twice
+	h, _ = garblingHandler(2, nil)
+	server2 := httptest.NewServer(h)
+	defer server2.Close()
+	n, _, err = testTimeoutClient.DownloadWithRetry(context.Background(), server2.URL, path, 5, nil, &Checksum{"sha256", sha256Hex(artifact)})
+	a.True(errors.Is(err, ErrChecksumMismatch), "Fails on second mismatch")
+	a.Equal(1, n, "No more retries")
+}
+
+func TestSafeClient_DownloadWithRetry_Header(t *testing.T) {
+	a := assert.NewAssert(t)
+	path := filepath.Join(t.TempDir(), "artifact")
+
+	sha := sha256.Sum256(artifact)
+	md := md5.Sum(artifact)
+	headers := []http.Header{
+		{"Digest": {"unixsum=30637, sha-256=" + base64.StdEncoding.EncodeToString(sha[:])}},
+		{"Content-Md5": {base64.StdEncoding.EncodeToString(md[:])}},
+	}
+	for _, header := range headers {
+		h, _ := garblingHandler(0, header)
+		server := httptest.NewServer(h)
+		_, _, err := testTimeoutClient.DownloadWithRetry(context.Background(), server.URL, path, 3, nil, nil)
+		a.NoError(err, "Header digest verified")
+		server.Close()
+
+		h, _ = garblingHandler(3, header)
+		server = httptest.NewServer(h)
+		_, _, err = testTimeoutClient.DownloadWithRetry(context.Background(), server.URL, path, 3, nil, nil)
+		a.True(errors.Is(err, ErrChecksumMismatch), "Header digest mismatch")
+		server.Close()
+	}
+
+	// 5xx never writes
+	server := httptest.NewServer(Status5xxHandlerFunc)
+	defer server.Close()
+	n, status, err := testTimeoutClient.DownloadWithRetry(context.Background(), server.URL, path, 2, nil, nil)
+	a.NoError(err, "No transport error")
+	a.Equal(http.StatusInternalServerError, status, "Returns code")
+	a.Equal(1, n, "Retried")
+}
+
+func TestSafeClient_DownloadWithRetry_Context(t *testing.T) {
+	a := assert.NewAssert(t)
+	path := filepath.Join(t.TempDir(), "artifact")
+
+	server := httptest.NewServer(OkHandlerFunc)
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := testTimeoutClient.DownloadWithRetry(ctx, server.URL, path, 3, nil, nil)
+	a.True(errors.Is(err, context.Canceled), "Cancelled")
+	_, err = os.Stat(path)
+	a.True(os.IsNotExist(err), "Nothing written")
+}
@@ -0,0 +1,40 @@
+package utils
+
+import "errors"
+
+// ErrFallback is returned, along with the body and status made up by
+// the client's Fallback, by the methods that return no Response,
+// so that their callers can tell a fallback from a real response.
+var ErrFallback = errors.New("utils: response made up by Fallback")
+
+// FallbackFunc makes up the body and status of a call that failed
+// its last try with lastStatus (0 if no response) or lastErr,
+// e.g., from a cache; ok false leaves the failure as is.
+type FallbackFunc func(method, url string, lastStatus int, lastErr error) (body []byte, status int, ok bool)
+
+// WithFallback consults fn for every call of DoRequest and the methods
+// built on it whose tries ran out on a retryable failure, never on
+// success or a permanent one (nor if the context ended the retries);
+// the Response it makes, returned with no error by Send and
+// PostMultipart, has Fallback set. DoRequest, DoRequestContext and the
+// JSON and codec helpers return its body and status with ErrFallback
+// instead, the latter after decoding the body into out.
+func WithFallback(fn FallbackFunc) Option {
+	return func(c *SafeClient) error {
+		c.Fallback = fn
+		return nil
+	}
+}
+
+// fallback returns what Fallback makes of the last response
+// and error of a call, or them as they are.
+func (c *SafeClient) fallback(method, url string, res Response, err error) (Response, error) {
+	if c.Fallback == nil {
+		return res, err
+	}
+	body, status, ok := c.Fallback(method, url, res.Status, err)
+	if !ok {
+		return res, err
+	}
+	return Response{Status: status, Body: body, Fallback: true}, nil
+}
@@ -0,0 +1,91 @@
+package utils_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestWithFallback(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	dead := httptest.NewServer(OkHandlerFunc)
+	dead.Close()
+
+	var calls int
+	var lastErr error
+	cl, err := NewClient(WithFallback(func(method, url string, lastStatus int, err error) ([]byte, int, bool) {
+		calls++
+		lastErr = err
+		return []byte(`{"data":"cached"}`), http.StatusOK, true
+	}))
+	a.NoError(err, "No error")
+	cl.TimeoutOnly = false
+	cl.Backoff = testBackoff
+
+	tries, res, err := cl.Send(context.Background(), "GET", dead.URL, nil, 3, nil)
+	a.NoError(err, "Failure replaced")
+	a.Equal(2, tries, "Tries ran out first")
+	a.True(res.Fallback, "Flagged")
+	a.Equal(http.StatusOK, res.Status, "Returns code")
+	a.Equal(`{"data":"cached"}`, string(res.Body), "Canned body")
+	a.Equal(1, calls, "Consulted once")
+	a.NotNil(lastErr, "Told the last error")
+
+	var out testContent
+	_, status, err := cl.GetJSON(context.Background(), dead.URL, &out, 1, nil)
+	a.ErrorIs(err, ErrFallback, "Flagged without a Response")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal("cached", out.Data, "Decoded fallback")
+
+	_, status, body, err := cl.DoRequest("GET", dead.URL, nil, 1, nil)
+	a.ErrorIs(err, ErrFallback, "Flagged without a Response")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal(`{"data":"cached"}`, string(body), "Canned body")
+
+	out = testContent{}
+	_, _, err = cl.PostJSON(context.Background(), dead.URL, testContent{"in"}, &out, 1, nil)
+	a.ErrorIs(err, ErrFallback, "Flagged without a Response")
+	a.Equal("cached", out.Data, "Decoded fallback")
+}
+
+func TestWithFallback_NotConsulted(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	ok := httptest.NewServer(OkHandlerFunc)
+	defer ok.Close()
+	unavailable := httptest.NewServer(Status5xxHandlerFunc)
+	defer unavailable.Close()
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+
+	var statuses []int
+	cl, _ := NewClient(WithFallback(func(method, url string, lastStatus int, err error) ([]byte, int, bool) {
+		statuses = append(statuses, lastStatus)
+		return nil, 0, lastStatus != 0
+	}))
+	cl.Backoff = testBackoff
+
+	_, res, err := cl.Send(context.Background(), "GET", ok.URL, nil, 3, nil)
+	a.NoError(err, "No error")
+	a.True(!res.Fallback, "Real response")
+	_, res, _ = cl.Send(context.Background(), "GET", notFound.URL, nil, 3, nil)
+	a.Equal(http.StatusNotFound, res.Status, "Final status kept")
+	a.Equal(0, len(statuses), "Not on success or a final status")
+
+	// a non-retryable error exits early
+	bad := httptest.NewServer(OkHandlerFunc)
+	bad.Close()
+	_, _, err = cl.Send(context.Background(), "GET", bad.URL, nil, 3, nil)
+	a.NotNil(err, "Error kept")
+	a.Equal(0, len(statuses), "Not on a permanent error")
+
+	_, res, err = cl.Send(context.Background(), "GET", unavailable.URL, nil, 2, nil)
+	a.NoError(err, "No error")
+	a.True(res.Fallback, "Should-retry status replaced")
+	a.Equal([]int{http.StatusInternalServerError}, statuses, "Told the last status")
+}
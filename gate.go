@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+// Gate is a valve its waiters pass while open, e.g., to pause workers
+// during a migration; unlike Semaphore's, its Close can be undone by
+// Open. A worker passes the Gate before obtaining from a Semaphore.
+// It is safe for concurrent use.
+type Gate struct {
+	mu     sync.Mutex
+	closed chan struct{} // closed by Open, nil while open
+}
+
+// NewGate returns a Gate, open or not.
+func NewGate(open bool) *Gate {
+	g := &Gate{}
+	if !open {
+		g.closed = make(chan struct{})
+	}
+	return g
+}
+
+// Wait returns at once if g is open, else blocks until it is opened
+// or the context cancelled, returning its error then.
+func (g *Gate) Wait(ctx context.Context) error {
+	g.mu.Lock()
+	ch := g.closed
+	g.mu.Unlock()
+	if ch == nil {
+		return nil
+	}
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Open opens g, letting all its waiters through at once.
+func (g *Gate) Open() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed != nil {
+		close(g.closed)
+		g.closed = nil
+	}
+}
+
+// Close closes g, blocking the Waits after.
+func (g *Gate) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed == nil {
+		g.closed = make(chan struct{})
+	}
+}
+
+// IsOpen tells if g is open.
+func (g *Gate) IsOpen() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.closed == nil
+}
@@ -0,0 +1,71 @@
+package utils_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestGate(t *testing.T) {
+	a := assert.NewAssert(t)
+	const m = 10 // waiters
+	bc := context.Background()
+
+	g := NewGate(true)
+	a.True(g.IsOpen(), "Open")
+	a.NoError(g.Wait(bc), "Passes at once")
+
+	g.Close()
+	g.Close()
+	a.True(!g.IsOpen(), "Closed")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	passed := 0
+	for i := 0; i < m; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if g.Wait(bc) == nil {
+				mu.Lock()
+				passed++
+				mu.Unlock()
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	mu.Lock()
+	a.Equal(0, passed, "Parked while closed")
+	mu.Unlock()
+
+	g.Open()
+	wg.Wait()
+	a.Equal(m, passed, "All proceed once open")
+	a.True(g.IsOpen(), "Open again")
+	g.Open()
+	a.NoError(g.Wait(bc), "Passes at once")
+}
+
+func TestGate_Cancel(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	g := NewGate(false)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	a.Equal(context.DeadlineExceeded, g.Wait(ctx), "Cancelled while parked")
+
+	// with a semaphore, gate first
+	sema := NewSemaphore(1)
+	done := make(chan bool)
+	go func() {
+		done <- g.Wait(context.Background()) == nil && sema.Obtain(context.Background())
+	}()
+	time.Sleep(5 * time.Millisecond)
+	a.Equal(0, sema.Count(), "Not obtained while parked")
+	g.Open()
+	a.True(<-done, "Obtained after the gate")
+}
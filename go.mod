@@ -0,0 +1,3 @@
+module github.com/ShevaXu/web-utils
+
+go 1.24
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// DefaultGraphQLTries is used if SafeClient.GraphQLTries is unset.
+const DefaultGraphQLTries = 3
+
+// GraphQLError is one entry of the errors of a GraphQL response.
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"` // field names and list indices
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// GraphQLErrors is the error for a GraphQL response with errors;
+// it comes along with whatever data could be decoded.
+type GraphQLErrors []GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Message
+	}
+	return "utils: graphql: " + strings.Join(msgs, "; ")
+}
+
+// graphQLResponse is the envelope of a GraphQL response.
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors GraphQLErrors   `json:"errors"`
+}
+
+// GraphQL POSTs query and variables to a GraphQL endpoint and decodes
+// the data of the response into out (if not nil). A non-empty errors array
+// is returned as GraphQLErrors, also for a partial response (data is
+// decoded still) and for a non-2xx response carrying one; such errors
+// are not retried, whereas request errors and should-retry statuses are,
+// up to GraphQLTries.
+func (c *SafeClient) GraphQL(ctx context.Context, url, query string, variables map[string]interface{}, out interface{}, f RequestHook) (tries, status int, err error) {
+	maxTries := c.GraphQLTries
+	if maxTries <= 0 {
+		maxTries = DefaultGraphQLTries
+	}
+	in := struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables,omitempty"`
+	}{query, variables}
+
+	var res graphQLResponse
+	tries, status, err = c.PostJSON(ctx, url, in, &res, maxTries, f)
+	if herr, ok := err.(*HTTPError); ok {
+		// the errors of a non-2xx response, if any, tell more
+		if json.Unmarshal(herr.Body, &res) == nil && len(res.Errors) > 0 {
+			err = res.Errors
+		}
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	if out != nil && len(res.Data) > 0 && !bytes.Equal(res.Data, []byte("null")) {
+		if err = json.Unmarshal(res.Data, out); err != nil {
+			return
+		}
+	}
+	if len(res.Errors) > 0 {
+		err = res.Errors
+	}
+	return
+}
@@ -0,0 +1,82 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestSafeClient_GraphQL(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var calls int32
+	var got struct {
+		Query     string
+		Variables map[string]interface{}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		json.NewDecoder(r.Body).Decode(&got)
+		switch r.URL.Path {
+		case "/data":
+			w.Write([]byte(`{"data":{"user":{"name":"foo"}}}`))
+		case "/errors":
+			w.Write([]byte(`{"data":null,"errors":[{"message":"not found","path":["user"],"extensions":{"code":"NOT_FOUND"}}]}`))
+		case "/partial":
+			w.Write([]byte(`{"data":{"user":{"name":"foo"}},"errors":[{"message":"no email","path":["user","emails",0]}]}`))
+		case "/bad":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"message":"syntax error"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	type user struct {
+		User struct{ Name string }
+	}
+	ctx := context.Background()
+	vars := map[string]interface{}{"id": "1"}
+
+	var out user
+	_, status, err := testTimeoutClient.GraphQL(ctx, server.URL+"/data", "query($id: ID!) { user(id: $id) { name } }", vars, &out, nil)
+	a.NoError(err, "Data only")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal("foo", out.User.Name, "Data decoded")
+	a.Equal("query($id: ID!) { user(id: $id) { name } }", got.Query, "Query sent")
+	a.Equal(vars, got.Variables, "Variables sent")
+
+	atomic.StoreInt32(&calls, 0)
+	out = user{}
+	tries, _, err := testTimeoutClient.GraphQL(ctx, server.URL+"/errors", "{ user { name } }", nil, &out, nil)
+	gerrs, ok := err.(GraphQLErrors)
+	a.True(ok, "Errors only")
+	a.Equal(0, tries, "Not retried")
+	a.Equal(int32(1), atomic.LoadInt32(&calls), "Sent once")
+	if ok {
+		a.Equal("not found", gerrs[0].Message, "Message")
+		a.Equal([]interface{}{"user"}, gerrs[0].Path, "Path")
+		a.Equal("NOT_FOUND", gerrs[0].Extensions["code"], "Extensions")
+	}
+	a.Equal("", out.User.Name, "No data")
+
+	out = user{}
+	_, _, err = testTimeoutClient.GraphQL(ctx, server.URL+"/partial", "{ user { name emails } }", nil, &out, nil)
+	gerrs, ok = err.(GraphQLErrors)
+	a.True(ok, "Partial")
+	a.Equal("foo", out.User.Name, "Partial data decoded")
+	if ok {
+		a.Equal([]interface{}{"user", "emails", float64(0)}, gerrs[0].Path, "Path with index")
+		a.Equal("utils: graphql: no email", err.Error(), "Error message")
+	}
+
+	_, status, err = testTimeoutClient.GraphQL(ctx, server.URL+"/bad", "{", nil, nil, nil)
+	_, ok = err.(GraphQLErrors)
+	a.True(ok, "Errors of a non-2xx response")
+	a.Equal(http.StatusBadRequest, status, "Returns code")
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Group runs functions concurrently, up to a limit, like errgroup:
+// the first to fail cancels the context the others are given,
+// and Wait returns its error.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	sem    Semaphore // nil for no limit
+
+	wg   sync.WaitGroup
+	once sync.Once
+	err  error
+}
+
+// NewGroup returns a Group running up to limit functions at once,
+// no limit if limit <= 0, with a context derived from ctx.
+func NewGroup(ctx context.Context, limit int) *Group {
+	g := &Group{}
+	g.ctx, g.cancel = context.WithCancel(ctx)
+	if limit > 0 {
+		g.sem = NewSemaphore(limit)
+	}
+	return g
+}
+
+// Go runs fn in a goroutine, blocking until there is room under
+// the limit; fn is not run, failing with the error of the context,
+// if it is done first. A panic of fn fails it with an error.
+func (g *Group) Go(fn func(ctx context.Context) error) {
+	if err := g.ctx.Err(); err != nil {
+		g.fail(err)
+		return
+	}
+	if g.sem != nil {
+		if err := g.sem.ObtainErr(g.ctx); err != nil {
+			g.fail(err)
+			return
+		}
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if g.sem != nil {
+			defer g.sem.Release()
+		}
+		if err := g.run(fn); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+func (g *Group) run(fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("utils: group function panicked: %v", r)
+		}
+	}()
+	return fn(g.ctx)
+}
+
+// fail keeps the first error and cancels the context.
+func (g *Group) fail(err error) {
+	g.once.Do(func() {
+		g.err = err
+		g.cancel()
+	})
+}
+
+// Wait blocks until all the functions run return,
+// returning the first error if any.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	return g.err
+}
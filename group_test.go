@@ -0,0 +1,86 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestGroup(t *testing.T) {
+	a := assert.NewAssert(t)
+	const limit = 3
+	const m = 20 // functions
+
+	a.NoError(NewGroup(context.Background(), limit).Wait(), "Nothing to wait for")
+
+	g := NewGroup(context.Background(), limit)
+	var running, maxRunning, ran int32
+	for i := 0; i < m; i++ {
+		g.Go(func(ctx context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			atomic.AddInt32(&ran, 1)
+			return nil
+		})
+	}
+	a.NoError(g.Wait(), "All succeeded")
+	a.Equal(int32(m), ran, "All ran")
+	a.Equal(int32(limit), maxRunning, "Bounded by limit")
+}
+
+func TestGroup_Error(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	g := NewGroup(context.Background(), 0)
+	errFn := errors.New("fn failed")
+	cancelled := make(chan bool)
+	g.Go(func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			cancelled <- true
+		case <-time.After(time.Second):
+			cancelled <- false
+		}
+		return ctx.Err()
+	})
+	g.Go(func(ctx context.Context) error {
+		time.Sleep(5 * time.Millisecond)
+		return errFn
+	})
+	a.True(<-cancelled, "Others cancelled")
+	a.Equal(errFn, g.Wait(), "First error")
+
+	g = NewGroup(context.Background(), 1)
+	g.Go(func(ctx context.Context) error {
+		panic("boom")
+	})
+	err := g.Wait()
+	a.True(err != nil && strings.Contains(err.Error(), "boom"), "Panic as error")
+	g.Go(func(ctx context.Context) error {
+		t.Error("Run after failed")
+		return nil
+	})
+	a.Equal(err, g.Wait(), "Still the first error")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	g = NewGroup(ctx, 1)
+	g.Go(func(ctx context.Context) error {
+		t.Error("Run after cancelled")
+		return nil
+	})
+	a.Equal(context.Canceled, g.Wait(), "Parent cancelled")
+}
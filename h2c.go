@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// ErrH2CProxy is returned by WithH2C for a transport with a proxy,
+// which cannot carry HTTP/2 with prior knowledge.
+var ErrH2CProxy = errors.New("utils: h2c cannot go through a proxy")
+
+// WithH2C makes the client speak HTTP/2 with prior knowledge over cleartext
+// (h2c) to http:// URLs, e.g., for gRPC-gateway services; https:// URLs
+// negotiate HTTP/2 by ALPN as usual, falling back to HTTP/1.1.
+// Timeouts and retries behave the same as over HTTP/1.1.
+// The environment proxy of the default transport is dropped; a transport
+// set with its own Proxy fails with ErrH2CProxy.
+func WithH2C() Option {
+	return func(c *SafeClient) error {
+		t, err := c.transport()
+		if err != nil {
+			return err
+		}
+		if t.Proxy != nil {
+			if t != c.cloned {
+				return ErrH2CProxy
+			}
+			t.Proxy = nil
+		}
+		p := new(http.Protocols)
+		p.SetHTTP1(true)
+		p.SetHTTP2(true)
+		t.Protocols = p
+		// a transport with HTTP1 speaks it to http:// URLs, so they go
+		// to one without, under the wrappers of other options
+		c.h2c = &h2cTransport{base: t}
+		t.RegisterProtocol("http", c.h2c)
+		return nil
+	}
+}
+
+// h2cTransport speaks h2c through a clone of base, made on first use
+// to have what later options set on base.
+type h2cTransport struct {
+	base *http.Transport
+
+	mu  sync.Mutex
+	h2c *http.Transport
+}
+
+func (t *h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	if t.h2c == nil {
+		t.h2c = t.base.Clone()
+		t.h2c.Protocols = new(http.Protocols)
+		t.h2c.Protocols.SetUnencryptedHTTP2(true)
+	}
+	h2c := t.h2c
+	t.mu.Unlock()
+	return h2c.RoundTrip(req)
+}
+
+// CloseIdleConnections closes those of the clone, which base,
+// having it as a registered protocol, does not.
+func (t *h2cTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	h2c := t.h2c
+	t.mu.Unlock()
+	if h2c != nil {
+		h2c.CloseIdleConnections()
+	}
+}
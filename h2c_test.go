@@ -0,0 +1,114 @@
+package utils_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestWithH2C(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var tries int
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tries++
+		if tries == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(r.Proto))
+	}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	cl, err := NewClient(WithH2C())
+	a.NoError(err, "No error")
+	cl.Backoff = testBackoff
+	n, status, body, err := cl.DoRequest("GET", server.URL, nil, 3, nil)
+	a.NoError(err, "No error")
+	a.Equal(1, n, "Retried over h2c")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal("HTTP/2.0", string(body), "Prior knowledge")
+
+	// timeouts still classified
+	slow := httptest.NewUnstartedServer(TimeoutHandlerFunc)
+	slow.Config.Protocols = server.Config.Protocols
+	slow.Start()
+	defer slow.Close()
+	cl.Timeout = time.Duration(minTimeout) * time.Millisecond
+	_, _, _, err = cl.DoRequest("GET", slow.URL, nil, 1, nil)
+	a.True(IsTimeoutErr(err), "Timeout error")
+}
+
+func TestWithH2C_CloseIdleConnections(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var conns int32
+	server := httptest.NewUnstartedServer(OkHandlerFunc)
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Config.ConnState = func(_ net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	cl, _ := NewClient(WithH2C(), WithRecorder(&Recorder{}))
+	cl.DoRequest("GET", server.URL, nil, 1, nil)
+	cl.DoRequest("GET", server.URL, nil, 1, nil)
+	a.Equal(int32(1), atomic.LoadInt32(&conns), "Connection reused")
+	cl.CloseIdleConnections()
+	cl.DoRequest("GET", server.URL, nil, 1, nil)
+	a.Equal(int32(2), atomic.LoadInt32(&conns), "Idle one closed under the wrappers")
+}
+
+func TestWithH2C_HTTPS(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	for _, c := range []struct {
+		name  string
+		http2 bool
+		proto string
+	}{
+		{"HTTP/1.1 only", false, "HTTP/1.1"},
+		{"HTTP/2 by ALPN", true, "HTTP/2.0"},
+	} {
+		server := httptest.NewUnstartedServer(handler)
+		server.EnableHTTP2 = c.http2
+		server.StartTLS()
+
+		cl, err := NewClient(WithH2C())
+		a.NoError(err, "No error")
+		cl.Transport.(*http.Transport).TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+		_, status, body, err := cl.DoRequest("GET", server.URL, nil, 1, nil)
+		a.NoErrorf(err, "%s: no error", c.name)
+		a.Equalf(http.StatusOK, status, "%s: returns code", c.name)
+		a.Equalf(c.proto, string(body), "%s: protocol", c.name)
+		server.Close()
+	}
+}
+
+func TestWithH2C_Proxy(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	proxy, _ := url.Parse("http://127.0.0.1:3128")
+	_, err := NewClient(func(c *SafeClient) error {
+		c.Transport = &http.Transport{Proxy: http.ProxyURL(proxy)}
+		return nil
+	}, WithH2C())
+	a.Equal(ErrH2CProxy, err, "Proxy conflicts")
+}
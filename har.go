@@ -0,0 +1,344 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultMaxRecordedBody caps each body kept by a Recorder
+// whose MaxBodyBytes is unset.
+const DefaultMaxRecordedBody = 64 << 10
+
+// Recorder captures every exchange sent through the client it is
+// installed in by WithRecorder, one per try, for export by WriteHAR;
+// it is safe for concurrent use.
+type Recorder struct {
+	// MaxBodyBytes caps each recorded body, DefaultMaxRecordedBody
+	// if not positive; negative records no bodies.
+	MaxBodyBytes int
+
+	mu      sync.Mutex
+	entries []*harEntry
+}
+
+// WithRecorder installs r around the client's transport.
+func WithRecorder(r *Recorder) Option {
+	return func(c *SafeClient) error {
+		if _, err := c.transport(); err != nil {
+			return err
+		}
+		c.Transport = &recordingTransport{c.Transport, r}
+		return nil
+	}
+}
+
+// Reset drops the exchanges recorded so far.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.entries = nil
+	r.mu.Unlock()
+}
+
+// WriteHAR writes the recorded exchanges as a HAR 1.2 log in JSON.
+// A try that got no response has status 0 and its error in "_error";
+// bodies that are not UTF-8 text are base64-encoded. A request body
+// is read from GetBody, and omitted if the request has none.
+func (r *Recorder) WriteHAR(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := r.entries
+	if entries == nil {
+		entries = []*harEntry{}
+	}
+	var log struct {
+		Log harLog `json:"log"`
+	}
+	log.Log = harLog{"1.2", harCreator{"web-utils", "1.0"}, entries}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+func (r *Recorder) maxBody() int {
+	if r.MaxBodyBytes == 0 {
+		return DefaultMaxRecordedBody
+	}
+	return r.MaxBodyBytes
+}
+
+// the subset of HAR 1.2 written
+type harLog struct {
+	Version string      `json:"version"`
+	Creator harCreator  `json:"creator"`
+	Entries []*harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Error           string      `json:"_error,omitempty"`
+}
+
+type harRequest struct {
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	HTTPVersion string       `json:"httpVersion"`
+	Cookies     []harNV      `json:"cookies"`
+	Headers     []harNV      `json:"headers"`
+	QueryString []harNV      `json:"queryString"`
+	PostData    *harPostData `json:"postData,omitempty"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int          `json:"bodySize"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"_encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harResponse struct {
+	Status      int        `json:"status"`
+	StatusText  string     `json:"statusText"`
+	HTTPVersion string     `json:"httpVersion"`
+	Cookies     []harNV    `json:"cookies"`
+	Headers     []harNV    `json:"headers"`
+	Content     harContent `json:"content"`
+	RedirectURL string     `json:"redirectURL"`
+	HeadersSize int        `json:"headersSize"`
+	BodySize    int        `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+type harNV struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func harHeaders(h http.Header) []harNV {
+	nvs := []harNV{}
+	for k, vs := range h {
+		for _, v := range vs {
+			nvs = append(nvs, harNV{k, v})
+		}
+	}
+	return nvs
+}
+
+func harCookies(cookies []*http.Cookie) []harNV {
+	nvs := []harNV{}
+	for _, c := range cookies {
+		nvs = append(nvs, harNV{c.Name, c.Value})
+	}
+	return nvs
+}
+
+// harText returns data as HAR text and its encoding.
+func harText(data []byte) (string, string) {
+	if utf8.Valid(data) {
+		return string(data), ""
+	}
+	return base64.StdEncoding.EncodeToString(data), "base64"
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// recordingTransport is the RoundTripper installed by WithRecorder.
+type recordingTransport struct {
+	base http.RoundTripper
+	rec  *Recorder
+}
+
+func (t *recordingTransport) Unwrap() http.RoundTripper {
+	return t.base
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	e := &harEntry{
+		StartedDateTime: start.Format("2006-01-02T15:04:05.000Z07:00"),
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: req.Proto,
+			Cookies:     harCookies(req.Cookies()),
+			Headers:     harHeaders(req.Header),
+			QueryString: []harNV{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Response: harResponse{Cookies: []harNV{}, Headers: []harNV{}, HeadersSize: -1, BodySize: -1},
+	}
+	for k, vs := range req.URL.Query() {
+		for _, v := range vs {
+			e.Request.QueryString = append(e.Request.QueryString, harNV{k, v})
+		}
+	}
+	if e.Request.HTTPVersion == "" {
+		e.Request.HTTPVersion = "HTTP/1.1"
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		e.Request.BodySize = -1
+		if req.ContentLength > 0 {
+			e.Request.BodySize = int(req.ContentLength)
+		}
+		if max := t.rec.maxBody(); max >= 0 {
+			var size int
+			if e.Request.PostData, size = postData(req, max); size >= 0 {
+				e.Request.BodySize = size
+			}
+		}
+	} else {
+		e.Request.BodySize = 0
+	}
+
+	t.rec.mu.Lock()
+	t.rec.entries = append(t.rec.entries, e)
+	t.rec.mu.Unlock()
+
+	resp, err := t.base.RoundTrip(req)
+	wait := time.Since(start)
+	if err != nil {
+		t.rec.mu.Lock()
+		e.Time = millis(wait)
+		e.Timings.Wait = e.Time
+		e.Error = err.Error()
+		t.rec.mu.Unlock()
+		return nil, err
+	}
+
+	t.rec.mu.Lock()
+	e.Response.Status = resp.StatusCode
+	e.Response.StatusText = http.StatusText(resp.StatusCode)
+	e.Response.HTTPVersion = resp.Proto
+	e.Response.Cookies = harCookies(resp.Cookies())
+	e.Response.Headers = harHeaders(resp.Header)
+	e.Response.RedirectURL = resp.Header.Get("Location")
+	e.Response.Content.MimeType = resp.Header.Get("Content-Type")
+	e.Time = millis(wait)
+	e.Timings.Wait = e.Time
+	t.rec.mu.Unlock()
+
+	resp.Body = &recordedBody{ReadCloser: resp.Body, rec: t.rec, e: e, start: start, wait: wait, max: t.rec.maxBody()}
+	return resp, nil
+}
+
+// postData records up to max bytes of the body of req, read from
+// a copy by GetBody to leave req.Body to be sent, and the size of the
+// body if read whole, or -1. Without GetBody, req is left untouched
+// and the body omitted.
+func postData(req *http.Request, max int) (pd *harPostData, size int) {
+	pd = &harPostData{MimeType: req.Header.Get("Content-Type")}
+	if req.GetBody == nil {
+		pd.Comment = "omitted"
+		return pd, -1
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		pd.Comment = "omitted: " + err.Error()
+		return pd, -1
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(body, int64(max)+1))
+	if err != nil {
+		pd.Comment = "omitted: " + err.Error()
+		return pd, -1
+	}
+	size = len(data)
+	if size > max {
+		data, size = data[:max], -1
+		pd.Comment = "truncated"
+	}
+	pd.Text, pd.Encoding = harText(data)
+	return pd, size
+}
+
+// recordedBody keeps up to max bytes of a response body as it is
+// read and completes its entry on EOF or Close.
+type recordedBody struct {
+	io.ReadCloser
+	rec   *Recorder
+	e     *harEntry
+	start time.Time
+	wait  time.Duration
+	max   int
+
+	buf  bytes.Buffer
+	size int
+	done bool
+}
+
+func (b *recordedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += n
+	if keep := b.max - b.buf.Len(); keep > 0 {
+		if keep > n {
+			keep = n
+		}
+		b.buf.Write(p[:keep])
+	}
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *recordedBody) Close() error {
+	b.finish()
+	return b.ReadCloser.Close()
+}
+
+func (b *recordedBody) finish() {
+	if b.done {
+		return
+	}
+	b.done = true
+	elapsed := time.Since(b.start)
+
+	b.rec.mu.Lock()
+	defer b.rec.mu.Unlock()
+	c := &b.e.Response.Content
+	c.Size = b.size
+	if b.max >= 0 {
+		c.Text, c.Encoding = harText(b.buf.Bytes())
+		if b.size > b.buf.Len() {
+			c.Comment = "truncated"
+		}
+	}
+	b.e.Response.BodySize = b.size
+	b.e.Time = millis(elapsed)
+	b.e.Timings.Receive = millis(elapsed - b.wait)
+}
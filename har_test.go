@@ -0,0 +1,178 @@
+package utils_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+// requireKeys checks the keys HAR 1.2 requires of an object.
+func requireKeys(a *assert.Assert, obj interface{}, what string, keys ...string) map[string]interface{} {
+	m, ok := obj.(map[string]interface{})
+	a.True(ok, what+" is an object")
+	for _, k := range keys {
+		_, ok := m[k]
+		a.Truef(ok, "%s has %s", what, k)
+	}
+	return m
+}
+
+func TestRecorder_WriteHAR(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	binary := []byte{0xff, 0xfe, 0x00, 0x01}
+	var n int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(binary)
+	}))
+	defer server.Close()
+
+	rec := &Recorder{}
+	cl, err := NewClient(WithRecorder(rec))
+	a.NoError(err, "No error")
+	cl.Backoff = testBackoff
+	before := time.Now()
+	tries, status, body, err := cl.PostJSONWithRetry(server.URL+"?q=1", testContent{"hello"}, 3, nil)
+	a.NoError(err, "No error")
+	a.Equal(1, tries, "Retried once")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal(binary, body, "Body still returned")
+
+	var buf bytes.Buffer
+	a.NoError(rec.WriteHAR(&buf), "Written")
+	var har map[string]interface{}
+	if !a.NoError(json.Unmarshal(buf.Bytes(), &har), "Valid JSON") {
+		return
+	}
+
+	log := requireKeys(a, har["log"], "log", "version", "creator", "entries")
+	a.Equal("1.2", log["version"], "HAR 1.2")
+	entries := log["entries"].([]interface{})
+	if !a.Equal(2, len(entries), "An entry per try") {
+		return
+	}
+
+	for i, st := range []float64{502, 200} {
+		e := requireKeys(a, entries[i], "entry", "startedDateTime", "time", "request", "response", "cache", "timings")
+		started, err := time.Parse(time.RFC3339Nano, e["startedDateTime"].(string))
+		a.NoError(err, "ISO 8601 timestamp")
+		a.True(!started.Before(before.Truncate(time.Millisecond)), "Timestamped")
+
+		req := requireKeys(a, e["request"], "request", "method", "url", "httpVersion", "cookies", "headers", "queryString", "headersSize", "bodySize")
+		a.Equal("POST", req["method"], "Method")
+		a.Equal([]interface{}{map[string]interface{}{"name": "q", "value": "1"}}, req["queryString"], "Query")
+		a.Equal(`{"data":"hello"}`, req["postData"].(map[string]interface{})["text"], "Request body")
+
+		resp := requireKeys(a, e["response"], "response", "status", "statusText", "httpVersion", "cookies", "headers", "content", "redirectURL", "headersSize", "bodySize")
+		a.Equal(st, resp["status"], "Status")
+		requireKeys(a, resp["content"], "content", "size", "mimeType")
+		requireKeys(a, e["timings"], "timings", "send", "wait", "receive")
+	}
+
+	content := entries[1].(map[string]interface{})["response"].(map[string]interface{})["content"].(map[string]interface{})
+	a.Equal("base64", content["encoding"], "Binary body encoded")
+	a.Equal(base64.StdEncoding.EncodeToString(binary), content["text"], "Binary body")
+	a.Equal(float64(len(binary)), content["size"], "Body size")
+}
+
+func TestRecorder_MaxBodyBytes(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(OkHandlerFunc)
+	defer server.Close()
+
+	rec := &Recorder{MaxBodyBytes: 1}
+	cl, _ := NewClient(WithRecorder(rec))
+	_, _, body, _ := cl.DoRequest("GET", server.URL, nil, 1, nil)
+	a.Equal("OK", string(body), "Body not truncated for the caller")
+
+	var buf bytes.Buffer
+	rec.WriteHAR(&buf)
+	var har struct {
+		Log struct {
+			Entries []struct {
+				Response struct {
+					Content struct {
+						Size    int
+						Text    string
+						Comment string
+					}
+				}
+			}
+		}
+	}
+	if !a.NoError(json.Unmarshal(buf.Bytes(), &har), "Valid JSON") {
+		return
+	}
+	content := har.Log.Entries[0].Response.Content
+	a.Equal("O", content.Text, "Capped")
+	a.Equal(len(body), content.Size, "Full size")
+	a.Equal("truncated", content.Comment, "Marked")
+}
+
+func TestRecorder_RequestBody(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		received = append(received, string(data))
+	}))
+	defer server.Close()
+
+	rec := &Recorder{MaxBodyBytes: 4}
+	cl, _ := NewClient(WithRecorder(rec))
+
+	req, _ := http.NewRequest("POST", server.URL, strings.NewReader("hello world"))
+	_, err := cl.Do(req)
+	a.NoError(err, "Sent with GetBody")
+
+	req, _ = http.NewRequest("POST", server.URL, ioutil.NopCloser(strings.NewReader("streamed")))
+	body := req.Body
+	_, err = cl.Do(req)
+	a.NoError(err, "Sent without GetBody")
+	a.True(req.Body == body && req.GetBody == nil, "Request untouched")
+	a.Equal([]string{"hello world", "streamed"}, received, "Bodies sent whole")
+
+	var buf bytes.Buffer
+	rec.WriteHAR(&buf)
+	var har struct {
+		Log struct {
+			Entries []struct {
+				Request struct {
+					BodySize int
+					PostData struct {
+						Text    string
+						Comment string
+					}
+				}
+			}
+		}
+	}
+	if !a.NoError(json.Unmarshal(buf.Bytes(), &har), "Valid JSON") || !a.Len(har.Log.Entries, 2, "An entry per request") {
+		return
+	}
+	r := har.Log.Entries[0].Request
+	a.Equal("hell", r.PostData.Text, "Capped")
+	a.Equal("truncated", r.PostData.Comment, "Marked truncated")
+	a.Equal(len("hello world"), r.BodySize, "Size by Content-Length")
+	r = har.Log.Entries[1].Request
+	a.Equal("", r.PostData.Text, "Not read")
+	a.Equal("omitted", r.PostData.Comment, "Marked omitted")
+	a.Equal(-1, r.BodySize, "Size unknown")
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hostHeaders returns the HostHeaders entries matching host: an exact
+// host first, then "*.suffix" patterns from the longest suffix.
+func (c *SafeClient) hostHeaders(host string) []http.Header {
+	if len(c.HostHeaders) == 0 {
+		return nil
+	}
+	host = strings.ToLower(host)
+	var exact []http.Header
+	var wild []string
+	for pattern, h := range c.HostHeaders {
+		p := strings.ToLower(pattern)
+		if h, _, err := net.SplitHostPort(p); err == nil {
+			p = h // ports are ignored
+		}
+		switch {
+		case p == host:
+			exact = append(exact, h)
+		case strings.HasPrefix(p, "*.") && strings.HasSuffix(host, p[1:]):
+			wild = append(wild, pattern)
+		}
+	}
+	sort.Slice(wild, func(i, j int) bool { return len(wild[i]) > len(wild[j]) })
+	for _, pattern := range wild {
+		exact = append(exact, c.HostHeaders[pattern])
+	}
+	return exact
+}
+
+// MethodOverrideHeader tells the method of a POST sent for another.
+const MethodOverrideHeader = "X-HTTP-Method-Override"
+
+// WithMethodOverride sends requests of the methods (PUT, PATCH and
+// DELETE if none given) as POSTs with the method in MethodOverrideHeader,
+// for proxies and gateways letting GET and POST through only.
+// Bodies and retries are as of the method overridden, and it is
+// what SLAFunc is told of.
+func WithMethodOverride(methods ...string) Option {
+	return func(c *SafeClient) error {
+		if len(methods) == 0 {
+			methods = []string{"PUT", "PATCH", "DELETE"}
+		}
+		for _, m := range methods {
+			c.OverrideMethods = append(c.OverrideMethods, strings.ToUpper(m))
+		}
+		return nil
+	}
+}
+
+func (c *SafeClient) overridden(method string) bool {
+	for _, m := range c.OverrideMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// prepare returns req with the client's headers added to a copy
+// if any: AttemptHeader, then HostHeaders and DefaultHeaders for those
+// not set yet, so that the RequestHook (and the caller) wins over
+// the defaults of the host, which win over the global ones.
+// A method of OverrideMethods is turned into a POST there too.
+func (c *SafeClient) prepare(req *http.Request) *http.Request {
+	n, attempt := req.Context().Value(attemptKey{}).(int)
+	attempt = attempt && c.AttemptHeader != ""
+	hosts := c.hostHeaders(req.URL.Hostname())
+	override := c.overridden(req.Method)
+	if !attempt && len(hosts) == 0 && len(c.DefaultHeaders) == 0 && !override {
+		return req
+	}
+
+	// the caller's request is not ours to modify
+	r := *req
+	r.Header = req.Header.Clone()
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+	if attempt {
+		r.Header.Set(c.AttemptHeader, strconv.Itoa(n))
+	}
+	if override {
+		r.Header.Set(MethodOverrideHeader, r.Method)
+		r.Method = "POST"
+	}
+	for _, h := range append(hosts, c.DefaultHeaders) {
+		for k, vs := range h {
+			k = http.CanonicalHeaderKey(k)
+			if _, ok := r.Header[k]; !ok {
+				r.Header[k] = append([]string(nil), vs...)
+			}
+		}
+	}
+	return &r
+}
+
+// WithDefaultHeaders adds h to the DefaultHeaders of the client.
+func WithDefaultHeaders(h http.Header) Option {
+	return func(c *SafeClient) error {
+		if c.DefaultHeaders == nil {
+			c.DefaultHeaders = make(http.Header)
+		}
+		for k, vs := range h {
+			c.DefaultHeaders[http.CanonicalHeaderKey(k)] = vs
+		}
+		return nil
+	}
+}
+
+// WithHostHeaders adds the headers per host pattern to the HostHeaders
+// of the client.
+func WithHostHeaders(m map[string]http.Header) Option {
+	return func(c *SafeClient) error {
+		if c.HostHeaders == nil {
+			c.HostHeaders = make(map[string]http.Header)
+		}
+		for pattern, h := range m {
+			c.HostHeaders[pattern] = h
+		}
+		return nil
+	}
+}
@@ -0,0 +1,97 @@
+package utils_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestSafeClient_HostHeaders(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer server.Close()
+
+	cl, err := NewClient(
+		WithDefaultHeaders(http.Header{"X-Api-Key": {"global"}, "X-Tenant": {"none"}, "X-Trace": {"on"}}),
+		WithHostHeaders(map[string]http.Header{
+			"API.internal.example.com:8443": {"X-Api-Key": {"api"}},
+			"*.internal.example.com":        {"X-Api-Key": {"internal"}, "X-Tenant": {"acme"}},
+			"*.example.com":                 {"X-Tenant": {"example"}},
+		}),
+		WithHostOverrides(map[string]string{
+			"api.internal.example.com": server.Listener.Addr().String(),
+			"db.internal.example.com":  server.Listener.Addr().String(),
+			"other.org":                server.Listener.Addr().String(),
+		}),
+	)
+	a.NoError(err, "No error")
+
+	cl.DoRequest("GET", "http://api.internal.example.com/", nil, 1, nil)
+	a.Equal("api", got.Get("X-Api-Key"), "Exact host, case and port ignored")
+	a.Equal("acme", got.Get("X-Tenant"), "Longest suffix")
+	a.Equal("on", got.Get("X-Trace"), "Global default")
+
+	cl.DoRequest("GET", "http://db.internal.example.com/", nil, 1, func(req *http.Request) {
+		req.Header.Set("X-Tenant", "hook")
+	})
+	a.Equal("internal", got.Get("X-Api-Key"), "Host-specific over global")
+	a.Equal("hook", got.Get("X-Tenant"), "Hook over host-specific")
+
+	req, _ := http.NewRequest("GET", "http://other.org/", nil)
+	req.Header.Set("X-Trace", "off")
+	cl.RequestWithRetry(req, 1)
+	a.Equal("global", got.Get("X-Api-Key"), "Global only")
+	a.Equal("off", got.Get("X-Trace"), "Caller over global")
+	a.Equal("", req.Header.Get("X-Api-Key"), "Caller's request unmodified")
+}
+
+func TestWithMethodOverride(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	type wire struct {
+		method, override, body string
+	}
+	var got []wire
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		got = append(got, wire{r.Method, r.Header.Get(MethodOverrideHeader), string(data)})
+		if len(got) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	var methods []string
+	cl, err := NewClient(WithMethodOverride(), WithSLA(time.Nanosecond, func(method, url string, elapsed, slept time.Duration, tries, status int) {
+		methods = append(methods, method)
+	}))
+	a.NoError(err, "No error")
+	cl.Backoff = testBackoff
+
+	err = cl.Resource(server.URL).Update(context.Background(), "1", testContent{"x"}, nil)
+	a.NoError(err, "No error")
+	a.Equal([]wire{{"POST", "PUT", `{"data":"x"}`}, {"POST", "PUT", `{"data":"x"}`}}, got, "Sent as POST on every try")
+	a.Equal([]string{"PUT"}, methods, "Logical method reported")
+
+	got = nil
+	cl.DoRequest("GET", server.URL, nil, 2, nil)
+	a.Equal(wire{"GET", "", ""}, got[1], "Other methods as they are")
+
+	cl, _ = NewClient(WithMethodOverride("patch"))
+	got = []wire{{}}
+	cl.DoRequest("DELETE", server.URL, nil, 1, nil)
+	cl.DoRequest("PATCH", server.URL, nil, 1, nil)
+	a.Equal([]wire{{}, {"DELETE", "", ""}, {"POST", "PATCH", ""}}, got, "Configured methods only")
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// IntervalLimiter paces events at least an interval apart, e.g., for a
+// partner taking no bursts, handing its permits out in the order asked;
+// it is safe for concurrent use.
+type IntervalLimiter struct {
+	interval time.Duration
+
+	// Jitter, if positive, adds a random [0, Jitter) to every gap,
+	// so clients started together drift apart.
+	Jitter time.Duration
+
+	// Now and After, if set, stand in for time.Now and time.After;
+	// set them before any use.
+	Now   func() time.Time
+	After func(d time.Duration) <-chan time.Time
+
+	mu   sync.Mutex
+	next time.Time // when the next permit is due
+}
+
+// NewIntervalLimiter returns an IntervalLimiter of interval,
+// with the first permit due at once.
+func NewIntervalLimiter(interval time.Duration) *IntervalLimiter {
+	return &IntervalLimiter{interval: interval}
+}
+
+func (l *IntervalLimiter) now() time.Time {
+	if l.Now != nil {
+		return l.Now()
+	}
+	return time.Now()
+}
+
+func (l *IntervalLimiter) after(d time.Duration) <-chan time.Time {
+	if l.After != nil {
+		return l.After(d)
+	}
+	return time.After(d)
+}
+
+// Wait blocks until a permit is due, or returns the error of ctx;
+// it fails at once if the deadline of ctx comes before the permit.
+func (l *IntervalLimiter) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	now := l.now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	d := at.Sub(now)
+	if deadline, ok := ctx.Deadline(); ok && d > 0 && deadline.Sub(now) < d {
+		l.mu.Unlock()
+		return context.DeadlineExceeded
+	}
+	gap := l.interval
+	if l.Jitter > 0 {
+		gap += time.Duration(rand.Int63n(int64(l.Jitter)))
+	}
+	next := at.Add(gap)
+	l.next = next
+	l.mu.Unlock()
+	if d == 0 {
+		return nil
+	}
+
+	select {
+	case <-l.after(d):
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		// give the permit back if none is due after it
+		if l.next.Equal(next) {
+			l.next = at
+		}
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
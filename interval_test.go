@@ -0,0 +1,131 @@
+package utils_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestIntervalLimiter(t *testing.T) {
+	a := assert.NewAssert(t)
+	const n = 5
+	const interval = 100 * time.Millisecond
+
+	clock := newFakeClock()
+	l := NewIntervalLimiter(interval)
+	l.Now, l.After = clock.Now, clock.After
+	start := clock.Now()
+	a.Nil(l.Wait(context.Background()), "First at once")
+
+	done := make(chan int)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			if l.Wait(context.Background()) == nil {
+				done <- i
+			}
+		}(i)
+		// queued in order
+		clock.awaitTimers(i + 1)
+	}
+
+	for i := 0; i < n; i++ {
+		clock.Add(interval)
+		a.Equal(i, <-done, "Served in order")
+		a.Equal(time.Duration(i+1)*interval, clock.Now().Sub(start), "Spaced by interval")
+	}
+	for i, d := range clock.Waits() {
+		a.Equalf(time.Duration(i+1)*interval, d, "Waited gap %d", i)
+	}
+
+	clock.Add(time.Hour)
+	a.Nil(l.Wait(context.Background()), "At once when idle")
+	a.Equal(n, len(clock.Waits()), "No timer when idle")
+}
+
+func TestIntervalLimiter_Cancel(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	clock := newFakeClock()
+	l := NewIntervalLimiter(time.Second)
+	l.Now, l.After = clock.Now, clock.After
+	l.Wait(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		errs <- l.Wait(ctx)
+	}()
+	clock.awaitTimers(1)
+	cancel()
+	a.Equal(context.Canceled, <-errs, "Cancelled")
+
+	go func() {
+		errs <- l.Wait(context.Background())
+	}()
+	clock.awaitTimers(2)
+	a.Equal(time.Second, clock.Waits()[1], "Permit given back")
+	clock.Add(time.Second)
+	a.Nil(<-errs, "Permitted")
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	a.Equal(context.DeadlineExceeded, l.Wait(ctx), "Deadline before permit")
+}
+
+func TestIntervalLimiter_Deadline(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	clock := newFakeClockAhead()
+	l := NewIntervalLimiter(time.Second)
+	l.Now, l.After = clock.Now, clock.After
+	l.Wait(context.Background())
+
+	ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(500*time.Millisecond))
+	defer cancel()
+	a.Equal(context.DeadlineExceeded, l.Wait(ctx), "Deadline before permit by the clock")
+	a.Equal(0, len(clock.Waits()), "Fails at once")
+
+	ctx, cancel = context.WithDeadline(context.Background(), clock.Now().Add(2*time.Second))
+	defer cancel()
+	errs := make(chan error)
+	go func() {
+		errs <- l.Wait(ctx)
+	}()
+	clock.awaitTimers(1)
+	a.Equal(time.Second, clock.Waits()[0], "Waits by the clock")
+	clock.Add(time.Second)
+	a.Nil(<-errs, "Permit before deadline by the clock")
+}
+
+func TestIntervalLimiter_Jitter(t *testing.T) {
+	a := assert.NewAssert(t)
+	const n = 20
+	const interval = 100 * time.Millisecond
+
+	clock := newFakeClock()
+	l := NewIntervalLimiter(interval)
+	l.Jitter = 50 * time.Millisecond
+	l.Now, l.After = clock.Now, clock.After
+	l.Wait(context.Background())
+	for i := 0; i < n; i++ {
+		go l.Wait(context.Background())
+		clock.awaitTimers(i + 1)
+	}
+
+	waits := clock.Waits()
+	var prev time.Duration
+	varied := false
+	for i, d := range waits {
+		gap := d - prev
+		a.True(gap >= interval && gap < interval+l.Jitter, "Gap within jitter")
+		if i > 0 && gap != waits[0] {
+			varied = true
+		}
+		prev = d
+	}
+	a.True(varied, "Gaps jittered")
+	clock.Add(time.Hour)
+}
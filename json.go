@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"context"
+)
+
+// GetJSON GETs url with retries and decodes a 2xx JSON body into out;
+// see PostJSON.
+func (c *SafeClient) GetJSON(ctx context.Context, url string, out interface{}, maxTries int, f RequestHook) (tries, status int, err error) {
+	return c.GetWithCodec(ctx, JSONCodec, url, out, maxTries, f)
+}
+
+// PostJSON POSTs in as JSON with retries and decodes a 2xx JSON body
+// into out (if not nil). It asks for JSON with "Accept: application/json",
+// which the RequestHook may override. A 204 or an empty (or whitespace-only)
+// body leaves out untouched; any other final status is an *HTTPError.
+func (c *SafeClient) PostJSON(ctx context.Context, url string, in, out interface{}, maxTries int, f RequestHook) (tries, status int, err error) {
+	return c.PostWithCodec(ctx, JSONCodec, url, in, out, maxTries, f)
+}
@@ -0,0 +1,94 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestSafeClient_GetJSON(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	tests := []struct {
+		h      http.HandlerFunc
+		status int
+		data   string
+	}{
+		{func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"data":"bar"}`))
+		}, http.StatusOK, "bar"},
+		{func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}, http.StatusNoContent, "untouched"},
+		{func(w http.ResponseWriter, r *http.Request) {
+		}, http.StatusOK, "untouched"},
+		{func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(" \r\n\t"))
+		}, http.StatusOK, "untouched"},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(test.h)
+
+		out := testContent{"untouched"}
+		_, status, err := testTimeoutClient.GetJSON(context.Background(), server.URL, &out, 3, nil)
+		a.NoError(err, "Not a syntax error")
+		a.Equal(test.status, status, "Returns code")
+		a.Equal(test.data, out.Data, "Decoded or untouched")
+
+		server.Close()
+	}
+}
+
+func TestSafeClient_GetJSON_Accept(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(CheckHeaderHandler("Accept", "application/json"))
+	defer server.Close()
+
+	var out testContent
+	_, status, err := testTimeoutClient.GetJSON(context.Background(), server.URL, &out, 3, nil)
+	var serr *json.SyntaxError
+	a.True(errors.As(err, &serr), "Body OK is not JSON")
+	a.Equal(http.StatusOK, status, "Accept sent")
+
+	_, status, err = testTimeoutClient.GetJSON(context.Background(), server.URL, nil, 3, func(req *http.Request) {
+		req.Header.Set("Accept", "text/plain")
+	})
+	var herr *HTTPError
+	a.True(errors.As(err, &herr), "Non-2xx is *HTTPError")
+	a.Equal(http.StatusForbidden, status, "Hook overrides Accept")
+	if herr != nil {
+		a.Equal([]byte("Wrong header"), herr.Body, "Carries the body")
+	}
+}
+
+func TestSafeClient_PostJSON(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in testContent
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		in.Data += "!"
+		json.NewEncoder(w).Encode(in)
+	}))
+	defer echo.Close()
+
+	var out testContent
+	n, status, err := testTimeoutClient.PostJSON(context.Background(), echo.URL, testContent{"hello"}, &out, 3, nil)
+	if !a.NoError(err, "No error") {
+		return
+	}
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal(0, n, "No retry")
+	a.Equal("hello!", out.Data, "Round trip")
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+)
+
+// DefaultRPCTries is the MaxTries of a JSONRPC made by SafeClient.JSONRPC.
+const DefaultRPCTries = 3
+
+// rpcID numbers the JSON-RPC requests of the process.
+var rpcID uint64
+
+// RPCError is the error object of a JSON-RPC 2.0 response.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("utils: jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// ErrRPCNoResponse is the Err of a batched RPCCall without a response.
+var ErrRPCNoResponse = errors.New("utils: jsonrpc: no response for call")
+
+// JSONRPC calls JSON-RPC 2.0 methods over HTTP POSTs of its Client,
+// each retried up to MaxTries times as usual.
+type JSONRPC struct {
+	Client   *SafeClient
+	MaxTries int
+	Hook     RequestHook // applied to every request, if set
+}
+
+// JSONRPC returns a JSONRPC over c with DefaultRPCTries.
+func (c *SafeClient) JSONRPC() *JSONRPC {
+	return &JSONRPC{Client: c, MaxTries: DefaultRPCTries}
+}
+
+// RPCCall is one call of a batch: Result (if not nil) is decoded
+// from the result of the response and Err set to its *RPCError,
+// or ErrRPCNoResponse if the server left the call out.
+type RPCCall struct {
+	Method string
+	Params interface{}
+	Result interface{}
+	Err    error
+}
+
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+	ID      uint64      `json:"id"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   *RPCError       `json:"error"`
+	ID      json.RawMessage `json:"id"`
+}
+
+func newRPCRequest(method string, params interface{}) rpcRequest {
+	return rpcRequest{"2.0", method, params, atomic.AddUint64(&rpcID, 1)}
+}
+
+// decode checks res and decodes its result into result (if not nil).
+func (res *rpcResponse) decode(result interface{}) error {
+	if res.JSONRPC != "2.0" {
+		return fmt.Errorf("utils: jsonrpc: invalid version %q", res.JSONRPC)
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	if result == nil || len(res.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(res.Result, result)
+}
+
+// Call calls method with params (omitted if nil) at url and decodes
+// the result into result (if not nil); an error object is returned
+// as an *RPCError.
+func (r *JSONRPC) Call(ctx context.Context, url, method string, params, result interface{}) error {
+	req := newRPCRequest(method, params)
+	var res rpcResponse
+	if _, _, err := r.Client.PostJSON(ctx, url, req, &res, r.MaxTries, r.Hook); err != nil {
+		return err
+	}
+	if id := strconv.FormatUint(req.ID, 10); string(res.ID) != id && res.Error == nil {
+		return fmt.Errorf("utils: jsonrpc: response id %s, want %s", res.ID, id)
+	}
+	return res.decode(result)
+}
+
+// Batch sends calls as one JSON-RPC batch to url and sets the outcome
+// of each from the response with its id, in whatever order they come.
+// The error returned is that of the HTTP exchange or the response
+// as a whole; those of the calls are in their Err.
+func (r *JSONRPC) Batch(ctx context.Context, url string, calls []*RPCCall) error {
+	if len(calls) == 0 {
+		return nil
+	}
+	reqs := make([]rpcRequest, len(calls))
+	byID := make(map[string]*RPCCall, len(calls))
+	for i, call := range calls {
+		reqs[i] = newRPCRequest(call.Method, call.Params)
+		byID[strconv.FormatUint(reqs[i].ID, 10)] = call
+		call.Err = ErrRPCNoResponse
+	}
+
+	var ress []rpcResponse
+	if _, _, err := r.Client.PostJSON(ctx, url, reqs, &ress, r.MaxTries, r.Hook); err != nil {
+		return err
+	}
+	for i := range ress {
+		if call, ok := byID[string(ress[i].ID)]; ok {
+			call.Err = ress[i].decode(call.Result)
+			delete(byID, string(ress[i].ID))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+type rpcReq struct {
+	JSONRPC string
+	Method  string
+	Params  []int
+	ID      json.RawMessage
+}
+
+// rpcResult answers one request: sum adds the params, anything else fails.
+func rpcResult(req rpcReq) string {
+	if req.Method != "sum" {
+		return fmt.Sprintf(`{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found","data":%q},"id":%s}`, req.Method, req.ID)
+	}
+	sum := 0
+	for _, p := range req.Params {
+		sum += p
+	}
+	return fmt.Sprintf(`{"jsonrpc":"2.0","result":%d,"id":%s}`, sum, req.ID)
+}
+
+var rpcHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	var raw json.RawMessage
+	json.NewDecoder(r.Body).Decode(&raw)
+	if raw[0] != '[' {
+		var req rpcReq
+		json.Unmarshal(raw, &req)
+		w.Write([]byte(rpcResult(req)))
+		return
+	}
+	var reqs []rpcReq
+	json.Unmarshal(raw, &reqs)
+	// answer in reverse, skipping the method "skip"
+	out := "["
+	for i := len(reqs) - 1; i >= 0; i-- {
+		if reqs[i].Method == "skip" {
+			continue
+		}
+		if out != "[" {
+			out += ","
+		}
+		out += rpcResult(reqs[i])
+	}
+	w.Write([]byte(out + "]"))
+})
+
+func TestJSONRPC_Call(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(rpcHandler)
+	defer server.Close()
+
+	rpc := testTimeoutClient.JSONRPC()
+	var sum int
+	a.NoError(rpc.Call(context.Background(), server.URL, "sum", []int{1, 2, 3}, &sum), "No error")
+	a.Equal(6, sum, "Result decoded")
+
+	err := rpc.Call(context.Background(), server.URL, "nope", nil, &sum)
+	rerr, ok := err.(*RPCError)
+	a.True(ok, "Error object")
+	if ok {
+		a.Equal(-32601, rerr.Code, "Code")
+		a.Equal("Method not found", rerr.Message, "Message")
+		a.Equal(`"nope"`, string(rerr.Data), "Data")
+	}
+
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"1.0","result":1,"id":1}`))
+	}))
+	defer bad.Close()
+	a.NotNil(rpc.Call(context.Background(), bad.URL, "sum", nil, &sum), "Version checked")
+}
+
+func TestJSONRPC_Batch(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(rpcHandler)
+	defer server.Close()
+
+	var one, two int
+	calls := []*RPCCall{
+		{Method: "sum", Params: []int{1}, Result: &one},
+		{Method: "nope"},
+		{Method: "sum", Params: []int{1, 1}, Result: &two},
+		{Method: "skip"},
+	}
+	a.NoError(testTimeoutClient.JSONRPC().Batch(context.Background(), server.URL, calls), "No error")
+	a.Equal(1, one, "Matched by id")
+	a.Equal(2, two, "Matched by id regardless of order")
+	a.NoError(calls[0].Err, "No call error")
+	_, ok := calls[1].Err.(*RPCError)
+	a.True(ok, "Error object")
+	a.Equal(ErrRPCNoResponse, calls[3].Err, "Left out")
+}
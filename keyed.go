@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+// SemaphoreGroup bounds the resources used per key, e.g., the requests
+// to each host, by a Semaphore of its own made on first use and
+// dropped once idle (none obtained nor wanted), so that keys seen
+// once cost nothing; it is safe for concurrent use.
+type SemaphoreGroup struct {
+	capacity int
+
+	mu     sync.Mutex
+	sems   map[string]*groupEntry
+	closed bool
+}
+
+type groupEntry struct {
+	sem  Semaphore
+	refs int // obtained or obtaining
+}
+
+// NewSemaphoreGroup returns a SemaphoreGroup of perKeyCapacity per key.
+func NewSemaphoreGroup(perKeyCapacity int) *SemaphoreGroup {
+	return &SemaphoreGroup{capacity: perKeyCapacity, sems: make(map[string]*groupEntry)}
+}
+
+// Obtain puts one into the semaphore of key, returns true if succeeds;
+// it blocks like Semaphore.Obtain.
+func (g *SemaphoreGroup) Obtain(ctx context.Context, key string) bool {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return false
+	}
+	e := g.sems[key]
+	if e == nil {
+		e = &groupEntry{sem: NewSemaphore(g.capacity)}
+		g.sems[key] = e
+	}
+	e.refs++
+	g.mu.Unlock()
+
+	if e.sem.Obtain(ctx) {
+		return true
+	}
+	g.mu.Lock()
+	g.unref(key, e)
+	g.mu.Unlock()
+	return false
+}
+
+// Release takes one from the semaphore of key, returns true if succeeds.
+func (g *SemaphoreGroup) Release(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	e := g.sems[key]
+	if e == nil || !e.sem.Release() {
+		return false
+	}
+	g.unref(key, e)
+	return true
+}
+
+// unref drops e of key if idle; g.mu must be held.
+func (g *SemaphoreGroup) unref(key string, e *groupEntry) {
+	if e.refs--; e.refs == 0 && g.sems[key] == e {
+		delete(g.sems, key)
+	}
+}
+
+// Counts returns the resources used per key, of the keys in use.
+func (g *SemaphoreGroup) Counts() map[string]int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	counts := make(map[string]int, len(g.sems))
+	for key, e := range g.sems {
+		counts[key] = e.sem.Count()
+	}
+	return counts
+}
+
+// Close closes the semaphore of every key, waking those blocked
+// in Obtain; it makes Obtain return false ever since.
+func (g *SemaphoreGroup) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.closed = true
+	for _, e := range g.sems {
+		e.sem.Close()
+	}
+}
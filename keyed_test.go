@@ -0,0 +1,86 @@
+package utils_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestSemaphoreGroup(t *testing.T) {
+	assert := assert.NewAssert(t)
+
+	g := NewSemaphoreGroup(2)
+	bc := context.Background()
+	assert.True(g.Obtain(bc, "a") && g.Obtain(bc, "a"), "Obtained a twice")
+	assert.True(g.Obtain(bc, "b"), "Independent of a")
+
+	ctx, cancel := context.WithTimeout(bc, 10*time.Millisecond)
+	defer cancel()
+	assert.True(!g.Obtain(ctx, "a"), "a is full")
+	assert.Equal(map[string]int{"a": 2, "b": 1}, g.Counts(), "Counts per key")
+
+	assert.True(g.Release("b"), "Released b")
+	assert.Equal(map[string]int{"a": 2}, g.Counts(), "Idle b evicted")
+	assert.True(!g.Release("b"), "Nothing held in b")
+	assert.True(!g.Release("c"), "Unknown key")
+
+	g.Release("a")
+	g.Release("a")
+	assert.Equal(0, len(g.Counts()), "All evicted")
+
+	// Close wakes the waiters
+	g.Obtain(bc, "a")
+	g.Obtain(bc, "a")
+	result := make(chan bool)
+	go func() {
+		result <- g.Obtain(bc, "a")
+	}()
+	time.Sleep(5 * time.Millisecond)
+	g.Close()
+	assert.True(!<-result, "Woken with false")
+	assert.True(!g.Obtain(bc, "d"), "Closed")
+}
+
+func TestSemaphoreGroup_Sync(t *testing.T) {
+	assert := assert.NewAssert(t)
+	const n = 4
+	const m = 20 // workers per key
+
+	g := NewSemaphoreGroup(n)
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	var running [2]int32
+	var maxRunning [2]int32
+	for _, k := range []int{0, 1} {
+		key := []string{"x", "y"}[k]
+		for i := 0; i < m; i++ {
+			wg.Add(1)
+			go func(k int) {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					if !g.Obtain(ctx, key) {
+						return
+					}
+					c := atomic.AddInt32(&running[k], 1)
+					for {
+						max := atomic.LoadInt32(&maxRunning[k])
+						if c <= max || atomic.CompareAndSwapInt32(&maxRunning[k], max, c) {
+							break
+						}
+					}
+					time.Sleep(time.Microsecond)
+					atomic.AddInt32(&running[k], -1)
+					g.Release(key)
+				}
+			}(k)
+		}
+	}
+	wg.Wait()
+	assert.True(maxRunning[0] <= n && maxRunning[1] <= n, "Bounded per key")
+	assert.Equal(0, len(g.Counts()), "All evicted")
+}
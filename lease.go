@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// LeasedSemaphore is a Semaphore handing out its resources as leases
+// reclaimed after a TTL, so that a goroutine leaking one, e.g.,
+// crashed before releasing, does not shrink it for good.
+// One timer watches the leases, the earliest expiring first.
+type LeasedSemaphore struct {
+	sem      Semaphore
+	ttl      time.Duration
+	onExpire func(l *Lease)
+
+	mu     sync.Mutex
+	leases list.List // of *Lease, by expiry
+	timer  *time.Timer
+}
+
+// Lease is one obtained from a LeasedSemaphore.
+type Lease struct {
+	// Obtained is when it was obtained, e.g., to log a leak.
+	Obtained time.Time
+
+	s    *LeasedSemaphore
+	elem *list.Element // in s.leases if watched
+	done bool          // released or expired
+}
+
+// NewLeasedSemaphore returns a LeasedSemaphore of n reclaiming the
+// leases not released within ttl, none if ttl <= 0, calling onExpire,
+// if not nil, with each reclaimed.
+func NewLeasedSemaphore(n int, ttl time.Duration, onExpire func(l *Lease)) *LeasedSemaphore {
+	return &LeasedSemaphore{sem: NewSemaphore(n), ttl: ttl, onExpire: onExpire}
+}
+
+// Obtain obtains a Lease, failing like Semaphore.ObtainErr.
+func (s *LeasedSemaphore) Obtain(ctx context.Context) (*Lease, error) {
+	if err := s.sem.ObtainErr(ctx); err != nil {
+		return nil, err
+	}
+	l := &Lease{Obtained: time.Now(), s: s}
+	if s.ttl <= 0 {
+		return l, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l.elem = s.leases.PushBack(l)
+	if s.leases.Len() == 1 {
+		if s.timer == nil {
+			s.timer = time.AfterFunc(s.ttl, s.expire)
+		} else {
+			s.timer.Reset(s.ttl)
+		}
+	}
+	return l, nil
+}
+
+// Release releases l, returns true if succeeds;
+// it is a no-op, returning false, once released or expired.
+func (l *Lease) Release() bool {
+	s := l.s
+	s.mu.Lock()
+	if l.done {
+		s.mu.Unlock()
+		return false
+	}
+	l.done = true
+	if l.elem != nil {
+		s.leases.Remove(l.elem)
+		l.elem = nil
+		if s.leases.Len() == 0 {
+			s.timer.Stop()
+		}
+		// else the timer, if early, only watches again
+	}
+	s.mu.Unlock()
+	return s.sem.Release()
+}
+
+// expire reclaims the leases expired, then watches the next.
+func (s *LeasedSemaphore) expire() {
+	var expired []*Lease
+	s.mu.Lock()
+	now := time.Now()
+	for front := s.leases.Front(); front != nil; front = s.leases.Front() {
+		l := front.Value.(*Lease)
+		if d := l.Obtained.Add(s.ttl).Sub(now); d > 0 {
+			s.timer.Reset(d)
+			break
+		}
+		s.leases.Remove(front)
+		l.elem, l.done = nil, true
+		expired = append(expired, l)
+	}
+	s.mu.Unlock()
+
+	for _, l := range expired {
+		s.sem.Release()
+		if s.onExpire != nil {
+			s.onExpire(l)
+		}
+	}
+}
+
+// Capacity returns semaphore's max concurrent leases.
+func (s *LeasedSemaphore) Capacity() int {
+	return s.sem.Capacity()
+}
+
+// Count returns semaphore's current leases.
+func (s *LeasedSemaphore) Count() int {
+	return s.sem.Count()
+}
+
+// Close stops obtaining leases, waking those blocked in Obtain;
+// the leases out are still released or expired.
+func (s *LeasedSemaphore) Close() {
+	s.sem.Close()
+}
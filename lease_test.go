@@ -0,0 +1,77 @@
+package utils_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestLeasedSemaphore(t *testing.T) {
+	assert := assert.NewAssert(t)
+	const ttl = 20 * time.Millisecond
+	bc := context.Background()
+
+	var mu sync.Mutex
+	var leaked []*Lease
+	s := NewLeasedSemaphore(2, ttl, func(l *Lease) {
+		mu.Lock()
+		leaked = append(leaked, l)
+		mu.Unlock()
+	})
+
+	// released before expiry
+	l, err := s.Obtain(bc)
+	assert.NoError(err, "Obtained")
+	assert.True(l.Release(), "Released")
+	assert.True(!l.Release(), "Released once")
+	assert.Equal(0, s.Count(), "Empty")
+
+	// leaked ones reclaimed
+	l1, _ := s.Obtain(bc)
+	time.Sleep(ttl / 2)
+	l2, _ := s.Obtain(bc)
+	assert.Equal(2, s.Count(), "Full")
+	start := time.Now()
+	l3, err := s.Obtain(bc)
+	assert.NoError(err, "Obtained once reclaimed")
+	assert.True(time.Since(start) < ttl, "Reclaimed at expiry")
+	mu.Lock()
+	assert.Equal([]*Lease{l1}, leaked, "Leak told")
+	mu.Unlock()
+
+	// a late release is a no-op
+	assert.True(!l1.Release(), "Late release ignored")
+	assert.Equal(2, s.Count(), "Count kept")
+	assert.True(l2.Release() && l3.Release(), "Released in time")
+	assert.Equal(0, s.Count(), "Empty")
+
+	time.Sleep(2 * ttl)
+	mu.Lock()
+	assert.Equal(1, len(leaked), "Released ones not expired")
+	mu.Unlock()
+
+	// watched again after idle
+	s.Obtain(bc)
+	time.Sleep(2 * ttl)
+	assert.Equal(0, s.Count(), "Reclaimed again")
+
+	s.Close()
+	_, err = s.Obtain(bc)
+	assert.Equal(ErrSemaphoreClosed, err, "Closed")
+}
+
+func TestLeasedSemaphore_NoTTL(t *testing.T) {
+	assert := assert.NewAssert(t)
+
+	s := NewLeasedSemaphore(1, 0, nil)
+	l, err := s.Obtain(context.Background())
+	assert.NoError(err, "Obtained")
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(1, s.Count(), "Never expires")
+	assert.True(l.Release() && !l.Release(), "Released once")
+	assert.Equal(1, s.Capacity(), "Capacity")
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrOverloaded is returned, without retrying, for a try shed
+// by the queue limits of WithMaxConcurrent.
+var ErrOverloaded = errors.New("utils: too many requests queued")
+
+// WithMaxConcurrent bounds the tries in flight at once to n, shared by
+// every copy of the client; a try holds its slot until the body is closed.
+// When all slots are taken, a try waits in a queue bounded by
+// maxQueueDepth waiters and by maxQueueWait, failing with ErrOverloaded
+// past either (0 leaves it unbounded); shed tries are counted by Stats.
+func WithMaxConcurrent(n, maxQueueDepth int, maxQueueWait time.Duration) Option {
+	return func(c *SafeClient) error {
+		if n <= 0 {
+			return errors.New("utils: MaxConcurrent must be positive")
+		}
+		c.limit = &limiter{
+			sem:      NewSemaphore(n),
+			maxDepth: maxQueueDepth,
+			maxWait:  maxQueueWait,
+		}
+		return nil
+	}
+}
+
+// limiter is the concurrency limit installed by WithMaxConcurrent.
+type limiter struct {
+	sem      Semaphore
+	waiting  int64
+	maxDepth int
+	maxWait  time.Duration
+}
+
+// obtain takes a slot for a try, or fails with ErrOverloaded
+// or the error of ctx.
+func (l *limiter) obtain(ctx context.Context) error {
+	full := l.sem.Count() >= l.sem.Capacity()
+	if waiting := atomic.AddInt64(&l.waiting, 1); full && l.maxDepth > 0 && waiting > int64(l.maxDepth) {
+		atomic.AddInt64(&l.waiting, -1)
+		return ErrOverloaded
+	}
+	defer atomic.AddInt64(&l.waiting, -1)
+
+	wctx := ctx
+	if l.maxWait > 0 {
+		var cancel context.CancelFunc
+		wctx, cancel = context.WithTimeout(ctx, l.maxWait)
+		defer cancel()
+	}
+	if l.sem.Obtain(wctx) {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ErrOverloaded
+}
+
+func (l *limiter) do(c *SafeClient, req *http.Request) (*http.Response, error) {
+	if err := l.obtain(req.Context()); err != nil {
+		if err == ErrOverloaded && c.Stats != nil {
+			atomic.AddInt64(&c.Stats.shed, 1)
+		}
+		return nil, err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		l.sem.Release()
+		return nil, err
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, sem: l.sem}
+	return resp, nil
+}
+
+// limitedBody holds a slot of the limit until closed.
+type limitedBody struct {
+	io.ReadCloser
+	sem  Semaphore
+	once sync.Once
+}
+
+func (b *limitedBody) Close() error {
+	b.once.Do(func() {
+		b.sem.Release()
+	})
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,86 @@
+package utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestWithMaxConcurrent(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	entered := make(chan struct{}, 10)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	cl, err := NewClient(WithMaxConcurrent(2, 1, 5*time.Second))
+	a.NoError(err, "No error")
+	cl.Stats = &Stats{}
+
+	var wg sync.WaitGroup
+	statuses := make(chan int, 3)
+	get := func() {
+		defer wg.Done()
+		_, status, _, err := cl.DoRequest("GET", server.URL, nil, 1, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		statuses <- status
+	}
+	wg.Add(2)
+	go get()
+	go get()
+	<-entered
+	<-entered
+
+	// one more may wait in the queue
+	wg.Add(1)
+	go get()
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	_, _, _, err = cl.DoRequest("GET", server.URL, nil, 3, nil)
+	a.Equal(ErrOverloaded, err, "Excess call shed")
+	a.True(time.Since(start) < 100*time.Millisecond, "Failed fast")
+	a.Equal(int64(1), cl.Stats.Snapshot().Shed, "Shed counted")
+
+	close(release)
+	wg.Wait()
+	close(statuses)
+	for status := range statuses {
+		a.Equal(http.StatusOK, status, "Admitted call succeeds")
+	}
+}
+
+func TestWithMaxConcurrent_QueueWait(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	cl, _ := NewClient(WithMaxConcurrent(1, 0, 20*time.Millisecond))
+	go cl.DoRequest("GET", server.URL, nil, 1, nil)
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	_, _, _, err := cl.DoRequest("GET", server.URL, nil, 1, nil)
+	a.Equal(ErrOverloaded, err, "Waited too long")
+	a.True(time.Since(start) < 500*time.Millisecond, "Bounded by MaxQueueWait")
+
+	_, err = NewClient(WithMaxConcurrent(0, 0, 0))
+	a.NotNil(err, "Needs a positive limit")
+}
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Part is a part of a multipart/form-data body, streamed from
+// what Open returns (and closed after); Open is called once per try,
+// so a retried or redirected upload re-reads the source.
+type Part struct {
+	Name        string
+	FileName    string // for a file part
+	ContentType string // of a file part, "application/octet-stream" if empty
+	// Size is the exact length Open reads, or negative if unknown;
+	// the body has a Content-Length only if every Size is known.
+	Size int64
+	Open func() (io.ReadCloser, error)
+}
+
+// FieldPart returns the Part of a form field.
+func FieldPart(name, value string) Part {
+	return Part{
+		Name: name,
+		Size: int64(len(value)),
+		Open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(value)), nil
+		},
+	}
+}
+
+// FilePart returns the Part uploading the file at path,
+// typed by its extension.
+func FilePart(name, path string) (Part, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return Part{}, err
+	}
+	return Part{
+		Name:        name,
+		FileName:    filepath.Base(path),
+		ContentType: mime.TypeByExtension(filepath.Ext(path)),
+		Size:        fi.Size(),
+		Open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+	}, nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func (p *Part) header() textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+	disposition := fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(p.Name))
+	if p.FileName != "" {
+		disposition += fmt.Sprintf(`; filename="%s"`, quoteEscaper.Replace(p.FileName))
+		ct := p.ContentType
+		if ct == "" {
+			ct = "application/octet-stream"
+		}
+		h.Set("Content-Type", ct)
+	}
+	h.Set("Content-Disposition", disposition)
+	return h
+}
+
+// NewMultipartPost returns a Request with the parts streamed as a
+// multipart/form-data body while it is sent, never held in memory;
+// additional headers or cookies can be set through the RequestHook.
+// GetBody re-opens the parts, so a 307 or 308 redirect re-sends them.
+func NewMultipartPost(url string, parts []Part, f RequestHook) (*http.Request, error) {
+	req, err := newMultipartPost(context.Background(), url, parts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = applyHook(f, req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// PostMultipart posts the parts as NewMultipartPost does, retried
+// like DoRequest with the parts re-opened for every try.
+func (c *SafeClient) PostMultipart(ctx context.Context, url string, parts []Part, maxTries int, f RequestHook) (tries int, res Response, err error) {
+	return c.doRequestFunc(ctx, "POST", url, func(ctx context.Context) (*http.Request, error) {
+		req, err := newMultipartPost(ctx, url, parts)
+		if err == nil {
+			c.digestTrailer(req)
+		}
+		return req, err
+	}, maxTries, f)
+}
+
+func newMultipartPost(ctx context.Context, url string, parts []Part) (*http.Request, error) {
+	boundary := multipart.NewWriter(nil).Boundary()
+	body := func() (io.ReadCloser, error) {
+		return streamParts(boundary, parts), nil
+	}
+	r, _ := body()
+	req, err := http.NewRequestWithContext(ctx, "POST", url, r)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	req.GetBody = body
+	req.ContentLength = multipartLength(boundary, parts)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+	return req, nil
+}
+
+// multipartLength returns the length of the body of parts,
+// or -1 if any size is unknown.
+func multipartLength(boundary string, parts []Part) int64 {
+	var cw countingWriter
+	w := multipart.NewWriter(&cw)
+	w.SetBoundary(boundary)
+	for i := range parts {
+		if parts[i].Size < 0 {
+			return -1
+		}
+		w.CreatePart(parts[i].header())
+		cw.n += parts[i].Size
+	}
+	w.Close()
+	return cw.n
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// streamParts writes the body of parts through a pipe as it is read;
+// closing the reader stops the writing.
+func streamParts(boundary string, parts []Part) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		w := multipart.NewWriter(pw)
+		w.SetBoundary(boundary)
+		var err error
+		for i := range parts {
+			if err = writePart(w, &parts[i]); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = w.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+func writePart(w *multipart.Writer, p *Part) error {
+	pw, err := w.CreatePart(p.header())
+	if err != nil {
+		return err
+	}
+	r, err := p.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	n, err := io.Copy(pw, r)
+	if err != nil {
+		return err
+	}
+	if p.Size >= 0 && n != p.Size {
+		return fmt.Errorf("utils: multipart part %q is %d bytes, not %d", p.Name, n, p.Size)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package utils_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+// patternReader generates n bytes of a repeating pattern.
+type patternReader struct {
+	n int64
+}
+
+func (r *patternReader) Read(p []byte) (int, error) {
+	if r.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.n {
+		p = p[:r.n]
+	}
+	for i := range p {
+		p[i] = byte('a' + i%26)
+	}
+	r.n -= int64(len(p))
+	return len(p), nil
+}
+
+func (r *patternReader) Close() error {
+	return nil
+}
+
+func streamPart(name string, n int64, known bool) Part {
+	p := Part{Name: name, FileName: name + ".bin", Size: -1, Open: func() (io.ReadCloser, error) {
+		return &patternReader{n}, nil
+	}}
+	if known {
+		p.Size = n
+	}
+	return p
+}
+
+// multipartHandler writes each part as "name filename type size",
+// a line per part, after the request's Content-Length.
+var multipartHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var lines []string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		n, _ := io.Copy(ioutil.Discard, p)
+		lines = append(lines, fmt.Sprintf("%s %s %s %d", p.FormName(), p.FileName(), p.Header.Get("Content-Type"), n))
+	}
+	fmt.Fprintf(w, "%d\n%s", r.ContentLength, strings.Join(lines, "\n"))
+})
+
+func TestPostMultipart(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(multipartHandler)
+	defer server.Close()
+
+	ctx := context.Background()
+	parts := []Part{FieldPart("title", `a "quoted" title`), streamPart("data", 1000, true)}
+	_, res, err := StdClient().PostMultipart(ctx, server.URL, parts, 1, nil)
+	a.NoError(err, "No error")
+	a.Equal(http.StatusOK, res.Status, "Returns code")
+	lines := strings.Split(string(res.Body), "\n")
+	a.True(lines[0] != "-1", "Length precomputed")
+	a.Equal([]string{`title   16`, "data data.bin application/octet-stream 1000"}, lines[1:], "Parts received")
+
+	parts[1] = streamPart("data", 1000, false)
+	_, res, _ = StdClient().PostMultipart(ctx, server.URL, parts, 1, nil)
+	lines = strings.Split(string(res.Body), "\n")
+	a.Equal("-1", lines[0], "Chunked")
+	a.Equal("data data.bin application/octet-stream 1000", lines[2], "Part received")
+
+	// a wrong Size fails the upload
+	parts[1] = streamPart("data", 1000, true)
+	parts[1].Size = 999
+	_, _, err = StdClient().PostMultipart(ctx, server.URL, parts, 1, nil)
+	a.NotNil(err, "Size mismatch")
+}
+
+func TestPostMultipart_Retry(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var n int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		multipartHandler(w, r)
+	}))
+	defer server.Close()
+
+	part := streamPart("data", 100, true)
+	cl := StdClient()
+	cl.Backoff = testBackoff
+	tries, res, err := cl.PostMultipart(context.Background(), server.URL, []Part{part}, 3, nil)
+	a.NoError(err, "No error")
+	a.Equal(1, tries, "Retried once")
+	a.Equal(http.StatusOK, res.Status, "Returns code")
+	a.True(strings.HasSuffix(string(res.Body), "data data.bin application/octet-stream 100"), "Re-sent in full")
+}
+
+func TestNewMultipartPost(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(multipartHandler)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	ioutil.WriteFile(path, []byte("hello"), 0644)
+	part, err := FilePart("file", path)
+	a.NoError(err, "No error")
+	_, err = FilePart("file", path+".missing")
+	a.NotNil(err, "Missing file")
+
+	req, err := NewMultipartPost(server.URL, []Part{part}, func(req *http.Request) {
+		req.Header.Set("X-Test", "1")
+	})
+	a.NoError(err, "No error")
+	a.Equal("1", req.Header.Get("X-Test"), "Hook applied")
+	a.True(strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data; boundary="), "Content type")
+	status, body, err := StdClient().RequestWithClose(req)
+	a.NoError(err, "No error")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal(fmt.Sprintf("%d\nfile notes.txt text/plain; charset=utf-8 5", req.ContentLength), string(body), "File received")
+}
+
+func TestPostMultipart_Memory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("uploads 32MB")
+	}
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(multipartHandler)
+	defer server.Close()
+
+	const size = 32 << 20
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	_, res, err := StdClient().PostMultipart(context.Background(), server.URL, []Part{streamPart("big", size, false)}, 1, nil)
+	runtime.ReadMemStats(&after)
+	a.NoError(err, "No error")
+	a.True(strings.HasSuffix(string(res.Body), fmt.Sprintf(" %d", size)), "Received in full")
+	a.True(after.TotalAlloc-before.TotalAlloc < size/8, "Never buffered")
+}
+
+func BenchmarkPostMultipart(b *testing.B) {
+	server := httptest.NewServer(multipartHandler)
+	defer server.Close()
+
+	const size = 8 << 20
+	b.SetBytes(size)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		StdClient().PostMultipart(context.Background(), server.URL, []Part{streamPart("big", size, true)}, 1, nil)
+	}
+}
+
+// closeCounter counts the closes of the readers it opens.
+type closeCounter struct {
+	io.Reader
+	n *int32
+}
+
+func (c closeCounter) Close() error {
+	atomic.AddInt32(c.n, -1)
+	return nil
+}
+
+func TestMultipart_HookError(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var open int32
+	part := Part{Name: "file", FileName: "big.bin", Size: -1, Open: func() (io.ReadCloser, error) {
+		atomic.AddInt32(&open, 1)
+		return closeCounter{&patternReader{1 << 20}, &open}, nil
+	}}
+	errHook := fmt.Errorf("hook failed")
+	hook := RequestHookE(func(req *http.Request) error { return errHook }).Hook()
+
+	goroutines := runtime.NumGoroutine()
+	_, err := NewMultipartPost("http://example.com", []Part{part}, hook)
+	a.Equal(errHook, err, "Hook error")
+	_, _, err = StdClient().PostMultipart(context.Background(), "http://example.com", []Part{part}, 3, hook)
+	a.ErrorIs(err, errHook, "Hook error")
+	a.Eventually(func() bool { return runtime.NumGoroutine() <= goroutines }, time.Second, time.Millisecond, "Streaming stopped")
+	a.Equal(int32(0), atomic.LoadInt32(&open), "No part left open")
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+)
+
+// Option configures a SafeClient built by NewClient;
+// it returns an error if the setting cannot be applied.
+type Option func(c *SafeClient) error
+
+// NewClient returns a StdClient configured by the options,
+// which are applied in order.
+func NewClient(opts ...Option) (*SafeClient, error) {
+	c := StdClient()
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// wrappedTransport is a RoundTripper installed by an option
+// around the transport underneath.
+type wrappedTransport interface {
+	Unwrap() http.RoundTripper
+}
+
+// transport returns the underlying *http.Transport, looking through
+// the wrappers installed by options and installing a clone of
+// http.DefaultTransport if none set.
+func (c *SafeClient) transport() (*http.Transport, error) {
+	if c.Transport == nil {
+		c.cloned = http.DefaultTransport.(*http.Transport).Clone()
+		c.Transport = c.cloned
+	}
+	rt := c.Transport
+	for {
+		w, ok := rt.(wrappedTransport)
+		if !ok {
+			break
+		}
+		rt = w.Unwrap()
+	}
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		return nil, errors.New("utils: option requires an *http.Transport")
+	}
+	return t, nil
+}
+
+// CloseIdleConnections closes the idle connections of the client's
+// transport, looking through the wrappers installed by options,
+// those of WithH2C included.
+func (c *SafeClient) CloseIdleConnections() {
+	rt := c.Transport
+	for {
+		w, ok := rt.(wrappedTransport)
+		if !ok {
+			break
+		}
+		rt = w.Unwrap()
+	}
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	if ci, ok := rt.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+	if c.h2c != nil {
+		c.h2c.CloseIdleConnections()
+	}
+}
+
+// dialer returns the client's dialer, installing it
+// as the transport's DialContext on first use.
+func (c *SafeClient) dialer() (*dialer, error) {
+	if c.dial != nil {
+		return c.dial, nil
+	}
+	t, err := c.transport()
+	if err != nil {
+		return nil, err
+	}
+	c.dial = newDialer()
+	t.DialContext = c.dial.DialContext
+	return c.dial, nil
+}
+
+// DefaultAttemptHeader is the header set by WithAttemptHeader("").
+const DefaultAttemptHeader = "X-Retry-Attempt"
+
+// attemptKey keys the number of the try in its request context.
+type attemptKey struct{}
+
+// WithAttemptHeader sets header (DefaultAttemptHeader if empty) on every
+// try of the retrying methods to the zero-based number of the try,
+// so that the server can tell retries from original requests.
+func WithAttemptHeader(header string) Option {
+	return func(c *SafeClient) error {
+		if header == "" {
+			header = DefaultAttemptHeader
+		}
+		c.AttemptHeader = header
+		return nil
+	}
+}
+
+// WithRetryTooManyRequests makes the retrying methods retry
+// 429 Too Many Requests, honoring its Retry-After, which ShouldRetry
+// leaves to the caller.
+func WithRetryTooManyRequests() Option {
+	return func(c *SafeClient) error {
+		c.RetryTooManyRequests = true
+		return nil
+	}
+}
+
+// WithRetryCookies sets the client's cookie jar (a new in-memory one
+// if jar is nil), so that the cookies set by a failed try, e.g.,
+// a sticky-session cookie on a 503, are sent on its retries:
+// every try, DoRequest's rebuilt requests included, goes through the
+// jar both ways. Without a jar, retries are sent as the first try.
+func WithRetryCookies(jar http.CookieJar) Option {
+	return func(c *SafeClient) error {
+		if jar == nil {
+			var err error
+			if jar, err = cookiejar.New(nil); err != nil {
+				return err
+			}
+		}
+		c.Jar = jar
+		return nil
+	}
+}
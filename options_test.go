@@ -0,0 +1,109 @@
+package utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestWithAttemptHeader(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var mu sync.Mutex
+	var attempts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts = append(attempts, r.Header.Get(DefaultAttemptHeader))
+		if _, ok := r.Header[DefaultAttemptHeader]; !ok {
+			attempts[len(attempts)-1] = "absent"
+		}
+		if len(attempts)%3 != 0 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	cl, err := NewClient(WithAttemptHeader(""))
+	a.NoError(err, "No error")
+	cl.Backoff = testBackoff
+	cl.Sleep = (&fakeSleeper{}).Sleep
+
+	n, status, _, err := cl.DoRequest("GET", server.URL, nil, 3, nil)
+	a.NoError(err, "No error")
+	a.Equal(2, n, "Retried twice")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal([]string{"0", "1", "2"}, attempts, "Attempt numbers on rebuilt requests")
+
+	// the same request retried is left untouched
+	attempts = nil
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	cl.RequestWithRetry(req, 3)
+	a.Equal([]string{"0", "1", "2"}, attempts, "Attempt numbers on RequestWithRetry")
+	a.Equal("", req.Header.Get(DefaultAttemptHeader), "Caller's request unmodified")
+
+	// disabled by default
+	attempts = nil
+	cl.AttemptHeader = ""
+	cl.DoRequest("GET", server.URL, nil, 3, nil)
+	a.Equal([]string{"absent", "absent", "absent"}, attempts, "No header when disabled")
+}
+
+func TestWithRetryCookies(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("backend"); err != nil {
+			// the load balancer picks a backend, which is down
+			http.SetCookie(w, &http.Cookie{Name: "backend", Value: "healthy"})
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	cl := testTimeoutClient
+	cl.Sleep = (&fakeSleeper{}).Sleep
+	n, status, _, _ := cl.DoRequest("GET", server.URL, nil, 2, nil)
+	a.Equal(1, n, "Retried")
+	a.Equal(http.StatusServiceUnavailable, status, "Cookie dropped without a jar")
+
+	a.NoError(WithRetryCookies(nil)(&cl), "No error")
+	n, status, body, err := cl.DoRequest("GET", server.URL, nil, 2, nil)
+	a.NoError(err, "No error")
+	a.Equal(1, n, "Retried")
+	a.Equal(http.StatusOK, status, "Cookie of the 503 sent on the retry")
+	a.Equal("OK", string(body), "Routed to the healthy backend")
+}
+
+func TestWithRetryTooManyRequests(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var tries int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tries++; tries == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	cl := testTimeoutClient
+	cl.Sleep = (&fakeSleeper{}).Sleep
+	n, status, _, _ := cl.DoRequest("GET", server.URL, nil, 2, nil)
+	a.Equal(0, n, "Not retried by default")
+	a.Equal(http.StatusTooManyRequests, status, "Throttled")
+
+	tries = 0
+	a.NoError(WithRetryTooManyRequests()(&cl), "No error")
+	n, status, _, err := cl.DoRequest("GET", server.URL, nil, 2, nil)
+	a.NoError(err, "No error")
+	a.Equal(1, n, "Retried")
+	a.Equal(http.StatusOK, status, "Through once not throttled")
+}
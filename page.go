@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrStop can be returned by the callbacks of the streaming
+// and paging helpers to stop them early without an error.
+var ErrStop = errors.New("utils: stop")
+
+// DefaultMaxPages bounds GetAllPages if SafeClient.MaxPages is unset.
+const DefaultMaxPages = 1000
+
+// ParseLinkNext returns the target of the rel="next" link
+// in the RFC 5988 Link header(s), or "" if none.
+func ParseLinkNext(h http.Header) string {
+	for _, v := range h["Link"] {
+		for _, link := range strings.Split(v, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				if len(kv) != 2 || !strings.EqualFold(kv[0], "rel") {
+					continue
+				}
+				// rel may hold several space-separated types
+				for _, rel := range strings.Fields(strings.Trim(kv[1], `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// GetAllPages GETs rawurl and every page linked from it by Link: rel="next",
+// one by one with the usual retries on each page, calling onPage in order.
+// It stops when there is no next page, onPage returns an error
+// (ErrStop stops without one) or ctx is done; a next link visited before
+// or more than MaxPages pages are treated as a pagination loop.
+func (c *SafeClient) GetAllPages(ctx context.Context, rawurl string, maxTries int, f RequestHook, onPage func(status int, body []byte, header http.Header) error) error {
+	maxPages := c.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+	seen := make(map[string]bool)
+
+	for pages := 0; rawurl != ""; pages++ {
+		if pages == maxPages || seen[rawurl] {
+			return fmt.Errorf("utils: pagination loop at %s after %d pages", rawurl, pages)
+		}
+		seen[rawurl] = true
+
+		_, res, err := c.doRequest(ctx, "GET", rawurl, nil, maxTries, f)
+		if err != nil {
+			return err
+		}
+		if err = onPage(res.Status, res.Body, res.Header); err != nil {
+			if err == ErrStop {
+				return nil
+			}
+			return err
+		}
+
+		next := ParseLinkNext(res.Header)
+		if next == "" {
+			return nil
+		}
+		// next may be relative to the current page
+		base, err := url.Parse(rawurl)
+		if err != nil {
+			return err
+		}
+		ref, err := base.Parse(next)
+		if err != nil {
+			return err
+		}
+		rawurl = ref.String()
+	}
+	return nil
+}
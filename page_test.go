@@ -0,0 +1,121 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestParseLinkNext(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	tests := []struct {
+		links []string
+		next  string
+	}{
+		{nil, ""},
+		{[]string{`<https://api.example.com/items?page=2>; rel="next"`}, "https://api.example.com/items?page=2"},
+		{[]string{`<https://a/?page=1>; rel="prev", <https://a/?page=3>; rel="next", <https://a/?page=9>; rel="last"`}, "https://a/?page=3"},
+		{[]string{`<https://a/?page=1>; rel="first"`, `</items?page=2>; title="x"; rel="next last"`}, "/items?page=2"},
+		{[]string{`<https://a/?page=9>; rel=last`}, ""},
+		{[]string{`https://a/?page=2; rel="next"`}, ""}, // malformed
+	}
+	for _, test := range tests {
+		a.Equal(test.next, ParseLinkNext(http.Header{"Link": test.links}), "Parse next link")
+	}
+}
+
+// pagesHandler serves n pages linked by relative next links,
+// failing the first try of every page with a 503.
+func pagesHandler(n int) http.Handler {
+	var fails int32
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var page int
+		fmt.Sscanf(r.URL.Query().Get("page"), "%d", &page)
+		if atomic.AddInt32(&fails, 1)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if page < n {
+			w.Header().Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next"`, page+1))
+		}
+		fmt.Fprintf(w, "page%d", page)
+	})
+}
+
+func TestSafeClient_GetAllPages(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(pagesHandler(3))
+	defer server.Close()
+
+	var got []string
+	err := testTimeoutClient.GetAllPages(context.Background(), server.URL+"/items?page=1", 3, nil, func(status int, body []byte, header http.Header) error {
+		a.Equal(http.StatusOK, status, "Retried to success")
+		got = append(got, string(body))
+		return nil
+	})
+	a.NoError(err, "All pages fetched")
+	a.Equal("page1,page2,page3", strings.Join(got, ","), "In order and terminated")
+
+	// stop early
+	got = nil
+	err = testTimeoutClient.GetAllPages(context.Background(), server.URL+"/items?page=1", 3, nil, func(status int, body []byte, header http.Header) error {
+		got = append(got, string(body))
+		if len(got) == 2 {
+			return ErrStop
+		}
+		return nil
+	})
+	a.NoError(err, "ErrStop is not an error")
+	a.Equal(2, len(got), "Stopped at the second page")
+
+	// callback error
+	errPage := errors.New("bad page")
+	err = testTimeoutClient.GetAllPages(context.Background(), server.URL+"/items?page=1", 3, nil, func(status int, body []byte, header http.Header) error {
+		return errPage
+	})
+	a.Equal(errPage, err, "Callback error returned")
+}
+
+func TestSafeClient_GetAllPages_Loop(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	// every page links back to itself
+	loop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `</loop>; rel="next"`)
+	}))
+	defer loop.Close()
+
+	pages := 0
+	err := testTimeoutClient.GetAllPages(context.Background(), loop.URL+"/loop", 1, nil, func(int, []byte, http.Header) error {
+		pages++
+		return nil
+	})
+	a.True(err != nil, "Loop detected")
+	a.Equal(1, pages, "Revisit stops")
+
+	// endless distinct pages
+	cl := testTimeoutClient
+	cl.MaxPages = 5
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", fmt.Sprintf(`<%s0>; rel="next"`, r.URL.Path))
+	}))
+	defer server.Close()
+
+	pages = 0
+	err = cl.GetAllPages(context.Background(), server.URL+"/p", 1, nil, func(int, []byte, http.Header) error {
+		pages++
+		return nil
+	})
+	a.True(err != nil, "Max pages reached")
+	a.Equal(5, pages, "Bounded by MaxPages")
+}
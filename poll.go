@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// PollUntil calls fn until it reports done, sleeping by b in between;
+// errors from fn are taken as transient unless marked by Permanent.
+// It returns nil when done, the permanent error, or ctx.Err()
+// once ctx is done.
+func PollUntil(ctx context.Context, b Backoff, fn func(ctx context.Context) (done bool, err error)) error {
+	return pollUntil(ctx, b, sleepContext, fn)
+}
+
+func pollUntil(ctx context.Context, b Backoff, sleep Sleeper, fn func(ctx context.Context) (done bool, err error)) error {
+	wait := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		done, err := fn(ctx)
+		if err != nil {
+			if err, ok := unwrapPermanent(err); ok {
+				return err
+			}
+		} else if done {
+			return nil
+		}
+
+		wait = b.Next(wait)
+		if err := sleep(ctx, time.Duration(wait)*time.Millisecond); err != nil {
+			return err
+		}
+	}
+}
+
+// PollGet GETs url (with retries) until done reports true for the
+// response, sleeping by the client's Backoff between polls,
+// and returns the last response; see PollUntil.
+func (c *SafeClient) PollGet(ctx context.Context, url string, maxTries int, f RequestHook, done func(status int, body []byte) bool) (status int, body []byte, err error) {
+	err = pollUntil(ctx, c.Backoff, c.sleep, func(ctx context.Context) (bool, error) {
+		_, res, err := c.doRequest(ctx, "GET", url, nil, maxTries, f)
+		if err != nil {
+			if !c.retryable(err) {
+				return false, Permanent(err)
+			}
+			return false, err
+		}
+		status, body = res.Status, res.Body
+		return done(status, body), nil
+	})
+	return
+}
+
+// LongPoll GETs url over and over for a long-poll endpoint: a 2xx response is
+// handed to onMessage (except 204, taken as an empty timeout) and the request
+// is re-issued at once; a network error or retryable status backs off before
+// the next, whether TimeoutOnly is set or not.
+// It exits when ctx is done, on an unexpected status (as *HTTPError), an error
+// never retried (e.g., ErrBlockedAddress) or when onMessage returns an error
+// (ErrStop ends it without one).
+// Requests are bound by LongPollTimeout instead of the client timeout.
+func (c *SafeClient) LongPoll(ctx context.Context, url string, f RequestHook, onMessage func(status int, body []byte) error) error {
+	lc := *c
+	lc.Timeout = c.LongPollTimeout
+	lc.TimeoutOnly = false // a dropped connection is polled again
+	wait := 0
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		req, err := newRequest(ctx, "GET", url, nil)
+		if err != nil {
+			return err
+		}
+		if err = applyHook(f, req); err != nil {
+			return err
+		}
+
+		res, err := lc.requestWithClose(req)
+		if err = lc.classify(res.Status, res.Header, err); err == nil {
+			wait = 0
+			if res.Status == http.StatusNoContent {
+				continue
+			}
+			if res.Status < 200 || res.Status > 299 {
+				return &HTTPError{res.Status, res.Body}
+			}
+			if err = onMessage(res.Status, res.Body); err != nil {
+				if err == ErrStop {
+					return nil
+				}
+				return err
+			}
+			continue
+		}
+
+		if err, ok := unwrapPermanent(err); ok {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		wait = c.Next(wait)
+		d, clamped := clampDeadline(ctx, c.wait(time.Duration(wait)*time.Millisecond, err))
+		if err = c.sleep(ctx, d); err != nil {
+			return err
+		}
+		if clamped {
+			return context.DeadlineExceeded
+		}
+	}
+}
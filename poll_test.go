@@ -0,0 +1,195 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestPollUntil(t *testing.T) {
+	a := assert.NewAssert(t)
+	b := Backoff{1, 2}
+	ctx := context.Background()
+
+	calls := 0
+	err := PollUntil(ctx, b, func(ctx context.Context) (bool, error) {
+		calls++
+		if calls == 2 {
+			return false, errors.New("transient")
+		}
+		return calls == 3, nil
+	})
+	a.NoError(err, "Done eventually")
+	a.Equal(3, calls, "Polled through a transient error")
+
+	errFatal := errors.New("fatal")
+	calls = 0
+	err = PollUntil(ctx, b, func(ctx context.Context) (bool, error) {
+		calls++
+		return false, Permanent(errFatal)
+	})
+	a.Equal(errFatal, err, "Permanent error unwrapped")
+	a.Equal(1, calls, "Stops at once")
+
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	err = PollUntil(tctx, b, func(ctx context.Context) (bool, error) {
+		return false, nil
+	})
+	a.Equal(context.DeadlineExceeded, err, "Gives up with the context")
+}
+
+func TestSafeClient_PollGet(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&polls, 1) <= 2 {
+			w.Write([]byte("pending"))
+			return
+		}
+		w.Write([]byte("complete"))
+	}))
+	defer server.Close()
+
+	sleeper := &fakeSleeper{}
+	cl := testTimeoutClient
+	cl.Backoff = Backoff{100, 5000}
+	cl.Sleep = sleeper.Sleep
+
+	start := time.Now()
+	status, body, err := cl.PollGet(context.Background(), server.URL, 3, nil, func(status int, body []byte) bool {
+		return string(body) == "complete"
+	})
+	a.NoError(err, "Job completes")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal("complete", string(body), "Returns last body")
+	a.Equal(int32(3), atomic.LoadInt32(&polls), "Polled three times")
+
+	waits := sleeper.Waits()
+	a.Equal(2, len(waits), "Slept between polls")
+	for _, w := range waits {
+		a.True(w >= 100*time.Millisecond && w <= 5*time.Second, "Wait follows Backoff")
+	}
+	a.True(time.Since(start) < time.Second, "Never slept for real")
+
+	// cancelled while pending
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = cl.PollGet(ctx, server.URL, 3, nil, func(int, []byte) bool { return false })
+	a.True(errors.Is(err, context.Canceled), "Cancelled")
+}
+
+func TestSafeClient_LongPoll(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	// event, empty timeout, error, event
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&polls, 1) {
+		case 1:
+			w.Write([]byte("e1"))
+		case 2:
+			w.WriteHeader(http.StatusNoContent)
+		case 3:
+			w.WriteHeader(http.StatusBadGateway)
+		case 4:
+			// hangs longer than the client timeout
+			time.Sleep(30 * time.Millisecond)
+			w.Write([]byte("e2"))
+		default:
+			w.Write([]byte("e3"))
+		}
+	}))
+	defer server.Close()
+
+	sleeper := &fakeSleeper{}
+	cl := testTimeoutClient
+	cl.Sleep = sleeper.Sleep
+
+	var got []string
+	err := cl.LongPoll(context.Background(), server.URL, nil, func(status int, body []byte) error {
+		got = append(got, string(body))
+		if len(got) == 2 {
+			return ErrStop
+		}
+		return nil
+	})
+	a.NoError(err, "Stopped by ErrStop")
+	a.Equal([]string{"e1", "e2"}, got, "Messages in order")
+	a.Equal(int32(4), atomic.LoadInt32(&polls), "Re-issued after each response")
+	a.Equal(1, len(sleeper.Waits()), "Backed off after the 5xx only")
+
+	// stop with an error
+	errStop := errors.New("stop")
+	err = cl.LongPoll(context.Background(), server.URL, nil, func(int, []byte) error {
+		return errStop
+	})
+	a.Equal(errStop, err, "Callback error returned")
+}
+
+func TestSafeClient_LongPoll_NetworkError(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	// the first connection is dropped, then an event
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&polls, 1) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write([]byte("e1"))
+	}))
+	defer server.Close()
+
+	sleeper := &fakeSleeper{}
+	cl := testTimeoutClient // TimeoutOnly
+	cl.Sleep = sleeper.Sleep
+
+	var got []string
+	err := cl.LongPoll(context.Background(), server.URL, nil, func(status int, body []byte) error {
+		got = append(got, string(body))
+		return ErrStop
+	})
+	a.NoError(err, "Polled again after the network error")
+	a.Equal([]string{"e1"}, got, "Message after the error")
+	a.Equal(1, len(sleeper.Waits()), "Backed off after the network error")
+}
+
+func TestSafeClient_LongPoll_Exit(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	hang := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hang.Close()
+
+	// cancelled while hanging
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := testTimeoutClient.LongPoll(ctx, hang.URL, nil, func(int, []byte) error {
+		t.Error("No message")
+		return nil
+	})
+	a.Equal(context.DeadlineExceeded, err, "Exits with the context")
+
+	// unexpected status
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	err = testTimeoutClient.LongPoll(context.Background(), notFound.URL, nil, func(int, []byte) error {
+		return nil
+	})
+	var herr *HTTPError
+	a.True(errors.As(err, &herr), "Returns *HTTPError")
+	if herr != nil {
+		a.Equal(http.StatusNotFound, herr.StatusCode, "Reports status")
+	}
+}
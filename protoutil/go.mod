@@ -0,0 +1,9 @@
+module github.com/ShevaXu/web-utils/protoutil
+
+go 1.24
+
+require github.com/ShevaXu/web-utils v0.0.0
+
+require google.golang.org/protobuf v1.36.12
+
+replace github.com/ShevaXu/web-utils => ../
@@ -0,0 +1,53 @@
+// Package protoutil adds Protobuf bodies to SafeClient. It is a module
+// of its own, which keeps google.golang.org/protobuf out of the
+// dependencies of the core package.
+package protoutil
+
+import (
+	"context"
+	"fmt"
+
+	utils "github.com/ShevaXu/web-utils"
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentType is sent with and asked for Protobuf bodies.
+const ContentType = "application/x-protobuf"
+
+// Codec is the utils.Codec of proto.Message values.
+var Codec utils.Codec = codec{}
+
+type codec struct{}
+
+func (codec) ContentType() string {
+	return ContentType
+}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protoutil: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protoutil: %T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// PostProtoWithRetry POSTs in as Protobuf with retries like
+// utils.SafeClient.PostWithCodec, and unmarshals a 2xx body into out
+// (if not nil); an empty body leaves out untouched, and any other final
+// status, whose body need not be Protobuf, is a *utils.HTTPError
+// carrying the raw bytes.
+func PostProtoWithRetry(c *utils.SafeClient, url string, in, out proto.Message, maxTries int, f utils.RequestHook) (tries, status int, err error) {
+	var o interface{}
+	if out != nil {
+		o = out
+	}
+	return c.PostWithCodec(context.Background(), Codec, url, in, o, maxTries, f)
+}
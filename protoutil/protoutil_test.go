@@ -0,0 +1,59 @@
+package protoutil_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	utils "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+	. "github.com/ShevaXu/web-utils/protoutil"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestPostProtoWithRetry(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/echo":
+			if r.Header.Get("Content-Type") != ContentType {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Write(body)
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"not proto"}`))
+		}
+	}))
+	defer server.Close()
+
+	cl := utils.StdClient()
+	in, err := structpb.NewStruct(map[string]interface{}{"name": "foo", "count": 3.0})
+	a.NoError(err, "No error")
+
+	out := &structpb.Struct{}
+	_, status, err := PostProtoWithRetry(cl, server.URL+"/echo", in, out, 3, nil)
+	a.NoError(err, "No error")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.True(proto.Equal(in, out), "Round-trip")
+
+	out = &structpb.Struct{}
+	_, status, err = PostProtoWithRetry(cl, server.URL+"/empty", in, out, 3, nil)
+	a.NoError(err, "Empty response")
+	a.Equal(0, len(out.Fields), "Left untouched")
+
+	_, status, err = PostProtoWithRetry(cl, server.URL+"/error", in, nil, 3, nil)
+	herr, ok := err.(*utils.HTTPError)
+	a.True(ok, "Non-2xx is *HTTPError")
+	a.Equal(http.StatusBadRequest, status, "Returns code")
+	if ok {
+		a.Equal(`{"error":"not proto"}`, string(herr.Body), "Raw error body")
+	}
+}
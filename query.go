@@ -0,0 +1,373 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// queryTag is a parsed `url:"name,opts..."` struct tag.
+type queryTag struct {
+	name      string
+	omitempty bool
+	comma     bool // slices joined by "," into one value
+	required  bool
+	def       *string // decoded if the key is missing
+}
+
+// parseQueryTag returns the tag of field f, and false if it is skipped.
+func parseQueryTag(f reflect.StructField) (queryTag, bool) {
+	tag := f.Tag.Get("url")
+	if tag == "-" || f.PkgPath != "" && !f.Anonymous {
+		return queryTag{}, false
+	}
+	parts := strings.Split(tag, ",")
+	qt := queryTag{name: parts[0]}
+	if qt.name == "" {
+		qt.name = f.Name
+	}
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "omitempty":
+			qt.omitempty = true
+		case "comma":
+			qt.comma = true
+		case "required":
+			qt.required = true
+		default:
+			if strings.HasPrefix(opt, "default=") {
+				def := strings.TrimPrefix(opt, "default=")
+				qt.def = &def
+			}
+		}
+	}
+	return qt, true
+}
+
+// EncodeQuery builds url.Values from the exported fields of struct v
+// (or a pointer to one) by their `url:"name,omitempty"` tags; untagged fields
+// use the field name, "-" skips a field and embedded structs are flattened.
+// Supported are strings, ints, uints, bools, floats, time.Time (RFC3339),
+// pointers to them (nil is left out) and slices of them as repeated keys,
+// or as one comma-joined value with the "comma" option.
+func EncodeQuery(v interface{}) (url.Values, error) {
+	values := url.Values{}
+	if v == nil {
+		return values, nil
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return values, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("utils: EncodeQuery of non-struct %s", rv.Type())
+	}
+	return values, encodeStruct(values, rv)
+}
+
+func encodeStruct(values url.Values, rv reflect.Value) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		qt, ok := parseQueryTag(f)
+		if !ok {
+			continue
+		}
+		fv := rv.Field(i)
+
+		if f.Anonymous && f.Tag.Get("url") == "" {
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+				if err := encodeStruct(values, fv); err != nil {
+					return err
+				}
+				continue
+			}
+			if f.PkgPath != "" {
+				continue
+			}
+		}
+
+		// a set pointer is never empty
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		} else if qt.omitempty && fv.IsZero() {
+			continue
+		}
+
+		if fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array {
+			strs := make([]string, fv.Len())
+			for j := range strs {
+				s, err := formatQueryValue(fv.Index(j))
+				if err != nil {
+					return fmt.Errorf("utils: query field %s: %w", f.Name, err)
+				}
+				strs[j] = s
+			}
+			if qt.omitempty && len(strs) == 0 {
+				continue
+			}
+			if qt.comma {
+				values.Add(qt.name, strings.Join(strs, ","))
+			} else {
+				values[qt.name] = append(values[qt.name], strs...)
+			}
+			continue
+		}
+
+		s, err := formatQueryValue(fv)
+		if err != nil {
+			return fmt.Errorf("utils: query field %s: %w", f.Name, err)
+		}
+		values.Add(qt.name, s)
+	}
+	return nil
+}
+
+// formatQueryValue renders one scalar of a supported type.
+func formatQueryValue(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// withQuery adds the query encoded from params to rawurl.
+func withQuery(rawurl string, params interface{}) (string, error) {
+	if params == nil {
+		return rawurl, nil
+	}
+	values, err := EncodeQuery(params)
+	if err != nil {
+		return "", err
+	}
+	if len(values) == 0 {
+		return rawurl, nil
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	for k, vs := range values {
+		q[k] = append(q[k], vs...)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// ErrMissingParam is the Err of a QueryError for a required key.
+var ErrMissingParam = errors.New("required parameter missing")
+
+// QueryError reports a query parameter DecodeQuery cannot set.
+type QueryError struct {
+	Field string // struct field
+	Key   string // query key
+	Value string // raw value, empty if missing
+	Err   error
+}
+
+func (e *QueryError) Error() string {
+	if e.Err == ErrMissingParam {
+		return fmt.Sprintf("utils: query %s (field %s): %v", e.Key, e.Field, e.Err)
+	}
+	return fmt.Sprintf("utils: query %s=%q (field %s): %v", e.Key, e.Value, e.Field, e.Err)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeQuery sets the fields of the struct out points to from values,
+// by the same tags and types as EncodeQuery, which it round-trips with.
+// Two more tag options are supported: "required" fails with ErrMissingParam
+// if the key is missing, and "default=v" decodes v instead (v may not
+// contain a comma). Fields whose key is missing are left untouched;
+// a value of the wrong type fails with a *QueryError.
+func DecodeQuery(values url.Values, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("utils: DecodeQuery needs a non-nil struct pointer, got %T", out)
+	}
+	return decodeStruct(values, rv.Elem())
+}
+
+func decodeStruct(values url.Values, rv reflect.Value) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		qt, ok := parseQueryTag(f)
+		if !ok {
+			continue
+		}
+		fv := rv.Field(i)
+
+		if f.Anonymous && f.Tag.Get("url") == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				if fv.Kind() == reflect.Ptr {
+					if fv.IsNil() {
+						if f.PkgPath != "" {
+							continue // cannot set
+						}
+						fv.Set(reflect.New(ft))
+					}
+					fv = fv.Elem()
+				}
+				if err := decodeStruct(values, fv); err != nil {
+					return err
+				}
+				continue
+			}
+			if f.PkgPath != "" {
+				continue
+			}
+		}
+
+		raw, ok := values[qt.name]
+		if !ok || len(raw) == 0 {
+			switch {
+			case qt.def != nil:
+				raw = []string{*qt.def}
+			case qt.required:
+				return &QueryError{f.Name, qt.name, "", ErrMissingParam}
+			default:
+				continue
+			}
+		}
+
+		if err := setQueryField(fv, raw, qt.comma); err != nil {
+			var qe *QueryError
+			if errors.As(err, &qe) {
+				qe.Field, qe.Key = f.Name, qt.name
+				return qe
+			}
+			return &QueryError{f.Name, qt.name, strings.Join(raw, ","), err}
+		}
+	}
+	return nil
+}
+
+// setQueryField sets v from the raw values of its key.
+func setQueryField(v reflect.Value, raw []string, comma bool) error {
+	if v.Kind() == reflect.Ptr {
+		elem := reflect.New(v.Type().Elem())
+		if err := setQueryField(elem.Elem(), raw, comma); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	if v.Kind() == reflect.Slice && v.Type() != timeType {
+		if comma {
+			var split []string
+			for _, s := range raw {
+				split = append(split, strings.Split(s, ",")...)
+			}
+			raw = split
+		}
+		slice := reflect.MakeSlice(v.Type(), len(raw), len(raw))
+		for i, s := range raw {
+			if err := parseQueryValue(slice.Index(i), s); err != nil {
+				return &QueryError{Value: s, Err: err}
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+
+	if err := parseQueryValue(v, raw[0]); err != nil {
+		return &QueryError{Value: raw[0], Err: err}
+	}
+	return nil
+}
+
+// parseQueryValue sets one scalar of a supported type from s.
+func parseQueryValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		elem := reflect.New(v.Type().Elem())
+		if err := parseQueryValue(elem.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if v.Type() == timeType {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package utils_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+type Paging struct {
+	Page    int `url:"page,omitempty"`
+	PerPage int `url:"per_page,omitempty"`
+}
+
+type searchParams struct {
+	Paging
+	Query    string    `url:"q"`
+	Tags     []string  `url:"tag,omitempty"`
+	IDs      []int     `url:"ids,comma,omitempty"`
+	Exact    bool      `url:"exact,omitempty"`
+	Score    float64   `url:"score,omitempty"`
+	Since    time.Time `url:"since,omitempty"`
+	Limit    *uint     `url:"limit"`
+	Verbose  *bool     `url:"verbose,omitempty"`
+	Raw      string
+	Skipped  string `url:"-"`
+	internal string
+}
+
+func TestEncodeQuery(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	limit := uint(10)
+	no := false
+	since := time.Date(2017, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		v   interface{}
+		exp url.Values
+	}{
+		{nil, url.Values{}},
+		{(*searchParams)(nil), url.Values{}},
+		{searchParams{}, url.Values{"q": {""}, "Raw": {""}}},
+		{&searchParams{
+			Paging:   Paging{Page: 2},
+			Query:    "go http",
+			Tags:     []string{"a", "b"},
+			IDs:      []int{1, 2, 3},
+			Exact:    true,
+			Score:    0.5,
+			Since:    since,
+			Limit:    &limit,
+			Verbose:  &no,
+			Raw:      "x",
+			Skipped:  "y",
+			internal: "z",
+		}, url.Values{
+			"page":    {"2"},
+			"q":       {"go http"},
+			"tag":     {"a", "b"},
+			"ids":     {"1,2,3"},
+			"exact":   {"true"},
+			"score":   {"0.5"},
+			"since":   {"2017-09-01T12:00:00Z"},
+			"limit":   {"10"},
+			"verbose": {"false"}, // a set pointer is not empty
+			"Raw":     {"x"},
+		}},
+	}
+	for _, test := range tests {
+		values, err := EncodeQuery(test.v)
+		a.NoError(err, "No error")
+		a.Equal(test.exp, values, "Encoded")
+	}
+}
+
+func TestEncodeQuery_Unsupported(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	tests := []interface{}{
+		"not a struct",
+		42,
+		struct{ M map[string]string }{map[string]string{}},
+		struct{ C chan int }{},
+		struct{ S []struct{ X int } }{[]struct{ X int }{{1}}},
+		struct {
+			N struct{ X int } `url:"n"`
+		}{},
+	}
+	for _, v := range tests {
+		_, err := EncodeQuery(v)
+		a.True(err != nil, "Unsupported type fails")
+	}
+}
+
+func TestSafeClient_GetWithRetry(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RawQuery))
+	}))
+	defer server.Close()
+
+	_, status, body, err := testTimeoutClient.GetWithRetry(server.URL+"?sort=asc", &searchParams{Query: "go"}, 3, nil)
+	a.NoError(err, "No error")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal("Raw=&q=go&sort=asc", string(body), "Params merged into the query")
+
+	_, _, body, err = testTimeoutClient.GetWithRetry(server.URL+"?sort=asc", nil, 3, nil)
+	a.NoError(err, "No error")
+	a.Equal("sort=asc", string(body), "No params")
+
+	_, _, _, err = testTimeoutClient.GetWithRetry(server.URL, 42, 3, nil)
+	a.True(err != nil, "Bad params fail")
+}
+
+type listParams struct {
+	Page   int      `url:"page,default=1"`
+	Sort   string   `url:"sort,default=asc"`
+	Token  string   `url:"token,required"`
+	Tags   []string `url:"tag"`
+	IDs    []int64  `url:"ids,comma"`
+	Ratio  *float32 `url:"ratio"`
+	Strict bool     `url:"strict"`
+}
+
+func TestDecodeQuery(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	values, _ := url.ParseQuery("token=abc&tag=a&tag=b&ids=1,2&ids=3&ratio=0.25&strict=true")
+	var p listParams
+	err := DecodeQuery(values, &p)
+	a.NoError(err, "No error")
+	a.Equal(1, p.Page, "Default applied")
+	a.Equal("asc", p.Sort, "Default applied")
+	a.Equal("abc", p.Token, "Required present")
+	a.Equal([]string{"a", "b"}, p.Tags, "Repeated keys")
+	a.Equal([]int64{1, 2, 3}, p.IDs, "Comma-joined values")
+	a.True(p.Ratio != nil && *p.Ratio == 0.25, "Pointer allocated")
+	a.Equal(true, p.Strict, "Bool parsed")
+
+	// left untouched if missing
+	p = listParams{Tags: []string{"keep"}}
+	DecodeQuery(url.Values{"token": {"x"}}, &p)
+	a.Equal([]string{"keep"}, p.Tags, "Missing key untouched")
+}
+
+func TestDecodeQuery_Errors(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	tests := []struct {
+		query string
+		field string
+		value string
+	}{
+		{"page=1", "Token", ""},
+		{"token=x&page=two", "Page", "two"},
+		{"token=x&ids=1,b,3", "IDs", "b"},
+		{"token=x&ratio=NaNx", "Ratio", "NaNx"},
+		{"token=x&strict=maybe", "Strict", "maybe"},
+	}
+	for _, test := range tests {
+		values, _ := url.ParseQuery(test.query)
+		var p listParams
+		err := DecodeQuery(values, &p)
+		var qe *QueryError
+		if !errors.As(err, &qe) {
+			t.Errorf("Should be a *QueryError for %s", test.query)
+			continue
+		}
+		a.Equal(test.field, qe.Field, "Reports the field")
+		a.Equal(test.value, qe.Value, "Reports the raw value")
+	}
+
+	var p listParams
+	err := DecodeQuery(url.Values{}, &p)
+	a.True(errors.Is(err, ErrMissingParam), "Missing required field")
+
+	a.True(DecodeQuery(url.Values{}, p) != nil, "Needs a pointer")
+	a.True(DecodeQuery(url.Values{"m": {"1"}}, &struct {
+		M map[string]int `url:"m"`
+	}{}) != nil, "Unsupported type")
+}
+
+func TestDecodeQuery_RoundTrip(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	limit := uint(7)
+	in := searchParams{
+		Paging: Paging{Page: 3, PerPage: 50},
+		Query:  "a b&c",
+		Tags:   []string{"x", "y"},
+		IDs:    []int{4, 5},
+		Exact:  true,
+		Score:  1.5,
+		Since:  time.Date(2017, 9, 1, 12, 0, 0, 0, time.UTC),
+		Limit:  &limit,
+		Raw:    "r",
+	}
+	values, err := EncodeQuery(in)
+	a.NoError(err, "Encoded")
+
+	// through the wire format
+	parsed, _ := url.ParseQuery(values.Encode())
+	var out searchParams
+	a.NoError(DecodeQuery(parsed, &out), "Decoded")
+	a.Equal(in, out, "Round trip")
+}
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter bounds events to a rate, e.g., the requests per second
+// a partner allows, with bursts of up to its burst size, as a bucket of
+// tokens refilled by the time passed on each call (so no goroutine runs
+// in the background); it is safe for concurrent use.
+type RateLimiter struct {
+	rate  float64 // tokens per second, unlimited if not positive
+	burst float64
+
+	// Now and After, if set, stand in for time.Now and time.After;
+	// set them before any use.
+	Now   func() time.Time
+	After func(d time.Duration) <-chan time.Time
+
+	mu     sync.Mutex
+	tokens float64 // negative for those reserved ahead
+	last   time.Time
+}
+
+// NewRateLimiter returns a RateLimiter of perSecond events with bursts
+// of burst (at least one), starting full; perSecond <= 0 is no limit.
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{rate: perSecond, burst: float64(burst), tokens: float64(burst)}
+}
+
+func (l *RateLimiter) now() time.Time {
+	if l.Now != nil {
+		return l.Now()
+	}
+	return time.Now()
+}
+
+func (l *RateLimiter) after(d time.Duration) <-chan time.Time {
+	if l.After != nil {
+		return l.After(d)
+	}
+	return time.After(d)
+}
+
+// advance refills the bucket by the time passed till now,
+// never by a clock set back; l.mu must be held.
+func (l *RateLimiter) advance(now time.Time) {
+	if l.last.IsZero() {
+		l.last = now
+		return
+	}
+	if now.After(l.last) {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+	}
+}
+
+// reserve takes a token, returns how long till it is due;
+// l.mu must be held.
+func (l *RateLimiter) reserve() time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+	l.advance(l.now())
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// giveBack returns a token reserved but not used; l.mu must be held.
+func (l *RateLimiter) giveBack() {
+	if l.rate <= 0 {
+		return
+	}
+	if l.tokens++; l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+// Allow takes a token if one is there, returns true if it does.
+func (l *RateLimiter) Allow() bool {
+	if l.rate <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(l.now())
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// Reserve takes a token, ahead of time if none is there,
+// returns how long to wait before the event it is for.
+func (l *RateLimiter) Reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.reserve()
+}
+
+// Wait blocks until a token is due, or returns the error of ctx,
+// giving the token back; it fails at once if the deadline of ctx
+// comes before the token.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	d := l.reserve()
+	if deadline, ok := ctx.Deadline(); ok && d > 0 && deadline.Sub(l.now()) < d {
+		l.giveBack()
+		l.mu.Unlock()
+		return context.DeadlineExceeded
+	}
+	l.mu.Unlock()
+	if d == 0 {
+		return nil
+	}
+
+	select {
+	case <-l.after(d):
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.giveBack()
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,179 @@
+package utils_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+// fakeClock is a clock moved by hand, firing its timers as it goes.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+	waits  []time.Duration // of every After
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+// newFakeClockAhead returns a fakeClock an hour ahead of the real one,
+// so deadlines made from it, which expire by the real one, are not due.
+func newFakeClockAhead() *fakeClock {
+	return &fakeClock{now: time.Now().Add(time.Hour)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+		} else {
+			t.ch <- c.now
+		}
+	}
+	c.timers = pending
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, fakeTimer{c.now.Add(d), ch})
+	c.waits = append(c.waits, d)
+	return ch
+}
+
+// Waits returns the durations After was called with.
+func (c *fakeClock) Waits() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
+
+// awaitTimers blocks until n timers of c were made.
+func (c *fakeClock) awaitTimers(n int) {
+	for len(c.Waits()) < n {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	clock := newFakeClock()
+	l := NewRateLimiter(10, 5)
+	l.Now = clock.Now
+	for i := 0; i < 5; i++ {
+		a.True(l.Allow(), "Allowed in burst")
+	}
+	a.True(!l.Allow(), "Burst used up")
+
+	clock.Add(100 * time.Millisecond)
+	a.True(l.Allow(), "Refilled one")
+	a.True(!l.Allow(), "Only one")
+
+	clock.Add(-time.Second)
+	a.True(!l.Allow(), "No refill by a clock set back")
+
+	clock.Add(time.Hour)
+	n := 0
+	for l.Allow() {
+		n++
+	}
+	a.Equal(5, n, "Refilled up to burst")
+
+	a.True(NewRateLimiter(0, 1).Allow() && NewRateLimiter(0, 1).Allow(), "No limit")
+}
+
+func TestRateLimiter_Rate(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	clock := newFakeClock()
+	l := NewRateLimiter(10, 5)
+	l.Now = clock.Now
+	for l.Allow() {
+	}
+
+	n := 0
+	for i := 0; i < 400; i++ {
+		clock.Add(25 * time.Millisecond)
+		for l.Allow() {
+			n++
+		}
+	}
+	a.True(n >= 99 && n <= 100, "Steady rate in 10s")
+}
+
+func TestRateLimiter_Reserve(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	clock := newFakeClock()
+	l := NewRateLimiter(10, 1)
+	l.Now = clock.Now
+	a.Equal(time.Duration(0), l.Reserve(), "Due now")
+	a.Equal(100*time.Millisecond, l.Reserve(), "Due after one")
+	a.Equal(200*time.Millisecond, l.Reserve(), "Due after two")
+	a.True(!l.Allow(), "Reserved ahead")
+
+	clock.Add(300 * time.Millisecond)
+	a.True(l.Allow(), "Paid back")
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	clock := newFakeClockAhead()
+	l := NewRateLimiter(1, 1)
+	l.Now, l.After = clock.Now, clock.After
+	a.Nil(l.Wait(context.Background()), "No wait in burst")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	a.Equal(context.Canceled, l.Wait(ctx), "Cancelled")
+	a.True(time.Since(start) < time.Second, "Returns on cancel")
+	a.Equal(time.Second, l.Reserve(), "Token given back")
+
+	ctx, cancel = context.WithDeadline(context.Background(), clock.Now().Add(time.Second))
+	defer cancel()
+	a.Equal(context.DeadlineExceeded, l.Wait(ctx), "Deadline before token by the clock")
+	a.Equal(1, len(clock.Waits()), "Fails at once")
+
+	ctx, cancel = context.WithDeadline(context.Background(), clock.Now().Add(3*time.Second))
+	defer cancel()
+	errs := make(chan error)
+	go func() {
+		errs <- l.Wait(ctx)
+	}()
+	clock.awaitTimers(2)
+	a.Equal(2*time.Second, clock.Waits()[1], "Waits by the clock")
+	clock.Add(2 * time.Second)
+	a.Nil(<-errs, "Token before deadline by the clock")
+
+	l = NewRateLimiter(100, 1)
+	l.Allow()
+	a.Nil(l.Wait(context.Background()), "Waited for token")
+}
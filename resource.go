@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultResourceTries is the MaxTries of a Resource made by SafeClient.Resource.
+const DefaultResourceTries = 3
+
+// Resource is a REST collection at Base (e.g., "https://host/things"),
+// whose items are at Base/{id}, exchanged as JSON over the retrying
+// methods of Client. A final non-2xx response is an *HTTPError,
+// e.g., StatusCode 404 for a missing item.
+type Resource struct {
+	Client   *SafeClient
+	Base     string
+	MaxTries int
+	Hook     RequestHook // applied to every request, if set
+}
+
+// Resource returns a Resource at base over c with DefaultResourceTries.
+func (c *SafeClient) Resource(base string) *Resource {
+	return &Resource{Client: c, Base: base, MaxTries: DefaultResourceTries}
+}
+
+// item returns the URL of the item id, escaped as one path segment.
+func (r *Resource) item(id string) string {
+	return strings.TrimRight(r.Base, "/") + "/" + url.PathEscape(id)
+}
+
+func (r *Resource) do(ctx context.Context, method, url string, in, out interface{}) error {
+	var content []byte
+	f := r.Hook
+	if in != nil {
+		var err error
+		if content, err = JSONCodec.Marshal(in); err != nil {
+			return err
+		}
+		f = func(req *http.Request) {
+			req.Header.Set("Content-Type", JSONCodec.ContentType())
+			if r.Hook != nil {
+				r.Hook(req)
+			}
+		}
+	}
+	_, _, err := r.Client.doCodec(ctx, JSONCodec, method, url, content, out, r.MaxTries, f)
+	return err
+}
+
+// List GETs the collection, with query (see EncodeQuery) if not nil,
+// into out.
+func (r *Resource) List(ctx context.Context, query, out interface{}) error {
+	u, err := withQuery(r.Base, query)
+	if err != nil {
+		return err
+	}
+	return r.do(ctx, "GET", u, nil, out)
+}
+
+// Get GETs the item id into out.
+func (r *Resource) Get(ctx context.Context, id string, out interface{}) error {
+	return r.do(ctx, "GET", r.item(id), nil, out)
+}
+
+// Create POSTs in to the collection and decodes the response into out
+// (if not nil).
+func (r *Resource) Create(ctx context.Context, in, out interface{}) error {
+	return r.do(ctx, "POST", r.Base, in, out)
+}
+
+// Update PUTs in as the item id and decodes the response into out
+// (if not nil).
+func (r *Resource) Update(ctx context.Context, id string, in, out interface{}) error {
+	return r.do(ctx, "PUT", r.item(id), in, out)
+}
+
+// Delete DELETEs the item id.
+func (r *Resource) Delete(ctx context.Context, id string) error {
+	return r.do(ctx, "DELETE", r.item(id), nil, nil)
+}
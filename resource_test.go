@@ -0,0 +1,127 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+type thing struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// thingStore is an in-memory REST handler of /things.
+type thingStore struct {
+	mu     sync.Mutex
+	things map[string]thing
+}
+
+func (s *thingStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the escaped id is one segment
+	id := strings.TrimPrefix(r.URL.EscapedPath(), "/things")
+	id = strings.TrimPrefix(id, "/")
+	if strings.Contains(id, "/") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var in thing
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&in)
+	}
+	reply := func(v interface{}) {
+		json.NewEncoder(w).Encode(v)
+	}
+
+	switch {
+	case id == "" && r.Method == "GET":
+		list := []thing{}
+		for _, t := range s.things {
+			if q := r.URL.Query().Get("name"); q == "" || q == t.Name {
+				list = append(list, t)
+			}
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+		reply(list)
+	case id == "" && r.Method == "POST":
+		if _, ok := s.things[in.ID]; ok {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		s.things[in.ID] = in
+		w.WriteHeader(http.StatusCreated)
+		reply(in)
+	default:
+		key, _ := url.PathUnescape(id)
+		t, ok := s.things[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case "GET":
+			reply(t)
+		case "PUT":
+			in.ID = key
+			s.things[key] = in
+			reply(in)
+		case "DELETE":
+			delete(s.things, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+func TestResource(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(&thingStore{things: map[string]thing{}})
+	defer server.Close()
+
+	ctx := context.Background()
+	things := testTimeoutClient.Resource(server.URL + "/things")
+
+	var out thing
+	a.NoError(things.Create(ctx, thing{"a/1", "foo"}, &out), "Created")
+	a.Equal(thing{"a/1", "foo"}, out, "Create decoded")
+	a.NoError(things.Create(ctx, thing{"b 2", "bar"}, nil), "Created")
+
+	err := things.Create(ctx, thing{"a/1", "dup"}, nil)
+	herr, ok := err.(*HTTPError)
+	a.True(ok && herr.StatusCode == http.StatusConflict, "409 on a duplicate")
+
+	out = thing{}
+	a.NoError(things.Get(ctx, "a/1", &out), "Got")
+	a.Equal("foo", out.Name, "ID escaped as one segment")
+
+	var list []thing
+	a.NoError(things.List(ctx, nil, &list), "Listed")
+	a.Equal(2, len(list), "All listed")
+	a.NoError(things.List(ctx, struct {
+		Name string `url:"name"`
+	}{"bar"}, &list), "Listed")
+	a.Equal([]thing{{"b 2", "bar"}}, list, "Query applied")
+
+	a.NoError(things.Update(ctx, "b 2", thing{Name: "baz"}, &out), "Updated")
+	a.Equal(thing{"b 2", "baz"}, out, "Update decoded")
+
+	a.NoError(things.Delete(ctx, "b 2"), "Deleted")
+	err = things.Get(ctx, "b 2", &out)
+	herr, ok = err.(*HTTPError)
+	a.True(ok && herr.StatusCode == http.StatusNotFound, "404 once deleted")
+	err = things.Delete(ctx, "b 2")
+	herr, ok = err.(*HTTPError)
+	a.True(ok && herr.StatusCode == http.StatusNotFound, "404 on a missing item")
+}
@@ -0,0 +1,316 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sleeper waits for d or until ctx is done, returning ctx.Err() then;
+// it can be replaced in tests to avoid waiting for real.
+type Sleeper func(ctx context.Context, d time.Duration) error
+
+// sleepContext is the default Sleeper.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sleep waits with the client's Sleeper.
+func (c *SafeClient) sleep(ctx context.Context, d time.Duration) error {
+	if c.Sleep != nil {
+		return c.Sleep(ctx, d)
+	}
+	return sleepContext(ctx, d)
+}
+
+// permanentError marks an error not worth retrying.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent wraps err so that the retrying helpers give up at once
+// and return err itself; it returns nil for nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+// unwrapPermanent tells if err is marked by Permanent
+// and returns the error it wraps if so.
+func unwrapPermanent(err error) (error, bool) {
+	var p *permanentError
+	if errors.As(err, &p) {
+		return p.err, true
+	}
+	return err, false
+}
+
+// retryStatusError tells the retry loop that the response's status
+// asks for another try; callers get the response, not the error.
+type retryStatusError struct {
+	status int
+	header http.Header
+}
+
+func (e *retryStatusError) Error() string {
+	return fmt.Sprintf("utils: should retry status %d", e.status)
+}
+
+// AttemptInfo describes a failed try about to be retried.
+type AttemptInfo struct {
+	// Tries is the number of retries so far, 0 after the first try.
+	Tries int
+	// Status is the should-retry status of the response, if any.
+	Status int
+	// Err is the error of the try, nil for a should-retry status.
+	Err error
+	// Wait is the effective sleep before the next try,
+	// after Retry-After and the MaxWait and deadline clamps.
+	Wait time.Duration
+}
+
+// noRetryKey marks a context by WithNoRetry.
+type noRetryKey struct{}
+
+// WithNoRetry returns a copy of ctx under which the retrying methods
+// (and Retry) try once only, whatever their maxTries, e.g., for
+// a request that must not be repeated; tries is reported as 0 then.
+func WithNoRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+// Retry calls op up to maxTries times, sleeping by b in between
+// (cancelled with ctx), until it returns nil or an error marked by Permanent.
+// Like the SafeClient methods it reports tries as the number of retries,
+// i.e., 0 if the first call is final, and returns the last error,
+// the permanent one unwrapped, or ctx.Err() if ctx is done while sleeping.
+// No sleep outlasts the deadline of ctx; one that would ends the
+// retries with context.DeadlineExceeded once the deadline is reached.
+func Retry(ctx context.Context, b Backoff, maxTries int, op func(ctx context.Context) error) (tries int, err error) {
+	return retrier{backoff: b, sleep: sleepContext}.run(ctx, maxTries, op)
+}
+
+// retrier is the one loop behind every retrying method.
+type retrier struct {
+	backoff Backoff
+	sleep   Sleeper
+	// wait, if set, adjusts the backoff wait d after a try failed with err.
+	wait func(d time.Duration, err error) time.Duration
+	// onRetry, if set, is called before sleeping the final wait.
+	onRetry func(tries int, wait time.Duration, err error)
+	// giveUp, if set, is called when the last try fails with err.
+	giveUp func(err error)
+}
+
+func (r retrier) run(ctx context.Context, maxTries int, op func(ctx context.Context) error) (tries int, err error) {
+	if maxTries > 1 && ctx.Value(noRetryKey{}) != nil {
+		maxTries = 1
+	}
+	// 0 will trigger setting wait to base
+	wait := 0
+
+	for ; tries < maxTries; tries++ {
+		// update next sleep time
+		wait = r.backoff.Next(wait)
+		err = op(ctx)
+		if err == nil {
+			return
+		}
+		if perr, ok := unwrapPermanent(err); ok {
+			return tries, perr
+		}
+		// no sleep after the last try
+		if tries == maxTries-1 {
+			if r.giveUp != nil {
+				r.giveUp(err)
+			}
+			return
+		}
+
+		d := time.Duration(wait) * time.Millisecond
+		if r.wait != nil {
+			d = r.wait(d, err)
+		}
+		d, clamped := clampDeadline(ctx, d)
+		if r.onRetry != nil {
+			r.onRetry(tries, d, err)
+		}
+		if serr := r.sleep(ctx, d); serr != nil {
+			return tries, serr
+		}
+		if clamped {
+			// slept till the deadline, no time left for another try
+			return tries, context.DeadlineExceeded
+		}
+	}
+
+	// never tried
+	tries--
+	return
+}
+
+// clampDeadline bounds d by the time left until the deadline of ctx,
+// if any, and tells if it did.
+func clampDeadline(ctx context.Context, d time.Duration) (time.Duration, bool) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := time.Until(deadline); left < d {
+			if left < 0 {
+				left = 0
+			}
+			return left, true
+		}
+	}
+	return d, false
+}
+
+// retry runs op by the client's Backoff and Sleeper,
+// with the waits adjusted by wait and reported to OnRetry.
+func (c *SafeClient) retry(ctx context.Context, maxTries int, op func(ctx context.Context) error) (tries int, err error) {
+	tries, _, err = c.retryTimed(ctx, maxTries, op)
+	return
+}
+
+// callTiming splits the time of a logical call
+// and tells how it ended.
+type callTiming struct {
+	attempts time.Duration // spent in tries
+	slept    time.Duration // asked of the Sleeper in between
+	gaveUp   bool          // tries ran out on a retryable failure
+}
+
+// retryTimed is retry that also times the call.
+func (c *SafeClient) retryTimed(ctx context.Context, maxTries int, op func(ctx context.Context) error) (tries int, tm callTiming, err error) {
+	first := true
+	r := retrier{
+		backoff: c.Backoff,
+		sleep: func(ctx context.Context, d time.Duration) error {
+			start := time.Now()
+			err := c.sleep(ctx, d)
+			if err != nil {
+				// cut short
+				d = time.Since(start)
+			}
+			tm.slept += d
+			return err
+		},
+		wait:    c.wait,
+		onRetry: c.onRetry,
+		giveUp:  func(error) { tm.gaveUp = true },
+	}
+	if c.MaxTotalBodyBytes > 0 {
+		ctx = context.WithValue(ctx, budgetKey{}, &bodyBudget{c.MaxTotalBodyBytes})
+	}
+	attempt := 0
+	tries, err = r.run(ctx, maxTries, func(ctx context.Context) error {
+		if !first {
+			c.countRetry()
+		}
+		first = false
+		if c.AttemptHeader != "" {
+			ctx = context.WithValue(ctx, attemptKey{}, attempt)
+			attempt++
+		}
+		start := time.Now()
+		defer func() { tm.attempts += time.Since(start) }()
+		return op(ctx)
+	})
+	var rs *retryStatusError
+	if errors.As(err, &rs) {
+		// tries run out, the last response stands
+		err = nil
+	}
+	return
+}
+
+// wait returns the sleep after a try failed with err: the server's
+// Retry-After for a should-retry status if given, else the backoff d
+// scaled by the multiplier of the status or error, bounded by MaxWait if set.
+func (c *SafeClient) wait(d time.Duration, err error) time.Duration {
+	var rs *retryStatusError
+	if errors.As(err, &rs) {
+		if ra, ok := parseRetryAfter(rs.header, time.Now()); ok {
+			d = ra
+		} else if m := c.StatusMultipliers[rs.status]; m > 0 {
+			d = time.Duration(float64(d) * m)
+		}
+	} else if m := c.NetworkErrorMultiplier; m > 0 {
+		d = time.Duration(float64(d) * m)
+	}
+	if c.MaxWait > 0 && d > c.MaxWait {
+		d = c.MaxWait
+	}
+	return d
+}
+
+// onRetry reports a retry to OnRetry, if set.
+func (c *SafeClient) onRetry(tries int, wait time.Duration, err error) {
+	if c.OnRetry == nil {
+		return
+	}
+	info := AttemptInfo{Tries: tries, Err: err, Wait: wait}
+	var rs *retryStatusError
+	if errors.As(err, &rs) {
+		info.Status, info.Err = rs.status, nil
+	}
+	c.OnRetry(info)
+}
+
+// parseRetryAfter reads the Retry-After header, in seconds or
+// as an HTTP-date relative to now; false if absent or invalid.
+func parseRetryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	v := strings.TrimSpace(h.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// classify maps a request's outcome to the error for the retry loop:
+// retryable errors as is, others permanent, and a *retryStatusError
+// carrying the header for a should-retry status.
+func (c *SafeClient) classify(status int, header http.Header, err error) error {
+	if err != nil {
+		if c.retryable(err) {
+			return err
+		}
+		return Permanent(err)
+	}
+	if ShouldRetry(status) || (status == http.StatusTooManyRequests && c.RetryTooManyRequests) {
+		return &retryStatusError{status, header}
+	}
+	return nil
+}
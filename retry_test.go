@@ -0,0 +1,230 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestPermanent(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	a.Nil(Permanent(nil), "Nil stays nil")
+
+	err := errors.New("fatal")
+	perr := Permanent(err)
+	a.Equal(err.Error(), perr.Error(), "Same message")
+	a.True(errors.Is(perr, err), "Wraps err")
+}
+
+func TestRetry(t *testing.T) {
+	a := assert.NewAssert(t)
+	ctx := context.Background()
+	errTemp := errors.New("temporary")
+
+	// succeed on the third call
+	calls := 0
+	n, err := Retry(ctx, testBackoff, 5, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errTemp
+		}
+		return nil
+	})
+	a.NoError(err, "Succeeds eventually")
+	a.Equal(2, n, "Report retried times")
+	a.Equal(3, calls, "Called until success")
+
+	// exhaustion
+	calls = 0
+	n, err = Retry(ctx, testBackoff, 3, func(ctx context.Context) error {
+		calls++
+		return fmt.Errorf("fail %d", calls)
+	})
+	a.Equal("fail 3", err.Error(), "Returns the last error")
+	a.Equal(2, n, "Report retried times")
+
+	// permanent error
+	errFatal := errors.New("fatal")
+	calls = 0
+	n, err = Retry(ctx, testBackoff, 5, func(ctx context.Context) error {
+		calls++
+		if calls == 2 {
+			return fmt.Errorf("wrapped: %w", Permanent(errFatal))
+		}
+		return errTemp
+	})
+	a.Equal(errFatal, err, "Returns the permanent error unwrapped")
+	a.Equal(1, n, "Report retried times")
+	a.Equal(2, calls, "Aborted early")
+
+	// never tried
+	n, err = Retry(ctx, testBackoff, 0, func(ctx context.Context) error {
+		t.Error("Should not be called")
+		return nil
+	})
+	a.NoError(err, "No error")
+	a.Equal(-1, n, "Same as the HTTP methods")
+}
+
+func TestRetry_Cancel(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	calls := 0
+	n, err := Retry(ctx, Backoff{1000, 5000}, 5, func(ctx context.Context) error {
+		calls++
+		return errors.New("temporary")
+	})
+	a.Equal(context.Canceled, err, "Returns ctx.Err()")
+	a.Equal(0, n, "Cancelled during the first sleep")
+	a.Equal(1, calls, "Called once")
+	a.True(time.Since(start) < 500*time.Millisecond, "Sleep cut short")
+}
+
+func TestSafeClient_MaxWait(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var infos []AttemptInfo
+	sleeper := &fakeSleeper{}
+	cl := testTimeoutClient
+	cl.Sleep = sleeper.Sleep
+	cl.MaxWait = 30 * time.Millisecond
+	cl.OnRetry = func(info AttemptInfo) {
+		infos = append(infos, info)
+	}
+
+	n, status, _, err := cl.DoRequest("GET", server.URL, nil, 3, nil)
+	a.NoError(err, "No error")
+	a.Equal(2, n, "Tries run out")
+	a.Equal(http.StatusServiceUnavailable, status, "Last response kept")
+	a.Equal([]time.Duration{cl.MaxWait, cl.MaxWait}, sleeper.Waits(), "Retry-After clamped")
+	a.Equal(2, len(infos), "OnRetry before each sleep")
+	a.Equal(AttemptInfo{Tries: 0, Status: http.StatusServiceUnavailable, Wait: cl.MaxWait}, infos[0], "Effective wait observed")
+
+	// honoured below MaxWait
+	cl.MaxWait = time.Hour
+	infos = nil
+	cl.DoRequest("GET", server.URL, nil, 2, nil)
+	a.Equal(time.Hour, infos[0].Wait, "Retry-After honoured")
+}
+
+func TestSafeClient_Wait_Deadline(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(Status5xxHandlerFunc)
+	defer server.Close()
+
+	var waits []time.Duration
+	sleeper := &fakeSleeper{}
+	cl := testTimeoutClient
+	cl.Backoff = Backoff{1000, 5000}
+	cl.Sleep = sleeper.Sleep
+	cl.OnRetry = func(info AttemptInfo) {
+		waits = append(waits, info.Wait)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	n, status, _, err := cl.DoRequestContext(ctx, "GET", server.URL, nil, 5, nil)
+	a.Equal(context.DeadlineExceeded, err, "No time for another try")
+	a.Equal(0, n, "One try")
+	a.Equal(http.StatusInternalServerError, status, "Last response kept")
+	a.Equal(1, len(waits), "One sleep")
+	a.True(waits[0] <= 200*time.Millisecond, "Clamped by the deadline")
+	a.Equal(waits, sleeper.Waits(), "Slept the reported wait")
+}
+
+func TestSafeClient_StatusMultipliers(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var code int
+		fmt.Sscan(r.URL.Query().Get("code"), &code)
+		w.WriteHeader(code)
+	}))
+	defer server.Close()
+
+	cl := testTimeoutClient
+	cl.Backoff = Backoff{100, 100} // always 100ms
+	cl.RetryTooManyRequests = true
+	cl.StatusMultipliers = map[int]float64{429: 4, 502: 1}
+
+	waits := func(url string) []time.Duration {
+		sleeper := &fakeSleeper{}
+		cl.Sleep = sleeper.Sleep
+		cl.DoRequest("GET", url, nil, 3, nil)
+		return sleeper.Waits()
+	}
+	backoff := 100 * time.Millisecond
+	a.Equal([]time.Duration{4 * backoff, 4 * backoff}, waits(server.URL+"?code=429"), "429 backs off harder")
+	a.Equal([]time.Duration{backoff, backoff}, waits(server.URL+"?code=502"), "502 as is")
+	a.Equal([]time.Duration{backoff, backoff}, waits(server.URL+"?code=503"), "Unset is 1")
+
+	// composed before the clamp
+	cl.MaxWait = 250 * time.Millisecond
+	a.Equal([]time.Duration{cl.MaxWait, cl.MaxWait}, waits(server.URL+"?code=429"), "Clamped by MaxWait")
+	cl.MaxWait = 0
+
+	// network errors
+	closed := httptest.NewServer(OkHandlerFunc)
+	closed.Close()
+	cl.TimeoutOnly = false
+	cl.NetworkErrorMultiplier = 2
+	a.Equal([]time.Duration{2 * backoff, 2 * backoff}, waits(closed.URL), "Network error multiplier")
+}
+
+func TestWithNoRetry(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var calls int32
+	server := httptest.NewServer(countingHandler(&calls, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})))
+	defer server.Close()
+
+	sleeper := &fakeSleeper{}
+	cl := testTimeoutClient
+	cl.Sleep = sleeper.Sleep
+
+	ctx := WithNoRetry(context.Background())
+	n, status, _, err := cl.DoRequestContext(ctx, "POST", server.URL, []byte("capture"), 5, nil)
+	a.NoError(err, "No error")
+	a.Equal(0, n, "One try")
+	a.Equal(http.StatusServiceUnavailable, status, "Returns what happened")
+	a.Equal(int32(1), calls, "Sent once")
+	a.Equal(0, len(sleeper.Waits()), "No sleep")
+
+	// the stricter maxTries still wins
+	n, _, _, _ = cl.DoRequestContext(ctx, "POST", server.URL, nil, 0, nil)
+	a.Equal(-1, n, "Never tried")
+	a.Equal(int32(1), calls, "Not sent")
+
+	n, _, _, _ = cl.DoRequestContext(context.Background(), "POST", server.URL, nil, 3, nil)
+	a.Equal(2, n, "Retried without the flag")
+	a.Equal(int32(4), calls, "Sent thrice")
+
+	n, _ = Retry(ctx, testBackoff, 3, func(ctx context.Context) error {
+		return errors.New("temporary")
+	})
+	a.Equal(0, n, "Retry too")
+}
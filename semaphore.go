@@ -2,8 +2,26 @@ package utils
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// ErrSemaphoreClosed is returned obtaining from a closed semaphore.
+var ErrSemaphoreClosed = errors.New("utils: semaphore closed")
+
+// ErrNothingHeld is returned releasing from an empty semaphore.
+var ErrNothingHeld = errors.New("utils: nothing held in semaphore")
+
+// ErrOverRelease is returned, or panicked with by Release, releasing
+// from an empty semaphore made by NewStrictSemaphore.
+var ErrOverRelease = errors.New("utils: semaphore released more than obtained")
+
+// ErrTooMany is returned obtaining more than the capacity of a semaphore.
+var ErrTooMany = errors.New("utils: more than semaphore capacity")
+
 // Semaphore is bounded resources abstraction.
 // Ref: https://github.com/golang/go/wiki/BoundingResourceUse
 type Semaphore interface {
@@ -12,10 +30,44 @@ type Semaphore interface {
 	// Obtaining from a closed semaphore should return false.
 	Obtain(context.Context) bool
 
+	// ObtainErr is Obtain telling why it failed: ErrSemaphoreClosed,
+	// or the context's error if cancelled first; nil if succeeds.
+	ObtainErr(context.Context) error
+
+	// TryObtain is Obtain that never blocks: it returns false
+	// right away if the semaphore is full (Count() == Capacity())
+	// or closed, else puts one in, adding one to Count().
+	TryObtain() bool
+
+	// ObtainWithTimeout is Obtain giving up after d;
+	// for d <= 0 it is TryObtain.
+	ObtainWithTimeout(d time.Duration) bool
+
+	// ObtainWithTimeoutErr is ObtainWithTimeout telling why it failed:
+	// ErrSemaphoreClosed or context.DeadlineExceeded.
+	ObtainWithTimeoutErr(d time.Duration) error
+
+	// ObtainN puts n into the semaphore at once, all or none,
+	// blocking like Obtain; one holding some of them never keeps
+	// another ObtainN from the rest. It returns false immediately,
+	// never blocking, if n exceeds Capacity(); true if n <= 0.
+	ObtainN(ctx context.Context, n int) bool
+
+	// ObtainNErr is ObtainN telling why it failed: ErrTooMany if n
+	// exceeds Capacity(), else as ObtainErr does.
+	ObtainNErr(ctx context.Context, n int) error
+
 	// Release takes one from the semaphore, returns true if succeeds.
 	// It should never blocks.
 	Release() bool
 
+	// ReleaseErr is Release returning ErrNothingHeld if it fails.
+	ReleaseErr() error
+
+	// ReleaseN takes up to n from the semaphore (never blocking),
+	// returns how many it took.
+	ReleaseN(n int) int
+
 	// Capacity returns semaphore's max concurrent resources.
 	Capacity() int
 
@@ -23,39 +75,293 @@ type Semaphore interface {
 	Count() int
 
 	// Close stops obtaining resources from semaphore,
-	// it makes Obtain() return false ever since.
+	// it makes Obtain() return false ever since,
+	// waking those blocked in it too.
 	Close()
 
 	// Closed tells if semaphore is closed.
 	Closed() bool
+
+	// Done returns a channel closed by Close, like the Done of a
+	// context, for selecting on; it is the same for every call.
+	Done() <-chan struct{}
+
+	// AcquireAll obtains every one at once, blocking like ObtainN,
+	// e.g., to rotate what the semaphore guards under its users.
+	// Obtains coming after wait for it, so it is never starved by them,
+	// and until ReleaseAll if it succeeds.
+	AcquireAll(ctx context.Context) bool
+
+	// ReleaseAll releases what AcquireAll obtained,
+	// returns false if it holds nothing.
+	ReleaseAll() bool
+
+	// Waiting returns how many are blocked in Obtain or ObtainN.
+	Waiting() int
+
+	// Snapshot returns the state of the semaphore, e.g., to log
+	// when wedged; it only briefly blocks the others.
+	Snapshot() SemaphoreSnapshot
+
+	// Wait blocks until Count() is 0, e.g., after Close for the
+	// resources obtained to be released, or the context cancelled,
+	// returning its error then.
+	Wait(ctx context.Context) error
+
+	// CloseAndWait is Close then Wait, for a graceful shutdown;
+	// if the context is done first, its error is wrapped telling
+	// how many are still held.
+	CloseAndWait(ctx context.Context) error
+
+	// Do runs fn between obtaining and releasing one, returning
+	// fn's error, or ObtainErr's without running fn. One is released
+	// even if fn panics, the panic going on afterwards.
+	Do(ctx context.Context, fn func() error) error
+
+	// DoWithTimeout is Do giving up obtaining after d,
+	// with context.DeadlineExceeded; fn itself is not timed.
+	DoWithTimeout(d time.Duration, fn func() error) error
+}
+
+// SemaphoreSnapshot is the state of a semaphore at some point.
+type SemaphoreSnapshot struct {
+	Capacity int
+	Count    int
+	Waiting  int // blocked in Obtain
+	Closed   bool
+
+	// OldestWait is how long the first waiter has waited,
+	// 0 if unknown or none waits.
+	OldestWait time.Duration
+}
+
+// String returns s like
+// "capacity 10, held 10, 37 waiting, closed=false, oldest wait 42s".
+func (s SemaphoreSnapshot) String() string {
+	str := fmt.Sprintf("capacity %d, held %d, %d waiting, closed=%t", s.Capacity, s.Count, s.Waiting, s.Closed)
+	if s.OldestWait > 0 {
+		str += fmt.Sprintf(", oldest wait %v", s.OldestWait)
+	}
+	return str
 }
 
 // semaphore implements Semaphore using a buffered channel.
 // It works like this:
 // Release() <- Semaphore (buffered channel) <- Obtain()
 type semaphore struct {
-	sem    chan struct{}
-	closed bool
+	sem   chan struct{}
+	done  chan struct{} // closed by Close
+	once  sync.Once
+	multi chan struct{} // held by the ObtainN or AcquireAll filling up
+
+	mu    sync.Mutex
+	empty []chan struct{} // of Wait, closed once drained
+	all   chan struct{}   // of AcquireAll, closed once released
+	held  bool            // by AcquireAll
+
+	waiting int64 // blocked in Obtain, atomically
+
+	stats  *SemaphoreStats // nil if not instrumented
+	strict bool            // set by NewStrictSemaphore
 }
 
 func (s *semaphore) Obtain(ctx context.Context) bool {
+	return s.ObtainErr(ctx) == nil
+}
+
+func (s *semaphore) ObtainErr(ctx context.Context) error {
+	if s.stats != nil {
+		return s.stats.obtain(ctx, s)
+	}
+	return s.obtain(ctx)
+}
+
+func (s *semaphore) obtain(ctx context.Context) error {
 	// never obtain from a closed semaphore
-	if s.closed {
+	if s.Closed() {
+		return ErrSemaphoreClosed
+	}
+
+	if s.TryObtain() {
+		return nil
+	}
+	atomic.AddInt64(&s.waiting, 1)
+	defer atomic.AddInt64(&s.waiting, -1)
+
+	// behind AcquireAll
+	for all := s.acquiredAll(); all != nil; all = s.acquiredAll() {
+		select {
+		case <-all:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.done:
+			return ErrSemaphoreClosed
+		}
+	}
+	return s.send(ctx)
+}
+
+// send puts one into the channel, blocking until there is room.
+func (s *semaphore) send(ctx context.Context) error {
+	select {
+	case s.sem <- struct{}{}:
+		if s.Closed() {
+			// closed meanwhile, give it back
+			s.release()
+			return ErrSemaphoreClosed
+		}
+		s.stats.count(len(s.sem))
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.done:
+		// woken by Close
+		return ErrSemaphoreClosed
+	}
+}
+
+func (s *semaphore) TryObtain() bool {
+	if s.Closed() || s.acquiredAll() != nil {
 		return false
 	}
 
-	done := ctx.Done()
 	select {
 	case s.sem <- struct{}{}:
+		if s.Closed() {
+			s.release()
+			return false
+		}
+		s.stats.count(len(s.sem))
 		return true
-	case <-done:
+	default:
+		// full
+		return false
+	}
+}
+
+func (s *semaphore) ObtainWithTimeout(d time.Duration) bool {
+	return obtainWithin(s, d) == nil
+}
+
+func (s *semaphore) ObtainWithTimeoutErr(d time.Duration) error {
+	return obtainWithin(s, d)
+}
+
+func (s *semaphore) ObtainN(ctx context.Context, n int) bool {
+	return s.ObtainNErr(ctx, n) == nil
+}
+
+func (s *semaphore) ObtainNErr(ctx context.Context, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	if n > s.Capacity() {
+		return ErrTooMany
+	}
+	if n == 1 {
+		return s.ObtainErr(ctx)
+	}
+
+	// one at a time, so that no two hold part of what they need
+	if err := s.lockMulti(ctx); err != nil {
+		return err
+	}
+	defer func() { <-s.multi }()
+	for i := 0; i < n; i++ {
+		if err := s.ObtainErr(ctx); err != nil {
+			s.ReleaseN(i)
+			return err
+		}
+	}
+	return nil
+}
+
+// lockMulti takes multi for ObtainN, waiting meanwhile.
+func (s *semaphore) lockMulti(ctx context.Context) error {
+	atomic.AddInt64(&s.waiting, 1)
+	defer atomic.AddInt64(&s.waiting, -1)
+	select {
+	case s.multi <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.done:
+		return ErrSemaphoreClosed
+	}
+}
+
+func (s *semaphore) AcquireAll(ctx context.Context) bool {
+	select {
+	case s.multi <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	case <-s.done:
 		return false
 	}
+
+	s.mu.Lock()
+	s.all = make(chan struct{})
+	s.mu.Unlock()
+	for i := 0; i < s.Capacity(); i++ {
+		if s.send(ctx) != nil {
+			s.ReleaseN(i)
+			s.releaseAll()
+			return false
+		}
+	}
+	s.mu.Lock()
+	s.held = true
+	s.mu.Unlock()
+	return true
+}
+
+func (s *semaphore) ReleaseAll() bool {
+	s.mu.Lock()
+	held := s.held
+	s.held = false
+	s.mu.Unlock()
+	if !held {
+		return false
+	}
+	s.ReleaseN(s.Capacity())
+	s.releaseAll()
+	return true
+}
+
+// releaseAll lets the Obtains waiting for AcquireAll in.
+func (s *semaphore) releaseAll() {
+	s.mu.Lock()
+	close(s.all)
+	s.all = nil
+	s.mu.Unlock()
+	<-s.multi
+}
+
+// acquiredAll returns the channel of AcquireAll if it is held
+// or pending, nil if not.
+func (s *semaphore) acquiredAll() chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.all
 }
 
 func (s *semaphore) Release() bool {
+	if s.release() {
+		return true
+	}
+	if s.strict {
+		panic(ErrOverRelease)
+	}
+	return false
+}
+
+// release is Release never strict.
+func (s *semaphore) release() bool {
 	select {
 	case <-s.sem:
+		if len(s.sem) == 0 {
+			s.drained()
+		}
 		return true
 	default:
 		// nothing queued
@@ -63,6 +369,63 @@ func (s *semaphore) Release() bool {
 	}
 }
 
+// drained wakes the Waits if nothing is queued.
+func (s *semaphore) drained() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// checked again, as one may have obtained meanwhile
+	if len(s.sem) > 0 {
+		return
+	}
+	for _, ch := range s.empty {
+		close(ch)
+	}
+	s.empty = nil
+}
+
+func (s *semaphore) Wait(ctx context.Context) error {
+	s.mu.Lock()
+	if len(s.sem) == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	s.empty = append(s.empty, ch)
+	s.mu.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, c := range s.empty {
+			if c == ch {
+				s.empty = append(s.empty[:i], s.empty[i+1:]...)
+				break
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+func (s *semaphore) ReleaseErr() error {
+	if !s.release() {
+		if s.strict {
+			return ErrOverRelease
+		}
+		return ErrNothingHeld
+	}
+	return nil
+}
+
+func (s *semaphore) ReleaseN(n int) int {
+	released := 0
+	for ; released < n && s.release(); released++ {
+	}
+	return released
+}
+
 func (s *semaphore) Capacity() int {
 	return cap(s.sem)
 }
@@ -73,18 +436,102 @@ func (s *semaphore) Count() int {
 
 func (s *semaphore) Close() {
 	// once closed, cannot be un-done
-	s.closed = true
+	s.once.Do(func() { close(s.done) })
 }
 
 func (s *semaphore) Closed() bool {
-	return s.closed
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *semaphore) Waiting() int {
+	return int(atomic.LoadInt64(&s.waiting))
+}
+
+func (s *semaphore) Snapshot() SemaphoreSnapshot {
+	return SemaphoreSnapshot{
+		Capacity: s.Capacity(),
+		Count:    s.Count(),
+		Waiting:  s.Waiting(),
+		Closed:   s.Closed(),
+	}
+}
+
+func (s *semaphore) String() string {
+	return s.Snapshot().String()
+}
+
+func (s *semaphore) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *semaphore) CloseAndWait(ctx context.Context) error {
+	return closeAndWait(ctx, s)
+}
+
+func (s *semaphore) Do(ctx context.Context, fn func() error) error {
+	return do(s, s.ObtainErr(ctx), fn)
+}
+
+func (s *semaphore) DoWithTimeout(d time.Duration, fn func() error) error {
+	return do(s, obtainWithin(s, d), fn)
+}
+
+// closeAndWait closes s and waits for it to drain.
+func closeAndWait(ctx context.Context, s Semaphore) error {
+	s.Close()
+	if err := s.Wait(ctx); err != nil {
+		return fmt.Errorf("utils: %d still held in semaphore: %w", s.Count(), err)
+	}
+	return nil
+}
+
+// do runs fn, if obtaining from s succeeded with err nil,
+// and releases one afterwards.
+func do(s Semaphore, err error, fn func() error) error {
+	if err != nil {
+		return err
+	}
+	defer s.Release()
+	return fn()
+}
+
+// obtainWithin obtains from s within d, TryObtain if not positive.
+func obtainWithin(s Semaphore, d time.Duration) error {
+	if d <= 0 {
+		if s.TryObtain() {
+			return nil
+		}
+		if s.Closed() {
+			return ErrSemaphoreClosed
+		}
+		return context.DeadlineExceeded
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return s.ObtainErr(ctx)
 }
 
 // NewSemaphore returns an internal semaphore.
 // This is the exported interface for using semaphore.
 func NewSemaphore(n int) Semaphore {
 	return &semaphore{
-		sem:    make(chan struct{}, n),
-		closed: false,
+		sem:   make(chan struct{}, n),
+		done:  make(chan struct{}),
+		multi: make(chan struct{}, 1),
 	}
 }
+
+// NewStrictSemaphore returns a semaphore of n where releasing more than
+// obtained, e.g., releasing twice, is a bug told rather than ignored:
+// Release panics with ErrOverRelease, and ReleaseErr returns it.
+// ReleaseN still takes up to n.
+func NewStrictSemaphore(n int) Semaphore {
+	s := NewSemaphore(n).(*semaphore)
+	s.strict = true
+	return s
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// SemaphoreStats records the use of a Semaphore made by
+// NewSemaphoreWithStats, to tune its capacity; it is safe for
+// concurrent use and may be shared by semaphores.
+type SemaphoreStats struct {
+	highWater, obtained, waited, waitTotal, waitMax, failedClosed, failedContext int64
+}
+
+// SemaphoreStatsSnapshot is a copy of SemaphoreStats at some point.
+type SemaphoreStatsSnapshot struct {
+	HighWater     int64         `json:"high_water"`     // max Count
+	Obtained      int64         `json:"obtained"`       // by Obtain and TryObtain
+	Waited        int64         `json:"waited"`         // Obtains blocked, obtained or not
+	WaitTotal     time.Duration `json:"wait_total"`     // of the Obtains blocked
+	WaitMax       time.Duration `json:"wait_max"`       // of an Obtain blocked
+	FailedClosed  int64         `json:"failed_closed"`  // Obtains failed by Close
+	FailedContext int64         `json:"failed_context"` // Obtains failed by the context
+}
+
+// NewSemaphoreWithStats returns a Semaphore of n recording into stats,
+// or NewSemaphore(n) if stats is nil.
+func NewSemaphoreWithStats(n int, stats *SemaphoreStats) Semaphore {
+	s := NewSemaphore(n).(*semaphore)
+	s.stats = stats
+	return s
+}
+
+// Snapshot returns the current records.
+func (s *SemaphoreStats) Snapshot() SemaphoreStatsSnapshot {
+	return SemaphoreStatsSnapshot{
+		HighWater:     atomic.LoadInt64(&s.highWater),
+		Obtained:      atomic.LoadInt64(&s.obtained),
+		Waited:        atomic.LoadInt64(&s.waited),
+		WaitTotal:     time.Duration(atomic.LoadInt64(&s.waitTotal)),
+		WaitMax:       time.Duration(atomic.LoadInt64(&s.waitMax)),
+		FailedClosed:  atomic.LoadInt64(&s.failedClosed),
+		FailedContext: atomic.LoadInt64(&s.failedContext),
+	}
+}
+
+// Reset zeroes the records, each on its own: those made meanwhile
+// may be kept or not.
+func (s *SemaphoreStats) Reset() {
+	for _, p := range []*int64{&s.highWater, &s.obtained, &s.waited, &s.waitTotal, &s.waitMax, &s.failedClosed, &s.failedContext} {
+		atomic.StoreInt64(p, 0)
+	}
+}
+
+// count records one obtained with n in use; a nil s records nothing.
+func (s *SemaphoreStats) count(n int) {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.obtained, 1)
+	storeMax(&s.highWater, int64(n))
+}
+
+// obtain is sem.ObtainErr timing the wait, if it has to.
+func (s *SemaphoreStats) obtain(ctx context.Context, sem *semaphore) error {
+	if sem.Closed() {
+		atomic.AddInt64(&s.failedClosed, 1)
+		return ErrSemaphoreClosed
+	}
+	if sem.TryObtain() {
+		return nil
+	}
+
+	start := time.Now()
+	err := sem.obtain(ctx)
+	wait := int64(time.Since(start))
+	atomic.AddInt64(&s.waited, 1)
+	atomic.AddInt64(&s.waitTotal, wait)
+	storeMax(&s.waitMax, wait)
+	switch {
+	case err == ErrSemaphoreClosed:
+		atomic.AddInt64(&s.failedClosed, 1)
+	case err != nil:
+		atomic.AddInt64(&s.failedContext, 1)
+	}
+	return err
+}
+
+// storeMax stores v at p if above.
+func storeMax(p *int64, v int64) {
+	for {
+		old := atomic.LoadInt64(p)
+		if v <= old || atomic.CompareAndSwapInt64(p, old, v) {
+			return
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package utils_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestNewSemaphoreWithStats(t *testing.T) {
+	assert := assert.NewAssert(t)
+
+	var stats SemaphoreStats
+	s := NewSemaphoreWithStats(3, &stats)
+	bc := context.Background()
+
+	// no contention
+	assert.True(s.Obtain(bc) && s.TryObtain(), "Obtained two")
+	snap := stats.Snapshot()
+	assert.Equal(int64(2), snap.HighWater, "High water")
+	assert.Equal(int64(2), snap.Obtained, "Obtained")
+	assert.Equal(int64(0), snap.Waited, "None waited")
+	assert.Equal(time.Duration(0), snap.WaitTotal, "No wait")
+	s.ReleaseN(2)
+
+	// one waits for the full semaphore
+	s.ObtainN(bc, 3)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.Release()
+	}()
+	assert.True(s.Obtain(bc), "Obtained after wait")
+	ctx, cancel := context.WithTimeout(bc, 5*time.Millisecond)
+	defer cancel()
+	assert.True(!s.Obtain(ctx), "Timed out")
+	snap = stats.Snapshot()
+	assert.Equal(int64(3), snap.HighWater, "High water of full")
+	assert.Equal(int64(2), snap.Waited, "Two waited")
+	assert.True(snap.WaitMax >= 10*time.Millisecond, "Max wait")
+	assert.True(snap.WaitTotal >= snap.WaitMax+5*time.Millisecond, "Total wait")
+	assert.Equal(int64(1), snap.FailedContext, "Failed by context")
+
+	s.Close()
+	s.Obtain(bc)
+	assert.Equal(int64(1), stats.Snapshot().FailedClosed, "Failed by close")
+
+	stats.Reset()
+	assert.Equal(SemaphoreStatsSnapshot{}, stats.Snapshot(), "Reset")
+
+	s = NewSemaphoreWithStats(1, nil)
+	assert.True(s.Obtain(bc) && !s.TryObtain(), "Works without stats")
+}
+
+func BenchmarkSemaphore_ObtainRelease(b *testing.B) {
+	for _, bc := range []struct {
+		name  string
+		stats *SemaphoreStats
+	}{
+		{"NoStats", nil},
+		{"Stats", &SemaphoreStats{}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			s := NewSemaphoreWithStats(1, bc.stats)
+			ctx := context.Background()
+			for i := 0; i < b.N; i++ {
+				s.Obtain(ctx)
+				s.Release()
+			}
+		})
+	}
+}
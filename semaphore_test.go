@@ -2,7 +2,10 @@ package utils_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -37,8 +40,7 @@ func TestSemaphore_ObtainRelease(t *testing.T) {
 	assert.Equal(1, sema.Count(), "Now has one queued")
 
 	sema.Obtain(bc) // obtain one more
-	ctx, _ := context.WithTimeout(bc, 10*time.Millisecond)
-	assert.True(!sema.Obtain(ctx), "Should fail")
+	assert.True(!sema.ObtainWithTimeout(10*time.Millisecond), "Should fail")
 
 	assert.Equal(sema.Capacity(), sema.Count(), "Now it is full")
 
@@ -61,8 +63,8 @@ func TestSemaphore_Sync(t *testing.T) {
 
 	sema := NewSemaphore(n)
 	for i := 0; i < m; i++ {
+		wg.Add(1)
 		go func() {
-			wg.Add(1)
 			sema.Obtain(ctx)
 			wg.Done()
 		}()
@@ -78,3 +80,637 @@ func TestSemaphore_Sync(t *testing.T) {
 	wg.Wait()
 	assert.Equal(n, sema.Count(), "Still full but buffered")
 }
+
+func TestSemaphore_CloseRace(t *testing.T) {
+	assert := assert.NewAssert(t)
+	const n = 4
+	const m = 50 // workers
+
+	sema := NewSemaphore(n)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var late int // obtained after Close returned
+	closed := make(chan struct{})
+	for i := 0; i < m; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-closed:
+					if sema.Obtain(ctx) {
+						mu.Lock()
+						late++
+						mu.Unlock()
+					}
+					return
+				default:
+				}
+				if sema.Obtain(ctx) {
+					sema.Release()
+				}
+			}
+		}()
+	}
+	go func() {
+		time.Sleep(time.Millisecond)
+		sema.Close()
+		sema.Close() // idempotent
+		close(closed)
+	}()
+
+	wg.Wait()
+	assert.True(sema.Closed(), "Closed")
+	assert.Equal(0, late, "Never obtained after Close")
+}
+
+func TestSemaphore_CloseWakes(t *testing.T) {
+	assert := assert.NewAssert(t)
+
+	sema := NewSemaphore(1)
+	bc := context.Background()
+	sema.Obtain(bc) // full
+
+	results := make(chan bool, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			results <- sema.Obtain(bc)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond) // both blocked
+	sema.Close()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case ok := <-results:
+			assert.True(!ok, "Woken with false")
+		case <-time.After(time.Second):
+			t.Fatal("Still blocked after Close")
+		}
+	}
+	assert.True(!sema.Obtain(bc), "Later ones fail fast")
+}
+
+func TestSemaphore_TryObtain(t *testing.T) {
+	assert := assert.NewAssert(t)
+	const n = 2
+
+	sema := NewSemaphore(n)
+	assert.True(sema.TryObtain(), "Obtained from empty")
+	assert.True(sema.TryObtain(), "Obtained the last one")
+	assert.Equal(n, sema.Count(), "Now it is full")
+	assert.True(!sema.TryObtain(), "Fails on full")
+	assert.Equal(n, sema.Count(), "Count unchanged")
+
+	sema.Release()
+	sema.Close()
+	assert.True(!sema.TryObtain(), "Fails when closed")
+	assert.Equal(1, sema.Count(), "Count unchanged")
+}
+
+func TestSemaphore_TryObtainSync(t *testing.T) {
+	assert := assert.NewAssert(t)
+	const n = 5
+	const m = 100 // workers
+
+	sema := NewSemaphore(n)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	granted := 0
+	for i := 0; i < m; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if sema.TryObtain() {
+				mu.Lock()
+				granted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(n, granted, "No slot granted twice")
+	assert.Equal(n, sema.Count(), "Full")
+}
+
+func TestSemaphore_ObtainN(t *testing.T) {
+	assert := assert.NewAssert(t)
+	const n = 4
+
+	sema := NewSemaphore(n)
+	bc := context.Background()
+	assert.True(sema.ObtainN(bc, 3), "Obtained 3")
+	assert.Equal(3, sema.Count(), "3 queued")
+
+	ctx, cancel := context.WithTimeout(bc, 10*time.Millisecond)
+	defer cancel()
+	assert.True(!sema.ObtainN(ctx, 2), "Not enough left")
+	assert.Equal(3, sema.Count(), "None kept")
+
+	start := time.Now()
+	assert.True(!sema.ObtainN(bc, n+1), "More than capacity")
+	assert.True(time.Since(start) < 10*time.Millisecond, "Fails immediately")
+	assert.True(sema.ObtainN(bc, 0), "Nothing to obtain")
+
+	assert.Equal(3, sema.ReleaseN(5), "Released what there was")
+	assert.Equal(0, sema.Count(), "Empty")
+	assert.Equal(0, sema.ReleaseN(1), "Nothing to release")
+}
+
+func TestSemaphore_ObtainNSync(t *testing.T) {
+	assert := assert.NewAssert(t)
+	const n = 4
+	const m = 10 // workers
+
+	sema := NewSemaphore(n)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	held, maxHeld, done := 0, 0, 0
+	for i := 0; i < m; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// two of these holding 2 each would deadlock if not atomic
+			for j := 0; j < 20; j++ {
+				if !sema.ObtainN(ctx, 3) {
+					return
+				}
+				mu.Lock()
+				if held += 3; held > maxHeld {
+					maxHeld = held
+				}
+				mu.Unlock()
+				time.Sleep(time.Microsecond)
+				mu.Lock()
+				held -= 3
+				mu.Unlock()
+				sema.ReleaseN(3)
+			}
+			mu.Lock()
+			done++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(m, done, "No deadlock")
+	assert.Equal(3, maxHeld, "One batch at a time")
+	assert.Equal(0, sema.Count(), "All released")
+}
+
+func TestSemaphore_Wait(t *testing.T) {
+	assert := assert.NewAssert(t)
+	const n = 3
+
+	sema := NewSemaphore(n)
+	bc := context.Background()
+	assert.NoError(sema.Wait(bc), "Nothing to wait for")
+
+	sema.ObtainN(bc, n)
+	sema.Close()
+	var released int32
+	go func() {
+		for i := 0; i < n; i++ {
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&released, 1)
+			sema.Release()
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(sema.Wait(bc), "Drained")
+			assert.Equal(int32(n), atomic.LoadInt32(&released), "Returns once all released")
+		}()
+	}
+	wg.Wait()
+	assert.Equal(0, sema.Count(), "Empty")
+
+	sema = NewSemaphore(n)
+	sema.Obtain(bc)
+	ctx, cancel := context.WithTimeout(bc, 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, sema.Wait(ctx), "Timed out")
+}
+
+func TestSemaphore_ObtainErr(t *testing.T) {
+	assert := assert.NewAssert(t)
+
+	sema := NewSemaphore(1)
+	bc := context.Background()
+	assert.Equal(ErrNothingHeld, sema.ReleaseErr(), "Nothing held")
+	assert.NoError(sema.ObtainErr(bc), "Obtained")
+
+	ctx, cancel := context.WithTimeout(bc, 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, sema.ObtainErr(ctx), "Timed out")
+	ctx, cancel = context.WithCancel(bc)
+	cancel()
+	assert.Equal(context.Canceled, sema.ObtainErr(ctx), "Cancelled")
+
+	errs := make(chan error)
+	go func() {
+		errs <- sema.ObtainErr(bc)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	sema.Close()
+	assert.Equal(ErrSemaphoreClosed, <-errs, "Woken by Close")
+	assert.Equal(ErrSemaphoreClosed, sema.ObtainErr(bc), "Closed")
+	assert.NoError(sema.ReleaseErr(), "Released")
+}
+
+func TestSemaphore_ObtainNErr(t *testing.T) {
+	assert := assert.NewAssert(t)
+	bc := context.Background()
+
+	for _, sema := range []Semaphore{NewSemaphore(4), NewFairSemaphore(4)} {
+		assert.ErrorIs(sema.ObtainNErr(bc, 5), ErrTooMany, "More than capacity")
+		assert.NoError(sema.ObtainNErr(bc, 0), "Nothing to obtain")
+		assert.NoError(sema.ObtainNErr(bc, 3), "Obtained 3")
+
+		ctx, cancel := context.WithTimeout(bc, 10*time.Millisecond)
+		assert.ErrorIs(sema.ObtainNErr(ctx, 2), context.DeadlineExceeded, "Timed out")
+		cancel()
+		ctx, cancel = context.WithCancel(bc)
+		cancel()
+		assert.ErrorIs(sema.ObtainNErr(ctx, 2), context.Canceled, "Cancelled")
+		assert.Equal(3, sema.Count(), "None kept")
+
+		errs := make(chan error)
+		go func() {
+			errs <- sema.ObtainNErr(bc, 2)
+		}()
+		time.Sleep(5 * time.Millisecond)
+		sema.Close()
+		assert.ErrorIs(<-errs, ErrSemaphoreClosed, "Woken by Close")
+		assert.ErrorIs(sema.ObtainNErr(bc, 2), ErrSemaphoreClosed, "Closed")
+		assert.ErrorIs(sema.ObtainNErr(bc, 5), ErrTooMany, "Too many told first")
+	}
+}
+
+func TestSemaphore_Do(t *testing.T) {
+	assert := assert.NewAssert(t)
+	bc := context.Background()
+
+	for _, sema := range []Semaphore{NewSemaphore(2), NewFairSemaphore(2)} {
+		errFn := errors.New("fn failed")
+		assert.Equal(errFn, sema.Do(bc, func() error {
+			assert.Equal(1, sema.Count(), "Obtained while running")
+			return errFn
+		}), "Returns fn's error")
+		assert.Equal(0, sema.Count(), "Released")
+
+		func() {
+			defer func() {
+				assert.Equal("boom", recover(), "Re-panicked")
+			}()
+			sema.Do(bc, func() error {
+				panic("boom")
+			})
+		}()
+		assert.Equal(0, sema.Count(), "Released on panic")
+
+		// normal operation under concurrency
+		var wg sync.WaitGroup
+		var running, maxRunning int32
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sema.Do(bc, func() error {
+					n := atomic.AddInt32(&running, 1)
+					for {
+						m := atomic.LoadInt32(&maxRunning)
+						if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+							break
+						}
+					}
+					time.Sleep(time.Millisecond)
+					atomic.AddInt32(&running, -1)
+					return nil
+				})
+			}()
+		}
+		wg.Wait()
+		assert.True(atomic.LoadInt32(&maxRunning) <= 2, "Bounded")
+
+		sema.ObtainN(bc, 2)
+		assert.Equal(context.DeadlineExceeded, sema.DoWithTimeout(10*time.Millisecond, func() error {
+			t.Error("fn run when full")
+			return nil
+		}), "Timed out")
+		sema.ReleaseN(2)
+		assert.NoError(sema.DoWithTimeout(10*time.Millisecond, func() error { return nil }), "Within timeout")
+
+		sema.Close()
+		assert.Equal(ErrSemaphoreClosed, sema.Do(bc, func() error {
+			t.Error("fn run when closed")
+			return nil
+		}), "Closed")
+	}
+}
+
+func TestSemaphore_ObtainWithTimeout(t *testing.T) {
+	assert := assert.NewAssert(t)
+
+	for _, sema := range []Semaphore{NewSemaphore(1), NewFairSemaphore(1)} {
+		assert.True(sema.ObtainWithTimeout(10*time.Millisecond), "Obtained within")
+		start := time.Now()
+		assert.Equal(context.DeadlineExceeded, sema.ObtainWithTimeoutErr(10*time.Millisecond), "Timed out")
+		assert.True(time.Since(start) >= 10*time.Millisecond, "Waited the timeout")
+
+		go func() {
+			time.Sleep(5 * time.Millisecond)
+			sema.Release()
+		}()
+		assert.True(sema.ObtainWithTimeout(time.Second), "Obtained once released")
+
+		assert.True(!sema.ObtainWithTimeout(0), "Full for TryObtain")
+		sema.Release()
+		assert.True(sema.ObtainWithTimeout(-1), "TryObtain succeeds")
+
+		sema.Close()
+		assert.Equal(ErrSemaphoreClosed, sema.ObtainWithTimeoutErr(time.Second), "Closed")
+		assert.Equal(ErrSemaphoreClosed, sema.ObtainWithTimeoutErr(0), "Closed for TryObtain")
+	}
+}
+
+func TestNewStrictSemaphore(t *testing.T) {
+	assert := assert.NewAssert(t)
+	bc := context.Background()
+
+	// the status quo
+	lenient := NewSemaphore(2)
+	lenient.Obtain(bc)
+	assert.True(lenient.Release(), "Released")
+	assert.True(!lenient.Release(), "Double release ignored")
+	assert.Equal(ErrNothingHeld, lenient.ReleaseErr(), "Nothing held")
+
+	sema := NewStrictSemaphore(2)
+	sema.Obtain(bc)
+	assert.True(sema.Release(), "Released")
+	func() {
+		defer func() {
+			assert.Equal(ErrOverRelease, recover(), "Double release panics")
+		}()
+		sema.Release()
+	}()
+	assert.Equal(ErrOverRelease, sema.ReleaseErr(), "Over release")
+	assert.Equal(0, sema.ReleaseN(2), "ReleaseN takes up to n")
+
+	// the balance kept after all
+	for i := 0; i < 3; i++ {
+		sema.TryObtain()
+	}
+	assert.Equal(sema.Capacity(), sema.Count(), "Never over capacity")
+	assert.True(sema.Release(), "Released")
+	assert.NoError(sema.Do(bc, func() error { return nil }), "Do releases once")
+	assert.Equal(1, sema.ReleaseN(2), "Released the rest")
+
+	// give-backs are not over-releases
+	sema.Close()
+	assert.True(!sema.Obtain(bc) && !sema.TryObtain(), "Closed")
+}
+
+func TestSemaphore_Done(t *testing.T) {
+	assert := assert.NewAssert(t)
+	const m = 10 // selectors
+
+	for _, sema := range []Semaphore{NewSemaphore(1), NewFairSemaphore(1)} {
+		done := sema.Done()
+		assert.True(done == sema.Done(), "The same channel")
+		select {
+		case <-done:
+			t.Error("Done before Close")
+		default:
+		}
+
+		var wg sync.WaitGroup
+		var fired int32
+		ticker := time.NewTicker(time.Millisecond)
+		for i := 0; i < m; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-sema.Done():
+						atomic.AddInt32(&fired, 1)
+						return
+					case <-ticker.C:
+					}
+				}
+			}()
+		}
+		time.Sleep(5 * time.Millisecond)
+		sema.Close()
+		sema.Close()
+		wg.Wait()
+		ticker.Stop()
+		assert.Equal(int32(m), fired, "Fired for every selector")
+		assert.True(done == sema.Done(), "The same after Close")
+		<-sema.Done()
+	}
+}
+
+func TestSemaphore_AcquireAll(t *testing.T) {
+	assert := assert.NewAssert(t)
+	const n = 3
+	bc := context.Background()
+
+	for _, sema := range []Semaphore{NewSemaphore(n), NewFairSemaphore(n)} {
+		assert.True(!sema.ReleaseAll(), "Nothing acquired")
+
+		// holders keep obtaining and releasing all along
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		var holders, obtained int32
+		for i := 0; i < 2*n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					if sema.Obtain(bc) {
+						atomic.AddInt32(&holders, 1)
+						atomic.AddInt32(&obtained, 1)
+						time.Sleep(time.Millisecond)
+						atomic.AddInt32(&holders, -1)
+						sema.Release()
+					}
+				}
+			}()
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		acquired := make(chan bool)
+		go func() {
+			acquired <- sema.AcquireAll(bc)
+		}()
+		select {
+		case ok := <-acquired:
+			assert.True(ok, "Acquired all")
+		case <-time.After(time.Second):
+			t.Fatal("Starved")
+		}
+		assert.Equal(int32(0), atomic.LoadInt32(&holders), "No holders meanwhile")
+		assert.Equal(n, sema.Count(), "Holds every one")
+		assert.True(!sema.TryObtain(), "TryObtain fails meanwhile")
+		time.Sleep(5 * time.Millisecond)
+		assert.Equal(int32(0), atomic.LoadInt32(&holders), "Obtains block meanwhile")
+		before := atomic.LoadInt32(&obtained)
+
+		assert.True(sema.ReleaseAll(), "Released all")
+		assert.True(!sema.ReleaseAll(), "Released once")
+		time.Sleep(5 * time.Millisecond)
+		assert.True(atomic.LoadInt32(&obtained) > before, "Resumed")
+		close(stop)
+		wg.Wait()
+
+		// cancelled while the holders hold
+		sema.Obtain(bc)
+		ctx, cancel := context.WithTimeout(bc, 10*time.Millisecond)
+		assert.True(!sema.AcquireAll(ctx), "Cancelled")
+		cancel()
+		assert.True(sema.TryObtain(), "Obtains let in again")
+		sema.ReleaseN(2)
+		assert.True(sema.AcquireAll(bc) && sema.ReleaseAll(), "Acquired once free")
+		assert.Equal(0, sema.Count(), "Empty")
+	}
+}
+
+func TestSemaphore_Snapshot(t *testing.T) {
+	assert := assert.NewAssert(t)
+	const n = 2
+	const m = 3 // waiters
+	bc := context.Background()
+
+	for _, sema := range []Semaphore{NewSemaphore(n), NewFairSemaphore(n)} {
+		assert.Equal(SemaphoreSnapshot{Capacity: n}, sema.Snapshot(), "Idle")
+
+		sema.ObtainN(bc, n)
+		ctx, cancel := context.WithCancel(bc)
+		var wg sync.WaitGroup
+		for i := 0; i < m; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sema.Obtain(ctx)
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		snap := sema.Snapshot()
+		assert.Equal(n, snap.Capacity, "Capacity")
+		assert.Equal(n, snap.Count, "Held")
+		assert.Equal(m, snap.Waiting, "Waiting")
+		assert.True(!snap.Closed, "Not closed")
+
+		cancel()
+		wg.Wait()
+		sema.Close()
+		snap = sema.Snapshot()
+		assert.Equal(0, snap.Waiting, "None waiting")
+		assert.Equal(SemaphoreSnapshot{Capacity: n, Count: n, Closed: true}, snap, "Closed")
+		assert.Equal("capacity 2, held 2, 0 waiting, closed=true", fmt.Sprint(sema), "String")
+	}
+
+	snap := SemaphoreSnapshot{Capacity: 10, Count: 10, Waiting: 37, OldestWait: 42 * time.Second}
+	assert.Equal("capacity 10, held 10, 37 waiting, closed=false, oldest wait 42s", snap.String(), "Renders")
+
+	sema := NewFairSemaphore(1)
+	sema.Obtain(bc)
+	go sema.Obtain(bc)
+	time.Sleep(20 * time.Millisecond)
+	assert.True(sema.Snapshot().OldestWait >= 20*time.Millisecond, "Oldest wait of fair")
+	sema.Close()
+}
+
+func TestSemaphore_Waiting(t *testing.T) {
+	assert := assert.NewAssert(t)
+	const n = 2
+	bc := context.Background()
+
+	for _, sema := range []Semaphore{NewSemaphore(n), NewFairSemaphore(n)} {
+		assert.Equal(0, sema.Waiting(), "None waiting")
+		sema.ObtainN(bc, n)
+		assert.Equal(0, sema.Waiting(), "Obtained without waiting")
+
+		var cancels []context.CancelFunc
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			ctx, cancel := context.WithCancel(bc)
+			cancels = append(cancels, cancel)
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if i%2 == 0 {
+					sema.Obtain(ctx)
+				} else {
+					sema.ObtainN(ctx, n)
+				}
+			}(i)
+		}
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(4, sema.Waiting(), "Parked")
+
+		cancels[0]()
+		cancels[1]()
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(2, sema.Waiting(), "Cancelled ones left")
+
+		sema.Close()
+		wg.Wait()
+		assert.Equal(0, sema.Waiting(), "Woken by Close")
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+}
+
+func TestSemaphore_CloseAndWait(t *testing.T) {
+	assert := assert.NewAssert(t)
+	const n = 3
+	const delay = 20 * time.Millisecond
+	bc := context.Background()
+
+	for _, sema := range []Semaphore{NewSemaphore(n), NewFairSemaphore(n)} {
+		sema.ObtainN(bc, n)
+		for i := 0; i < n; i++ {
+			go func() {
+				time.Sleep(delay)
+				sema.Release()
+			}()
+		}
+		blocked := make(chan bool)
+		go func() {
+			blocked <- sema.Obtain(bc)
+		}()
+
+		start := time.Now()
+		assert.NoError(sema.CloseAndWait(bc), "Drained")
+		assert.True(time.Since(start) >= delay, "Returns once released")
+		assert.Equal(0, sema.Count(), "Empty")
+		assert.True(!<-blocked, "Blocked one woken")
+		assert.True(!sema.Obtain(bc), "Closed")
+	}
+
+	sema := NewSemaphore(n)
+	sema.ObtainN(bc, 2)
+	ctx, cancel := context.WithTimeout(bc, 10*time.Millisecond)
+	defer cancel()
+	err := sema.CloseAndWait(ctx)
+	assert.True(errors.Is(err, context.DeadlineExceeded), "Deadline expired")
+	assert.Equal("utils: 2 still held in semaphore: context deadline exceeded", err.Error(), "Tells how many held")
+	assert.True(sema.Closed(), "Closed anyway")
+}
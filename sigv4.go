@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// UnsignedPayload is the X-Amz-Content-Sha256 of a body left unsigned.
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// emptySHA256 is the hex SHA-256 of no body.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Credentials are the AWS keys a request is signed with;
+// SessionToken is for temporary ones only.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// SigV4Hook returns a hook signing every request by AWS Signature
+// Version 4 for the region and service, e.g., "s3"; see SigV4.
+func SigV4Hook(creds Credentials, region, service string) RequestHookE {
+	s := &SigV4{Credentials: creds, Region: region, Service: service}
+	return s.Sign
+}
+
+// SigV4 signs requests by AWS Signature Version 4.
+// As the signature includes the time, it must sign each try:
+// use its hook with the methods making a Request per try, e.g., DoRequest,
+// not RequestWithRetry. Headers set after it, e.g., DefaultHeaders,
+// go unsigned; WithMethodOverride breaks the signature.
+type SigV4 struct {
+	Credentials
+	Region  string
+	Service string
+
+	// UnsignedPayload leaves bodies unhashed, e.g., for big uploads
+	// to S3; a body that cannot be re-read is always left so.
+	UnsignedPayload bool
+
+	// Now, if set, stands in for time.Now.
+	Now func() time.Time
+}
+
+// Sign adds the X-Amz-Date and Authorization headers (and the
+// X-Amz-Security-Token and X-Amz-Content-Sha256 ones if needed) to req.
+// A body is hashed from GetBody, leaving req.Body unread, unless
+// X-Amz-Content-Sha256 is set already.
+func (s *SigV4) Sign(req *http.Request) error {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	t := now().UTC()
+	date := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", t.Format("20060102T150405Z"))
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	payload := req.Header.Get("X-Amz-Content-Sha256")
+	if payload == "" {
+		var err error
+		if payload, err = s.payloadHash(req); err != nil {
+			return err
+		}
+		// S3 requires the header, others take what is signed
+		if s.Service == "s3" || payload == UnsignedPayload {
+			req.Header.Set("X-Amz-Content-Sha256", payload)
+		}
+	}
+
+	canonical, signed := s.canonicalRequest(req, payload)
+	scope := strings.Join([]string{date, s.Region, s.Service, "aws4_request"}, "/")
+	toSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		req.Header.Get("X-Amz-Date"),
+		scope,
+		hexSHA256([]byte(canonical)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	for _, part := range []string{s.Region, s.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+	return nil
+}
+
+func (s *SigV4) payloadHash(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return emptySHA256, nil
+	}
+	if s.UnsignedPayload || req.GetBody == nil {
+		return UnsignedPayload, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// headers not signed, as proxies may change them
+var sigV4Unsigned = map[string]bool{
+	"Authorization":   true,
+	"User-Agent":      true,
+	"X-Amzn-Trace-Id": true,
+}
+
+// canonicalRequest returns the canonical request of req
+// and its signed headers.
+func (s *SigV4) canonicalRequest(req *http.Request, payload string) (string, string) {
+	headers := map[string]string{}
+	for k, vs := range req.Header {
+		if sigV4Unsigned[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		values := make([]string, len(vs))
+		for i, v := range vs {
+			values[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[strings.ToLower(k)] = strings.Join(values, ",")
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers["host"] = host
+
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	return strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signed,
+		payload,
+	}, "\n"), signed
+}
+
+// canonicalURI encodes each segment of path,
+// twice but for S3 as the spec requires.
+func (s *SigV4) canonicalURI(path string) string {
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		seg = awsEscape(seg)
+		if s.Service != "s3" {
+			seg = awsEscape(seg)
+		}
+		segments[i] = seg
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(q map[string][]string) string {
+	keys := make([]string, 0, len(q))
+	escaped := map[string][]string{}
+	for k, vs := range q {
+		ek := awsEscape(k)
+		keys = append(keys, ek)
+		for _, v := range vs {
+			escaped[ek] = append(escaped[ek], awsEscape(v))
+		}
+	}
+	// by key, then value
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		sort.Strings(escaped[k])
+		for _, v := range escaped[k] {
+			pairs = append(pairs, k+"="+v)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape percent-encodes all but the unreserved characters of RFC 3986.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
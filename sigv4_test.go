@@ -0,0 +1,97 @@
+package utils_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+// the example keys of the AWS documentation
+var testCredentials = Credentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+func fixedNow() time.Time {
+	return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+}
+
+func TestSigV4_Sign(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	// from "Signature Version 4 signing process" of the IAM docs;
+	// its canonical request hashes to f536975d06c0309214f805bb90ccff089219ecd68b2577efef23edd43b7e1a59
+	req, _ := http.NewRequest("GET", "https://iam.amazonaws.com/?Version=2010-05-08&Action=ListUsers", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	s := &SigV4{Credentials: testCredentials, Region: "us-east-1", Service: "iam", Now: fixedNow}
+	a.NoError(s.Sign(req), "No error")
+	a.Equal("20150830T123600Z", req.Header.Get("X-Amz-Date"), "Dated")
+	a.Equal("AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-date, "+
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		req.Header.Get("Authorization"), "IAM example")
+
+	// get-vanilla of the AWS SigV4 test suite
+	req, _ = http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	s = &SigV4{Credentials: testCredentials, Region: "us-east-1", Service: "service", Now: fixedNow}
+	s.Sign(req)
+	a.Equal("AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, "+
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"), "get-vanilla")
+}
+
+func TestSigV4_Payload(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	s := &SigV4{Credentials: testCredentials, Region: "us-east-1", Service: "s3", Now: fixedNow}
+	req, _ := http.NewRequest("PUT", "https://bucket.s3.amazonaws.com/a%20key", bytes.NewReader([]byte("hello")))
+	a.NoError(s.Sign(req), "No error")
+	a.Equal("2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", req.Header.Get("X-Amz-Content-Sha256"), "Body hashed")
+	data, _ := ioutil.ReadAll(req.Body)
+	a.Equal("hello", string(data), "Body left unread")
+	a.True(strings.Contains(req.Header.Get("Authorization"), "x-amz-content-sha256"), "Hash signed")
+
+	// a body that cannot be re-read is not hashed
+	req, _ = http.NewRequest("PUT", "https://bucket.s3.amazonaws.com/key", ioutil.NopCloser(strings.NewReader("hello")))
+	s.Sign(req)
+	a.Equal(UnsignedPayload, req.Header.Get("X-Amz-Content-Sha256"), "Unsigned")
+
+	req, _ = http.NewRequest("GET", "https://bucket.s3.amazonaws.com/key", nil)
+	s.SessionToken = "token"
+	s.Sign(req)
+	a.Equal("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", req.Header.Get("X-Amz-Content-Sha256"), "Empty body")
+	a.Equal("token", req.Header.Get("X-Amz-Security-Token"), "Session token")
+	a.True(strings.Contains(req.Header.Get("Authorization"), "x-amz-security-token"), "Token signed")
+}
+
+func TestSigV4Hook(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var auths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auths = append(auths, r.Header.Get("Authorization"))
+		if len(auths) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	cl := StdClient()
+	cl.Backoff = testBackoff
+	hook := SigV4Hook(testCredentials, "us-east-1", "execute-api")
+	tries, status, _, err := cl.DoRequest("POST", server.URL, []byte("{}"), 2, hook.Hook())
+	a.NoError(err, "No error")
+	a.Equal(1, tries, "Retried once")
+	a.Equal(http.StatusOK, status, "Returns code")
+	for _, auth := range auths {
+		a.True(strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"), "Signed per try")
+	}
+}
@@ -0,0 +1,29 @@
+package utils
+
+import "time"
+
+// SLAFunc is told of a logical call that took elapsed in total,
+// of which slept was spent sleeping between its tries
+// (the rest in the tries themselves).
+type SLAFunc func(method, url string, elapsed, slept time.Duration, tries, status int)
+
+// WithSLA calls fn for every call of RequestWithRetry, DoRequest
+// and the methods built on them that takes longer than threshold.
+// Sleeps count as long as asked of the Sleeper, so a fake one still
+// adds up as if it had slept.
+func WithSLA(threshold time.Duration, fn SLAFunc) Option {
+	return func(c *SafeClient) error {
+		c.SLA, c.OnSLAExceeded = threshold, fn
+		return nil
+	}
+}
+
+// checkSLA reports the call to OnSLAExceeded if it took too long.
+func (c *SafeClient) checkSLA(method, url string, tm callTiming, tries, status int) {
+	if c.SLA <= 0 || c.OnSLAExceeded == nil {
+		return
+	}
+	if elapsed := tm.attempts + tm.slept; elapsed > c.SLA {
+		c.OnSLAExceeded(method, url, elapsed, tm.slept, tries, status)
+	}
+}
@@ -0,0 +1,65 @@
+package utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+type slaCall struct {
+	method, url    string
+	elapsed, slept time.Duration
+	tries, status  int
+}
+
+func TestWithSLA(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	fast := httptest.NewServer(OkHandlerFunc)
+	defer fast.Close()
+	var n int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("OK"))
+	}))
+	defer slow.Close()
+
+	var calls []slaCall
+	cl, err := NewClient(WithSLA(time.Second, func(method, url string, elapsed, slept time.Duration, tries, status int) {
+		calls = append(calls, slaCall{method, url, elapsed, slept, tries, status})
+	}))
+	a.NoError(err, "No error")
+	cl.Sleep = (&fakeSleeper{}).Sleep
+	cl.Backoff = Backoff{2000, 2000}
+
+	cl.DoRequest("GET", fast.URL, nil, 3, nil)
+	a.Equal(0, len(calls), "Fast call not flagged")
+
+	// the fake 2s sleep puts it over
+	_, status, _, _ := cl.DoRequest("GET", slow.URL, nil, 3, nil)
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal(1, len(calls), "Slow call flagged")
+	c := calls[0]
+	a.Equal("GET", c.method, "Method")
+	a.Equal(slow.URL, c.url, "URL")
+	a.Equal(1, c.tries, "Tries")
+	a.Equal(http.StatusOK, c.status, "Status")
+	a.Equal(2*time.Second, c.slept, "Spent sleeping")
+	a.True(c.elapsed-c.slept >= 50*time.Millisecond, "Spent in attempts")
+
+	// and through RequestWithRetry, over in the attempt alone
+	cl.SLA = 10 * time.Millisecond
+	req, _ := http.NewRequest("GET", slow.URL, nil)
+	cl.RequestWithRetry(req, 1)
+	a.Equal(2, len(calls), "Slow attempt flagged")
+	a.Equal(time.Duration(0), calls[1].slept, "No sleep")
+}
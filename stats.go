@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// Stats counts the traffic of a SafeClient that points to it;
+// it is safe for concurrent use and may be shared by clients.
+type Stats struct {
+	requests, retries, failures, inFlight, shed int64
+	classes                                     [5]int64 // 1xx to 5xx
+}
+
+// StatsSnapshot is a copy of Stats at some point.
+type StatsSnapshot struct {
+	Requests int64            `json:"requests"`  // tries sent
+	Retries  int64            `json:"retries"`   // tries after the first
+	Failures int64            `json:"failures"`  // tries without a response
+	InFlight int64            `json:"in_flight"` // tries not done yet
+	Shed     int64            `json:"shed"`      // tries failed with ErrOverloaded
+	Status   map[string]int64 `json:"status"`    // responses by class, e.g., "2xx"
+}
+
+// Snapshot returns the current counts.
+func (s *Stats) Snapshot() StatsSnapshot {
+	snap := StatsSnapshot{
+		Requests: atomic.LoadInt64(&s.requests),
+		Retries:  atomic.LoadInt64(&s.retries),
+		Failures: atomic.LoadInt64(&s.failures),
+		InFlight: atomic.LoadInt64(&s.inFlight),
+		Shed:     atomic.LoadInt64(&s.shed),
+		Status:   make(map[string]int64, len(s.classes)),
+	}
+	for i := range s.classes {
+		snap.Status[fmt.Sprintf("%dxx", i+1)] = atomic.LoadInt64(&s.classes[i])
+	}
+	return snap
+}
+
+// countingBody counts the try in flight until closed.
+type countingBody struct {
+	io.ReadCloser
+	stats *Stats
+	once  sync.Once
+}
+
+func (b *countingBody) Close() error {
+	b.once.Do(func() {
+		atomic.AddInt64(&b.stats.inFlight, -1)
+	})
+	return b.ReadCloser.Close()
+}
+
+// do sends one try through the embedded http.Client,
+// within the concurrency limit and counted by Stats if set.
+func (c *SafeClient) do(req *http.Request) (*http.Response, error) {
+	req = c.prepare(req)
+	if c.CurlLog != nil {
+		c.logCurl(req)
+	}
+	if c.limit != nil {
+		return c.limit.do(c, req)
+	}
+	return c.send(req)
+}
+
+// send is do past the concurrency limit.
+func (c *SafeClient) send(req *http.Request) (*http.Response, error) {
+	s := c.Stats
+	if s == nil {
+		return c.Do(req)
+	}
+
+	atomic.AddInt64(&s.requests, 1)
+	atomic.AddInt64(&s.inFlight, 1)
+	resp, err := c.Do(req)
+	if err != nil {
+		atomic.AddInt64(&s.failures, 1)
+		atomic.AddInt64(&s.inFlight, -1)
+		return nil, err
+	}
+	if class := resp.StatusCode / 100; class >= 1 && class <= len(s.classes) {
+		atomic.AddInt64(&s.classes[class-1], 1)
+	}
+	resp.Body = &countingBody{ReadCloser: resp.Body, stats: s}
+	return resp, nil
+}
+
+// countRetry counts a try after the first.
+func (c *SafeClient) countRetry() {
+	if c.Stats != nil {
+		atomic.AddInt64(&c.Stats.retries, 1)
+	}
+}
+
+var expvarMu sync.Mutex
+
+// PublishExpvar publishes the client's Stats (set up if nil) as
+// an expvar.Func named prefix rendering a StatsSnapshot;
+// it returns an error if the name is taken.
+func (c *SafeClient) PublishExpvar(prefix string) error {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	if expvar.Get(prefix) != nil {
+		return fmt.Errorf("utils: expvar %q already published", prefix)
+	}
+	if c.Stats == nil {
+		c.Stats = &Stats{}
+	}
+	s := c.Stats
+	expvar.Publish(prefix, expvar.Func(func() interface{} {
+		return s.Snapshot()
+	}))
+	return nil
+}
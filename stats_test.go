@@ -0,0 +1,45 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestSafeClient_PublishExpvar(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	ok := httptest.NewServer(OkHandlerFunc)
+	defer ok.Close()
+	fail := httptest.NewServer(Status5xxHandlerFunc)
+	defer fail.Close()
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+
+	cl := testTimeoutClient
+	a.NoError(cl.PublishExpvar("utils_test_client"), "Published")
+	a.NotNil(cl.Stats, "Stats set up")
+
+	cl.DoRequest("GET", ok.URL, nil, 3, nil)
+	cl.DoRequest("GET", fail.URL, nil, 3, nil)
+	cl.DoRequest("GET", notFound.URL, nil, 3, nil)
+	cl.DoRequest("GET", "http://127.0.0.1:1", nil, 1, nil) // refused
+
+	var snap StatsSnapshot
+	err := json.Unmarshal([]byte(expvar.Get("utils_test_client").String()), &snap)
+	a.NoError(err, "Valid JSON")
+	a.Equal(int64(6), snap.Requests, "Every try counted")
+	a.Equal(int64(2), snap.Retries, "Retries of the 5xx")
+	a.Equal(int64(1), snap.Failures, "Tries without a response")
+	a.Equal(int64(0), snap.InFlight, "Nothing in flight")
+	a.Equal(map[string]int64{"1xx": 0, "2xx": 1, "3xx": 0, "4xx": 1, "5xx": 3}, snap.Status, "By status class")
+	a.Equal(snap, cl.Stats.Snapshot(), "Same as Snapshot")
+
+	cl2 := testTimeoutClient
+	a.True(cl2.PublishExpvar("utils_test_client") != nil, "Same prefix fails without panic")
+}
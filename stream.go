@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+)
+
+// DefaultStreamTries is used if SafeClient.StreamTries is unset.
+const DefaultStreamTries = 3
+
+// openStream GETs url with retries until a response worth reading arrives,
+// and returns it with the body open; a final non-2xx is an *HTTPError.
+func (c *SafeClient) openStream(ctx context.Context, url string, f RequestHook) (*http.Response, error) {
+	var resp *http.Response
+	_, err := c.retry(ctx, c.streamTries(), func(ctx context.Context) error {
+		req, err := newRequest(ctx, "GET", url, nil)
+		if err != nil {
+			return Permanent(err)
+		}
+		if err = applyHook(f, req); err != nil {
+			return Permanent(err)
+		}
+
+		resp, err = c.do(req)
+		if err != nil {
+			return c.classify(0, nil, err)
+		}
+		if err = c.classify(resp.StatusCode, resp.Header, nil); err != nil {
+			// drain to reuse the connection
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, &HTTPError{resp.StatusCode, body}
+	}
+	return resp, nil
+}
+
+// streamTries is the maxTries for opening a stream.
+func (c *SafeClient) streamTries() int {
+	if c.StreamTries > 0 {
+		return c.StreamTries
+	}
+	return DefaultStreamTries
+}
+
+// GetJSONStream GETs url and calls onItem for each element of the body as it
+// streams in, without buffering it: every value of an NDJSON body
+// (application/x-ndjson, or any sequence of JSON values) or every element of
+// a top-level JSON array. Opening the stream is retried StreamTries times;
+// a failure after that is reported with the index of the element,
+// and an error from onItem aborts the stream (ErrStop without an error).
+func (c *SafeClient) GetJSONStream(ctx context.Context, url string, f RequestHook, onItem func(json.RawMessage) error) error {
+	resp, err := c.openStream(ctx, url, f)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	r := bufio.NewReader(resp.Body)
+	dec := json.NewDecoder(r)
+
+	array := false
+	if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt != "application/x-ndjson" && mt != "application/jsonl" {
+		array = peekNonSpace(r) == '['
+	}
+
+	if array {
+		if _, err = dec.Token(); err != nil {
+			return fmt.Errorf("utils: stream element 0: %w", err)
+		}
+	}
+	for i := 0; ; i++ {
+		if array && !dec.More() {
+			if _, err = dec.Token(); err != nil {
+				return fmt.Errorf("utils: stream element %d: %w", i, err)
+			}
+			return nil
+		}
+
+		var item json.RawMessage
+		if err = dec.Decode(&item); err != nil {
+			if err == io.EOF && !array {
+				return nil
+			}
+			return fmt.Errorf("utils: stream element %d: %w", i, err)
+		}
+		if err = onItem(item); err != nil {
+			if err == ErrStop {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// peekNonSpace returns the first non-whitespace byte without consuming it,
+// or 0 on error.
+func peekNonSpace(r *bufio.Reader) byte {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return 0
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			r.ReadByte()
+		default:
+			return b[0]
+		}
+	}
+}
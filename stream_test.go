@@ -0,0 +1,136 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+const streamItems = 5000
+
+// streamHandler streams n objects as NDJSON or a JSON array,
+// failing the first request with a 503.
+func streamHandler(array bool) http.Handler {
+	var reqs int32
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&reqs, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if array {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(" [\n"))
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		for i := 0; i < streamItems; i++ {
+			if array && i > 0 {
+				w.Write([]byte(","))
+			}
+			fmt.Fprintf(w, `{"id":%d}`+"\n", i)
+		}
+		if array {
+			w.Write([]byte("]"))
+		}
+	})
+}
+
+var streamClient = SafeClient{
+	Client:  http.Client{Timeout: 5 * time.Second},
+	Backoff: testBackoff,
+}
+
+func TestSafeClient_GetJSONStream(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	for _, array := range []bool{false, true} {
+		server := httptest.NewServer(streamHandler(array))
+
+		count := 0
+		err := streamClient.GetJSONStream(context.Background(), server.URL, nil, func(item json.RawMessage) error {
+			var v struct{ ID int }
+			if err := json.Unmarshal(item, &v); err != nil {
+				return err
+			}
+			if v.ID != count {
+				return fmt.Errorf("got %d at %d", v.ID, count)
+			}
+			count++
+			return nil
+		})
+		a.NoError(err, "Streamed after a retry")
+		a.Equal(streamItems, count, "Every element once")
+
+		server.Close()
+	}
+}
+
+func TestSafeClient_GetJSONStream_Abort(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(streamHandler(true))
+	defer server.Close()
+
+	errAbort := errors.New("abort")
+	count := 0
+	err := streamClient.GetJSONStream(context.Background(), server.URL, nil, func(json.RawMessage) error {
+		count++
+		if count == 10 {
+			return errAbort
+		}
+		return nil
+	})
+	a.Equal(errAbort, err, "Callback error returned")
+	a.Equal(10, count, "Aborted at once")
+
+	count = 0
+	err = streamClient.GetJSONStream(context.Background(), server.URL, nil, func(json.RawMessage) error {
+		count++
+		if count == 3 {
+			return ErrStop
+		}
+		return nil
+	})
+	a.NoError(err, "ErrStop is not an error")
+	a.Equal(3, count, "Stopped at once")
+}
+
+func TestSafeClient_GetJSONStream_Broken(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	// the connection is cut in the middle of the third element
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":0}` + "\n" + `{"id":1}` + "\n" + `{"id":`))
+		w.(http.Flusher).Flush()
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer server.Close()
+
+	count := 0
+	err := streamClient.GetJSONStream(context.Background(), server.URL, nil, func(json.RawMessage) error {
+		count++
+		return nil
+	})
+	a.True(err != nil && strings.Contains(err.Error(), "element 2"), "Reports the element index")
+	a.Equal(2, count, "Elements before the failure delivered")
+
+	// unexpected status
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	err = streamClient.GetJSONStream(context.Background(), notFound.URL, nil, func(json.RawMessage) error {
+		return nil
+	})
+	var herr *HTTPError
+	a.True(errors.As(err, &herr), "Returns *HTTPError")
+}
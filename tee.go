@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+)
+
+// TeeFunc returns the writer to copy the body of a response to,
+// closed once it is read, or nil to copy none.
+type TeeFunc func(method, url string, status int) io.WriteCloser
+
+// WithBodyTee copies the body of every response of the tries read
+// whole (e.g., by DoRequest, not Stream) to the writer fn returns,
+// as it is read; the body returned is unaffected, and errors
+// of the writer are logged to ErrorLog, never failing the call.
+func WithBodyTee(fn TeeFunc) Option {
+	return func(c *SafeClient) error {
+		c.BodyTee = fn
+		return nil
+	}
+}
+
+// logf logs to ErrorLog, if set, or the standard logger.
+func (c *SafeClient) logf(format string, args ...interface{}) {
+	if c.ErrorLog != nil {
+		c.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// readTee reads the body of the response to req through BodyTee.
+func (c *SafeClient) readTee(req *http.Request, status int, r io.Reader) ([]byte, error) {
+	url := req.URL.String()
+	w := c.BodyTee(req.Method, url, status)
+	if w == nil {
+		return ioutil.ReadAll(r)
+	}
+	tw := &teeWriter{w: w}
+	body, err := ioutil.ReadAll(io.TeeReader(r, tw))
+	if cerr := w.Close(); tw.err == nil {
+		tw.err = cerr
+	}
+	if tw.err != nil {
+		c.logf("utils: body tee of %s %s: %v", req.Method, url, tw.err)
+	}
+	return body, err
+}
+
+// teeWriter keeps the first error of w to itself.
+type teeWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (t *teeWriter) Write(p []byte) (int, error) {
+	if t.err == nil {
+		_, t.err = t.w.Write(p)
+	}
+	return len(p), nil
+}
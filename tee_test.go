@@ -0,0 +1,85 @@
+package utils_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+type bufferCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufferCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func (failingWriter) Close() error {
+	return nil
+}
+
+func TestWithBodyTee(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	payload := bytes.Repeat([]byte{0, 1, 2, 0xff}, 10000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(payload)
+	}))
+	defer server.Close()
+
+	var tees []*bufferCloser
+	var statuses []int
+	cl, err := NewClient(WithBodyTee(func(method, url string, status int) io.WriteCloser {
+		if !strings.Contains(url, "/audit/") {
+			return nil
+		}
+		statuses = append(statuses, status)
+		b := &bufferCloser{}
+		tees = append(tees, b)
+		return b
+	}))
+	a.NoError(err, "No error")
+
+	_, _, body, err := cl.DoRequest("GET", server.URL+"/audit/1", nil, 1, nil)
+	a.NoError(err, "No error")
+	a.Equal(payload, body, "Body unaffected")
+	cl.DoRequest("GET", server.URL+"/other", nil, 1, nil)
+	a.Equal(1, len(tees), "Matching URLs only")
+	a.Equal(payload, tees[0].Bytes(), "Byte-for-byte copy")
+	a.True(tees[0].closed, "Closed")
+	a.Equal([]int{http.StatusOK}, statuses, "Told the status")
+}
+
+func TestWithBodyTee_Error(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(OkHandlerFunc)
+	defer server.Close()
+
+	cl, _ := NewClient(WithBodyTee(func(method, url string, status int) io.WriteCloser {
+		return failingWriter{}
+	}))
+	var logged bytes.Buffer
+	cl.ErrorLog = log.New(&logged, "", 0)
+	_, status, body, err := cl.DoRequest("POST", server.URL, nil, 1, nil)
+	a.NoError(err, "Never fails the call")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal("OK", string(body), "Body unaffected")
+	a.Equal("utils: body tee of POST "+server.URL+": disk full\n", logged.String(), "Logged")
+}
@@ -4,18 +4,73 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"log"
 	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
 // RequestHook can modify the Request anyway it wants.
 type RequestHook func(req *http.Request)
 
+// RequestHookE is a RequestHook that can fail, e.g., a signing hook;
+// adapted by Hook, it is accepted wherever a RequestHook is,
+// and its error aborts the request before anything is sent.
+// Such errors are returned as is and never retried.
+type RequestHookE func(req *http.Request) error
+
+// hookErrKey keys the error of a RequestHookE in the request context.
+type hookErrKey struct{}
+
+// Hook adapts h into a RequestHook.
+func (h RequestHookE) Hook() RequestHook {
+	return func(req *http.Request) {
+		if err := h(req); err != nil {
+			*req = *req.WithContext(context.WithValue(req.Context(), hookErrKey{}, err))
+		}
+	}
+}
+
+// ContextHook is a RequestHook that reads values from the context
+// of the call, e.g., a tenant ID or an auth principal;
+// adapted by Hook, it is accepted wherever a RequestHook is.
+type ContextHook func(ctx context.Context, req *http.Request)
+
+// Hook adapts h into a RequestHook.
+// It relies on req.Context() being the caller's context,
+// which holds for every try of the *Context methods.
+func (h ContextHook) Hook() RequestHook {
+	return func(req *http.Request) {
+		h(req.Context(), req)
+	}
+}
+
+// applyHook calls f, if any, on req and returns the error
+// reported by an adapted RequestHookE; req is dropped then,
+// so its body is closed, stopping a streamed one.
+func applyHook(f RequestHook, req *http.Request) error {
+	if f == nil {
+		return nil
+	}
+	f(req)
+	if err, ok := req.Context().Value(hookErrKey{}).(error); ok {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return err
+	}
+	return nil
+}
+
 // NewJSONPost returns a Request with json encoded and header set;
 // additional headers or cookies can be set through the RequestHook.
 func NewJSONPost(url string, v interface{}, f RequestHook) (*http.Request, error) {
@@ -24,13 +79,13 @@ func NewJSONPost(url string, v interface{}, f RequestHook) (*http.Request, error
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
+	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
 
-	if f != nil {
-		f(req)
+	if err = applyHook(f, req); err != nil {
+		return nil, err
 	}
 
 	req.Header.Add("Content-Type", "application/json; charset=utf-8")
@@ -40,13 +95,13 @@ func NewJSONPost(url string, v interface{}, f RequestHook) (*http.Request, error
 
 // NewFormPost returns a Request with default "Content-type: text/plain".
 func NewFormPost(url string, v url.Values, f RequestHook) (*http.Request, error) {
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(v.Encode())))
+	req, err := http.NewRequest("POST", url, strings.NewReader(v.Encode()))
 	if err != nil {
 		return nil, err
 	}
 
-	if f != nil {
-		f(req)
+	if err = applyHook(f, req); err != nil {
+		return nil, err
 	}
 
 	return req, nil
@@ -60,6 +115,16 @@ func ShouldRetry(statusCode int) bool {
 	return statusCode == http.StatusRequestTimeout || (statusCode >= 500 && statusCode <= 599)
 }
 
+// HTTPError reports a final response with an unexpected status.
+type HTTPError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("utils: unexpected status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
 // IsTimeoutErr checks if an error is timeout by cast it to net.Error.
 func IsTimeoutErr(e error) bool {
 	if err, ok := e.(net.Error); ok {
@@ -68,27 +133,97 @@ func IsTimeoutErr(e error) bool {
 	return false
 }
 
+// retryable tells if a request error is worth another try.
+func (c *SafeClient) retryable(err error) bool {
+	if errors.Is(err, ErrBlockedAddress) || errors.Is(err, ErrOverloaded) || errors.Is(err, ErrBodyBudgetExceeded) {
+		return false
+	}
+	return !c.TimeoutOnly || IsTimeoutErr(err)
+}
+
 // Backoff implements the exponential backoff algorithm with jitter for client sending remote calls.
 // It use an alternative method described in https://www.awsarchitectureblog.com/2015/03/backoff.html:
+// sleeps are in milliseconds, a zero (or negative) BaseSleep or MaxSleep
+// being StdClient's, so the zero Backoff is usable.
 type Backoff struct {
 	BaseSleep, MaxSleep int
 }
 
+// the Backoff of StdClient
+const (
+	defaultBaseSleep = 100
+	defaultMaxSleep  = 5000
+)
+
 // Next returns the next sleep time computed by the previous one;
 // the Decorrelated Jitter is:
 // sleep = min(cap, random_between(base, sleep * 3)).
 func (b *Backoff) Next(previous int) int {
-	if previous <= b.BaseSleep {
-		previous = b.BaseSleep
+	return b.next(previous, rand.Intn)
+}
+
+// next is Next drawing from intn.
+func (b *Backoff) next(previous int, intn func(n int) int) int {
+	base, max := b.BaseSleep, b.MaxSleep
+	if base <= 0 {
+		base = defaultBaseSleep
+	}
+	if max <= 0 {
+		max = defaultMaxSleep
+	}
+	if previous <= base {
+		previous = base
 	}
 	// Intn will panic if arg <= 0
-	sleep := rand.Intn(previous*3-b.BaseSleep) + b.BaseSleep
-	if sleep > b.MaxSleep {
-		return b.MaxSleep
+	sleep := intn(previous*3-base) + base
+	if sleep > max {
+		return max
 	}
 	return sleep
 }
 
+// Schedule returns the waits between the tries of a call of attempts
+// tries, one less, as drawn by an Iterator of seed 0: a representative
+// schedule, the same for every call.
+func (b Backoff) Schedule(attempts int) []time.Duration {
+	if attempts <= 1 {
+		return nil
+	}
+	it := b.Iterator(0)
+	waits := make([]time.Duration, attempts-1)
+	for i := range waits {
+		waits[i] = it.Next()
+	}
+	return waits
+}
+
+// Iterator returns an Iterator over the waits of b seeded by seed.
+func (b Backoff) Iterator(seed int64) *Iterator {
+	return &Iterator{b: b, seed: seed, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Iterator draws the waits of a Backoff one after another
+// from a source of its own, so iterators of the same seed
+// give the same waits; it is not safe for concurrent use.
+type Iterator struct {
+	b    Backoff
+	seed int64
+	rng  *rand.Rand
+	prev int
+}
+
+// Next returns the next wait.
+func (it *Iterator) Next() time.Duration {
+	it.prev = it.b.next(it.prev, it.rng.Intn)
+	return time.Duration(it.prev) * time.Millisecond
+}
+
+// Reset starts the waits over, as drawn the first time.
+func (it *Iterator) Reset() {
+	it.rng.Seed(it.seed)
+	it.prev = 0
+}
+
 // HTTPClient provides additional features upon http.Client,
 // e.g., io Reader handle and request retry;
 // it also normalize the HTTP response.
@@ -103,66 +238,160 @@ type SafeClient struct {
 	TimeoutOnly bool
 	http.Client // embedded
 	Backoff
+
+	// Sleep waits between tries; nil sleeps for real.
+	Sleep Sleeper
+
+	// LongPollTimeout replaces Client.Timeout for LongPoll requests,
+	// which hang on purpose; 0 means no timeout other than the context.
+	LongPollTimeout time.Duration
+
+	// StreamTries is the maxTries for opening a stream in
+	// GetJSONStream; DefaultStreamTries if not positive.
+	StreamTries int
+
+	// GraphQLTries is the maxTries of GraphQL calls;
+	// DefaultGraphQLTries if not positive.
+	GraphQLTries int
+
+	// WarmupMethod is the method of Warmup requests; HEAD if empty.
+	WarmupMethod string
+
+	// Stats, if set, counts the requests sent.
+	Stats *Stats
+
+	// MaxPages bounds GetAllPages; DefaultMaxPages if not positive.
+	MaxPages int
+
+	// MaxWait, if positive, caps every sleep between tries,
+	// whatever the Backoff or a Retry-After header asks for.
+	MaxWait time.Duration
+
+	// OnRetry, if set, is called before each sleep between tries.
+	OnRetry func(AttemptInfo)
+
+	// RetryTooManyRequests retries 429 as a should-retry status too;
+	// see WithRetryTooManyRequests.
+	RetryTooManyRequests bool
+
+	// StatusMultipliers scales the backoff wait after a should-retry
+	// status, e.g., {429: 4} with RetryTooManyRequests to back off
+	// harder when throttled;
+	// NetworkErrorMultiplier does the same after a request error.
+	// Unset (or non-positive) ones are 1; Retry-After is not scaled.
+	StatusMultipliers      map[int]float64
+	NetworkErrorMultiplier float64
+
+	// SLA, if positive, is the latency of a logical call (tries and
+	// sleeps in between) beyond which OnSLAExceeded is called; see WithSLA.
+	SLA           time.Duration
+	OnSLAExceeded SLAFunc
+
+	// Fallback, if set, stands in for a call whose tries ran out;
+	// see WithFallback.
+	Fallback FallbackFunc
+
+	// DefaultHeaders are sent with every try unless already set;
+	// HostHeaders are those for the hosts matching their key, an exact
+	// host or a "*.example.com" pattern, regardless of case and port.
+	// The RequestHook wins over both, and HostHeaders over DefaultHeaders.
+	DefaultHeaders http.Header
+	HostHeaders    map[string]http.Header
+
+	// AttemptHeader, if set, is sent on every try of a retrying method
+	// with the zero-based number of the try; see WithAttemptHeader.
+	AttemptHeader string
+
+	// OverrideMethods are sent as POSTs telling the method in
+	// MethodOverrideHeader; see WithMethodOverride.
+	OverrideMethods []string
+
+	// BodyDigest, if set, is the digest sent of request bodies:
+	// DigestMD5 or DigestSHA256; see WithBodyDigest.
+	BodyDigest string
+
+	// BodyTee, if set, is given a copy of the response bodies read
+	// whole; see WithBodyTee.
+	BodyTee TeeFunc
+
+	// CurlLog, if set, is given every try rendered by CurlString
+	// with CurlRedactHeaders redacted too; see WithCurlLog.
+	CurlLog           func(curl string)
+	CurlRedactHeaders []string
+
+	// ErrorLog logs the errors not failing a call, e.g., of a BodyTee;
+	// the log package's standard logger if nil.
+	ErrorLog *log.Logger
+
+	// MaxTotalBodyBytes, if positive, caps the response bytes read
+	// by all tries of a call; see WithMaxTotalBodyBytes.
+	MaxTotalBodyBytes int64
+
+	dial   *dialer         // set by dial-level options
+	cloned *http.Transport // the default one installed by options
+	h2c    *h2cTransport   // set by WithH2C
+	limit  *limiter        // set by WithMaxConcurrent
 }
 
-// RequestWithClose sends the request and returns statusCode and raw body.
-// It reads and closes Response.Body, return any error occurs.
-func (c *SafeClient) RequestWithClose(req *http.Request) (status int, body []byte, err error) {
-	var resp *http.Response
+// Response is a normalized http.Response with its body read.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
 
-	// Close() iff resp did return
-	if resp != nil {
-		defer resp.Body.Close()
-	}
+	// URL is the final URL requested, after any redirects.
+	URL *url.URL
+
+	// Fallback tells the Response was made up by the client's Fallback.
+	Fallback bool
+}
 
-	resp, err = c.Do(req)
+// requestWithClose is RequestWithClose that keeps the header.
+func (c *SafeClient) requestWithClose(req *http.Request) (res Response, err error) {
+	resp, err := c.do(req)
 	if err != nil {
 		return
 	}
+	defer resp.Body.Close()
 
-	status = resp.StatusCode
-
-	body, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
+	res.Status = resp.StatusCode
+	res.Header = resp.Header
+	if resp.Request != nil {
+		res.URL = resp.Request.URL
+	}
+	var body io.Reader = resp.Body
+	if b, ok := req.Context().Value(budgetKey{}).(*bodyBudget); ok {
+		body = &budgetReader{body, b}
+	}
+	if c.BodyTee == nil {
+		res.Body, err = ioutil.ReadAll(body)
 		return
 	}
-
+	res.Body, err = c.readTee(req, res.Status, body)
 	return
 }
 
+// RequestWithClose sends the request and returns statusCode and raw body.
+// It reads and closes Response.Body, return any error occurs.
+func (c *SafeClient) RequestWithClose(req *http.Request) (status int, body []byte, err error) {
+	res, err := c.requestWithClose(req)
+	return res.Status, res.Body, err
+}
+
 // RequestWithRetry wraps RequestWithClose and exponential-backoff
 // retries in following conditions:
 // 1. timeout error occurs (mostly client-side);
 // 2. server-side should-retry statusCode returned.
 // It returns the last response if tries run out.
-// NOTICE: retry works for request with no body only before go1.9.
+// NOTICE: req is sent as is every try, so a body is read up only by
+// the first; use DoRequest for requests with one.
 func (c *SafeClient) RequestWithRetry(req *http.Request, maxTries int) (tries, status int, body []byte, err error) {
-	// 0 will trigger setting wait to base
-	wait := 0
-
-	for ; tries < maxTries; tries++ {
-		// update next sleep time
-		wait = c.Next(wait)
-		// do request
-		status, body, err = c.RequestWithClose(req)
-		if err != nil {
-			if !c.TimeoutOnly || IsTimeoutErr(err) {
-				time.Sleep(time.Duration(wait) * time.Millisecond)
-				continue
-			}
-			return
-		}
-		// no error, check status
-		if ShouldRetry(status) {
-			time.Sleep(time.Duration(wait) * time.Millisecond)
-			continue
-		}
-		// succeed or should not repeat
-		return
-	}
-
-	// return the last request's response, succeed or not
-	tries--
+	tries, tm, err := c.retryTimed(req.Context(), maxTries, func(ctx context.Context) error {
+		res, err := c.requestWithClose(req.WithContext(ctx))
+		status, body = res.Status, res.Body
+		return c.classify(status, res.Header, err)
+	})
+	c.checkSLA(req.Method, req.URL.String(), tm, tries, status)
 	return
 }
 
@@ -170,51 +399,92 @@ func (c *SafeClient) RequestWithRetry(req *http.Request, maxTries int) (tries, s
 // initialize a Request each time to ensure Body get consumed.
 // Additional headers or cookies can be set through the RequestHook.
 func (c *SafeClient) DoRequest(method, url string, content []byte, maxTries int, f RequestHook) (tries, status int, body []byte, err error) {
-	var req *http.Request
-	wait := 0
+	return c.DoRequestContext(context.Background(), method, url, content, maxTries, f)
+}
 
-	for ; tries < maxTries; tries++ {
-		// make a new request each time
-		if len(content) > 0 {
-			req, err = http.NewRequest(method, url, bytes.NewBuffer(content))
-		} else {
-			req, err = http.NewRequest(method, url, nil)
-		}
-		if err != nil {
-			return
-		}
+// DoRequestContext is DoRequest with every Request made with ctx,
+// which also cancels the sleeps between tries.
+func (c *SafeClient) DoRequestContext(ctx context.Context, method, url string, content []byte, maxTries int, f RequestHook) (tries, status int, body []byte, err error) {
+	tries, res, err := c.doRequest(ctx, method, url, content, maxTries, f)
+	if res.Fallback {
+		err = ErrFallback
+	}
+	return tries, res.Status, res.Body, err
+}
 
-		if f != nil {
-			f(req)
+// Send is DoRequestContext returning the whole last Response,
+// e.g., to tell by its URL if the request was redirected.
+func (c *SafeClient) Send(ctx context.Context, method, url string, content []byte, maxTries int, f RequestHook) (tries int, res Response, err error) {
+	return c.doRequest(ctx, method, url, content, maxTries, f)
+}
+
+// doRequest is DoRequest with ctx bound to every Request
+// and the response header kept.
+func (c *SafeClient) doRequest(ctx context.Context, method, url string, content []byte, maxTries int, f RequestHook) (tries int, res Response, err error) {
+	// the same for every try
+	key, digest := c.digest(content)
+	return c.doRequestFunc(ctx, method, url, func(ctx context.Context) (*http.Request, error) {
+		req, err := newRequest(ctx, method, url, content)
+		if err == nil && key != "" {
+			req.Header.Set(key, digest)
 		}
+		return req, err
+	}, maxTries, f)
+}
 
-		wait = c.Next(wait)
-		status, body, err = c.RequestWithClose(req)
+// doRequestFunc is doRequest with every Request made by newReq.
+func (c *SafeClient) doRequestFunc(ctx context.Context, method, url string, newReq func(ctx context.Context) (*http.Request, error), maxTries int, f RequestHook) (tries int, res Response, err error) {
+	tries, tm, err := c.retryTimed(ctx, maxTries, func(ctx context.Context) error {
+		// make a new request each time
+		req, err := newReq(ctx)
 		if err != nil {
-			if !c.TimeoutOnly || IsTimeoutErr(err) {
-				time.Sleep(time.Duration(wait) * time.Millisecond)
-				continue
-			}
-			return
+			return Permanent(err)
 		}
-		if ShouldRetry(status) {
-			time.Sleep(time.Duration(wait) * time.Millisecond)
-			continue
+
+		if err = applyHook(f, req); err != nil {
+			return Permanent(err)
 		}
-		return
-	}
 
-	tries--
+		res, err = c.requestWithClose(req)
+		return c.classify(res.Status, res.Header, err)
+	})
+	c.checkSLA(method, url, tm, tries, res.Status)
+	if tm.gaveUp {
+		res, err = c.fallback(method, url, res, err)
+	}
 	return
 }
 
+// newRequest makes a Request with a fresh reader over content;
+// GetBody is set too, so a 307 or 308 redirect re-sends it.
+func newRequest(ctx context.Context, method, url string, content []byte) (*http.Request, error) {
+	if len(content) > 0 {
+		return http.NewRequestWithContext(ctx, method, url, bytes.NewReader(content))
+	}
+	return http.NewRequestWithContext(ctx, method, url, nil)
+}
+
+// GetWithRetry is a convenient method for GET requests;
+// params, if not nil, is encoded by EncodeQuery into the URL query.
+func (c *SafeClient) GetWithRetry(url string, params interface{}, maxTries int, f RequestHook) (tries, status int, body []byte, err error) {
+	if url, err = withQuery(url, params); err != nil {
+		return
+	}
+	return c.DoRequest("GET", url, nil, maxTries, f)
+}
+
 // PostJSONWithRetry is a convenient method for JSON POST requests.
 func (c *SafeClient) PostJSONWithRetry(url string, v interface{}, maxTries int, f RequestHook) (tries, status int, body []byte, err error) {
+	return c.PostJSONWithRetryContext(context.Background(), url, v, maxTries, f)
+}
+
+// PostJSONWithRetryContext is PostJSONWithRetry with a context.
+func (c *SafeClient) PostJSONWithRetryContext(ctx context.Context, url string, v interface{}, maxTries int, f RequestHook) (tries, status int, body []byte, err error) {
 	data, err := json.Marshal(v)
 	if err != nil {
 		return
 	}
-	return c.DoRequest("POST", url, data, maxTries, func(req *http.Request) {
+	return c.DoRequestContext(ctx, "POST", url, data, maxTries, func(req *http.Request) {
 		req.Header.Add("Content-Type", "application/json; charset=utf-8")
 		if f != nil {
 			f(req)
@@ -224,14 +494,19 @@ func (c *SafeClient) PostJSONWithRetry(url string, v interface{}, maxTries int,
 
 // PostFormWithRetry is a convenient method for form POST requests.
 func (c *SafeClient) PostFormWithRetry(url string, v url.Values, maxTries int, f RequestHook) (tries, status int, body []byte, err error) {
-	return c.DoRequest("POST", url, []byte(v.Encode()), maxTries, f)
+	return c.PostFormWithRetryContext(context.Background(), url, v, maxTries, f)
+}
+
+// PostFormWithRetryContext is PostFormWithRetry with a context.
+func (c *SafeClient) PostFormWithRetryContext(ctx context.Context, url string, v url.Values, maxTries int, f RequestHook) (tries, status int, body []byte, err error) {
+	return c.DoRequestContext(ctx, "POST", url, []byte(v.Encode()), maxTries, f)
 }
 
 // StdClient gives a ready-to-use SafeClient instance.
 func StdClient() *SafeClient {
 	return &SafeClient{
-		true,
-		http.Client{Timeout: 5 * time.Second},
-		Backoff{100, 5000},
+		TimeoutOnly: true,
+		Client:      http.Client{Timeout: 5 * time.Second},
+		Backoff:     Backoff{defaultBaseSleep, defaultMaxSleep},
 	}
 }
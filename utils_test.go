@@ -2,9 +2,9 @@ package utils_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -12,6 +12,8 @@ import (
 	"net/url"
 	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -112,12 +114,31 @@ var (
 	maxTimeout        = 50
 	testBackoff       = Backoff{minTimeout, maxTimeout}
 	testTimeoutClient = SafeClient{
-		true,
-		http.Client{Timeout: time.Duration(minTimeout) * time.Millisecond},
-		testBackoff,
+		TimeoutOnly: true,
+		Client:      http.Client{Timeout: time.Duration(minTimeout) * time.Millisecond},
+		Backoff:     testBackoff,
 	}
 )
 
+// fakeSleeper records the waits instead of sleeping.
+type fakeSleeper struct {
+	mu    sync.Mutex
+	waits []time.Duration
+}
+
+func (s *fakeSleeper) Sleep(ctx context.Context, d time.Duration) error {
+	s.mu.Lock()
+	s.waits = append(s.waits, d)
+	s.mu.Unlock()
+	return ctx.Err()
+}
+
+func (s *fakeSleeper) Waits() []time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Duration{}, s.waits...)
+}
+
 var TimeoutHandlerFunc = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	time.Sleep(20 * time.Millisecond)
@@ -135,6 +156,49 @@ func TestBackoff_Next(t *testing.T) {
 	a.True(sleep2 <= maxTimeout && sleep3 <= maxTimeout, "Each sleep < max")
 }
 
+func TestBackoff_Schedule(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	b := Backoff{100, 5000}
+	waits := b.Schedule(8)
+	a.Equal(7, len(waits), "A wait between tries")
+	for _, w := range waits {
+		a.True(w >= 100*time.Millisecond && w <= 5*time.Second, "Each wait bounded")
+	}
+	a.Equal(waits, b.Schedule(8), "Representative")
+	a.Equal(0, len(b.Schedule(1)), "No wait for one try")
+
+	it1, it2 := b.Iterator(42), b.Iterator(42)
+	var first []time.Duration
+	for i := 0; i < 20; i++ {
+		w := it1.Next()
+		a.Equal(w, it2.Next(), "Same seed, same waits")
+		a.True(w >= 100*time.Millisecond && w <= 5*time.Second, "Each wait bounded")
+		first = append(first, w)
+	}
+	it1.Reset()
+	for i := 0; i < 20; i++ {
+		a.Equal(first[i], it1.Next(), "Reset starts over")
+	}
+}
+
+func TestBackoff_Zero(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var b Backoff
+	for _, w := range b.Schedule(20) {
+		a.True(w >= 100*time.Millisecond && w <= 5*time.Second, "StdClient's bounds")
+	}
+	for _, w := range (Backoff{MaxSleep: 1000}).Schedule(20) {
+		a.True(w >= 100*time.Millisecond && w <= time.Second, "Only BaseSleep defaulted")
+	}
+	a.True((Backoff{BaseSleep: 200}).Iterator(1).Next() >= 200*time.Millisecond, "Only MaxSleep defaulted")
+
+	tries, err := Retry(context.Background(), b, 1, func(ctx context.Context) error { return nil })
+	a.NoError(err, "Retried with the zero Backoff")
+	a.Equal(0, tries, "First try final")
+}
+
 type closeTest struct {
 	h             http.Handler
 	expectedCode  int
@@ -474,12 +538,147 @@ func TestStdClient(t *testing.T) {
 
 	cl := StdClient()
 	a.NotNil(cl, "StdClient not nil")
-	addr := fmt.Sprintf("%p", cl)
 
 	cl2 := StdClient()
-	addr2 := fmt.Sprintf("%p", cl2)
-
 	a.Equal(cl, cl2, "Every call returns a value-equal client")
-	//fmt.Println(addr, addr2)
-	a.NotEqual(addr, addr2, "Every call returns a defferent client")
+	a.NotSame(cl, cl2, "Every call returns a different client")
+}
+
+func TestRequestHookE(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var hits int32
+	server := httptest.NewServer(countingHandler(&hits, OkHandlerFunc))
+	defer server.Close()
+
+	errSign := errors.New("expired key")
+	failing := RequestHookE(func(req *http.Request) error {
+		return errSign
+	}).Hook()
+	signing := RequestHookE(func(req *http.Request) error {
+		req.Header.Set("x-test", "test")
+		return nil
+	}).Hook()
+
+	cl := testTimeoutClient
+	cl.TimeoutOnly = false // would retry any other error
+
+	n, status, _, err := cl.DoRequest("GET", server.URL, nil, 3, failing)
+	a.Equal(errSign, err, "Hook error returned")
+	a.Equal(0, n, "Not retried")
+	a.Equal(0, status, "No response")
+
+	_, _, _, err = cl.PostJSONWithRetry(server.URL, testContent{"foo"}, 3, failing)
+	a.Equal(errSign, err, "Hook error returned from wrapped hook")
+
+	_, err = NewJSONPost(server.URL, testContent{"foo"}, failing)
+	a.Equal(errSign, err, "NewJSONPost fails")
+	_, err = NewFormPost(server.URL, url.Values{}, failing)
+	a.Equal(errSign, err, "NewFormPost fails")
+
+	a.Equal(int32(0), atomic.LoadInt32(&hits), "Zero requests sent")
+
+	// hooks that succeed work as RequestHook
+	check := httptest.NewServer(CheckHeaderHandler("x-test", "test"))
+	defer check.Close()
+	_, status, _, err = cl.DoRequest("GET", check.URL, nil, 3, signing)
+	a.NoError(err, "No error")
+	a.Equal(http.StatusOK, status, "Hooked header")
+}
+
+type tenantKey struct{}
+
+func TestContextHook(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	// fail the first try, then check the header
+	var tries int32
+	var tenants []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tenants = append(tenants, r.Header.Get("x-tenant"))
+		mu.Unlock()
+		if atomic.AddInt32(&tries, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	hook := ContextHook(func(ctx context.Context, req *http.Request) {
+		if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+			req.Header.Set("x-tenant", tenant)
+		}
+	}).Hook()
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	n, status, _, err := testTimeoutClient.DoRequestContext(ctx, "GET", server.URL, nil, 3, hook)
+	a.NoError(err, "No error")
+	a.Equal(http.StatusOK, status, "Returns code")
+	a.Equal(1, n, "Retried once")
+	a.Equal([]string{"acme", "acme"}, tenants, "Context value on every try")
+
+	// the POST helpers too
+	tenants = nil
+	atomic.StoreInt32(&tries, 0)
+	_, _, _, err = testTimeoutClient.PostJSONWithRetryContext(ctx, server.URL, testContent{"foo"}, 3, hook)
+	a.NoError(err, "No error")
+	a.Equal([]string{"acme", "acme"}, tenants, "Context value on every try")
+}
+
+func TestSafeClient_DoRequestContext_Cancel(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(Status5xxHandlerFunc)
+	defer server.Close()
+
+	cl := testTimeoutClient
+	cl.Backoff = Backoff{1000, 5000}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	n, status, _, err := cl.DoRequestContext(ctx, "GET", server.URL, nil, 5, nil)
+	a.Equal(context.DeadlineExceeded, err, "Cancelled while sleeping")
+	a.Equal(0, n, "One try")
+	a.Equal(http.StatusInternalServerError, status, "Last response kept")
+	a.True(time.Since(start) < 500*time.Millisecond, "Sleep cut short")
+}
+
+func TestSafeClient_Redirect_Body(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var got []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		code := http.StatusTemporaryRedirect
+		if r.URL.Query().Get("permanent") != "" {
+			code = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, "/new", code)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		got = append(got, string(body))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := context.Background()
+	_, res, err := testTimeoutClient.Send(ctx, "POST", server.URL+"/old", []byte("raw"), 1, nil)
+	a.NoError(err, "No error")
+	a.Equal(http.StatusOK, res.Status, "Returns code")
+	a.Equal("/new", res.URL.Path, "Final URL")
+
+	testTimeoutClient.Send(ctx, "PUT", server.URL+"/old?permanent=1", []byte("raw"), 1, nil)
+	testTimeoutClient.PostJSONWithRetry(server.URL+"/old", testContent{"json"}, 1, nil)
+	testTimeoutClient.PostFormWithRetry(server.URL+"/old", url.Values{"k": {"v"}}, 1, nil)
+	req, _ := NewJSONPost(server.URL+"/old", testContent{"new"}, nil)
+	testTimeoutClient.RequestWithRetry(req, 1)
+	req, _ = NewFormPost(server.URL+"/old", url.Values{"k": {"new"}}, nil)
+	testTimeoutClient.RequestWithRetry(req, 1)
+
+	a.Equal([]string{"raw", "raw", `{"data":"json"}`, "k=v", `{"data":"new"}`, "k=new"}, got, "Bodies survive 307/308")
 }
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Warmup sends a WarmupMethod (HEAD by default) request to each of urls,
+// at most concurrency at a time, only to dial (and TLS handshake) ahead of
+// the real requests and leave the connections idle in the Transport's pool
+// (the Transport's MaxIdleConnsPerHost caps how many are kept per host).
+// Response statuses are ignored; the errors of failed requests are returned
+// joined, along with ctx.Err() if ctx is done first.
+// It is safe to call repeatedly.
+func (c *SafeClient) Warmup(ctx context.Context, urls []string, concurrency int) error {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sema := NewSemaphore(concurrency)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, u := range urls {
+		if !sema.Obtain(ctx) {
+			break
+		}
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			defer sema.Release()
+			if err := c.warm(ctx, u); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(u)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// warm sends one warming request and parks its connection.
+func (c *SafeClient) warm(ctx context.Context, url string) error {
+	method := c.WarmupMethod
+	if method == "" {
+		method = "HEAD"
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("utils: warmup %s: %w", url, err)
+	}
+	// read to EOF so the connection can be reused
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,85 @@
+package utils_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"sync"
+	"testing"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+// traceReused records if the request reused a connection.
+func traceReused(reused *bool) RequestHook {
+	return func(req *http.Request) {
+		trace := &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				*reused = info.Reused
+			},
+		}
+		*req = *req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	}
+}
+
+func TestSafeClient_Warmup(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	var mu sync.Mutex
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.Method)
+		mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError) // ignored
+	}))
+	defer server.Close()
+
+	cl, _ := NewClient()
+
+	var reused bool
+	cl.DoRequest("GET", server.URL, nil, 1, traceReused(&reused))
+	a.Equal(false, reused, "Cold start dials")
+	mu.Lock()
+	methods = nil
+	mu.Unlock()
+
+	cl, _ = NewClient()
+	err := cl.Warmup(context.Background(), []string{server.URL}, 1)
+	a.NoError(err, "Status ignored")
+	mu.Lock()
+	a.Equal([]string{"HEAD"}, methods, "HEAD by default")
+	mu.Unlock()
+
+	cl.DoRequest("GET", server.URL, nil, 1, traceReused(&reused))
+	a.Equal(true, reused, "Following request reuses the connection")
+
+	a.NoError(cl.Warmup(context.Background(), []string{server.URL, server.URL}, 2), "Safe to repeat")
+
+	cl.WarmupMethod = "OPTIONS"
+	cl.Warmup(context.Background(), []string{server.URL}, 1)
+	mu.Lock()
+	a.Equal("OPTIONS", methods[len(methods)-1], "Method configurable")
+	mu.Unlock()
+}
+
+func TestSafeClient_Warmup_Errors(t *testing.T) {
+	a := assert.NewAssert(t)
+
+	server := httptest.NewServer(OkHandlerFunc)
+	defer server.Close()
+
+	cl, _ := NewClient()
+	err := cl.Warmup(context.Background(), []string{server.URL, "http://127.0.0.1:1", "http://127.0.0.1:2"}, 3)
+	a.True(err != nil, "Dial errors reported")
+	if err != nil {
+		a.Equal(2, len(err.(interface{ Unwrap() []error }).Unwrap()), "One error per failed origin")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = cl.Warmup(ctx, []string{server.URL}, 1)
+	a.True(err != nil, "Respects the context")
+}
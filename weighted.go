@@ -0,0 +1,537 @@
+package utils
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WeightedSemaphore is a Semaphore of resources obtained by weight,
+// e.g., the memory of a task, rather than one at a time.
+type WeightedSemaphore interface {
+	// Obtain puts weight into the semaphore, returns true if succeeds.
+	// It blocks until there is room or the context cancelled,
+	// waiting its turn behind earlier ones: a heavy waiter is not
+	// passed over by lighter ones coming after.
+	// It returns false immediately for weight over Capacity()
+	// or a closed semaphore; true for weight <= 0.
+	Obtain(ctx context.Context, weight int64) bool
+
+	// TryObtain is Obtain that never blocks, failing if anyone waits.
+	TryObtain(weight int64) bool
+
+	// Release takes weight from the semaphore, returns true if succeeds;
+	// it takes none if weight exceeds Count().
+	Release(weight int64) bool
+
+	// Capacity returns semaphore's max total weight.
+	Capacity() int64
+
+	// Count returns semaphore's current weight obtained.
+	Count() int64
+
+	// Close stops obtaining from semaphore, waking those blocked in
+	// Obtain; it makes Obtain() return false ever since.
+	Close()
+
+	// Closed tells if semaphore is closed.
+	Closed() bool
+
+	// Done returns a channel closed by Close, the same for every call.
+	Done() <-chan struct{}
+}
+
+// weighted implements WeightedSemaphore as a running total
+// and a FIFO queue of its waiters.
+type weighted struct {
+	size int64
+
+	mu      sync.Mutex
+	cur     int64
+	waiters list.List       // of *weightedWaiter
+	empty   []chan struct{} // of wait, closed once nothing is obtained
+	done    chan struct{}
+	closed  bool
+
+	// prioritized serves the waiters by priority, then the order
+	// they came, aged up a priority every aging if positive.
+	prioritized bool
+	aging       time.Duration
+}
+
+type weightedWaiter struct {
+	n     int64
+	ready chan struct{} // closed once granted
+
+	priority int
+	since    time.Time // when it came
+}
+
+// NewWeightedSemaphore returns a WeightedSemaphore of capacity,
+// serving its waiters in the order they came.
+func NewWeightedSemaphore(capacity int64) WeightedSemaphore {
+	return newWeighted(capacity)
+}
+
+func newWeighted(capacity int64) *weighted {
+	return &weighted{size: capacity, done: make(chan struct{})}
+}
+
+func (s *weighted) Obtain(ctx context.Context, weight int64) bool {
+	return s.obtain(ctx, weight) == nil
+}
+
+// obtain is Obtain returning ErrSemaphoreClosed, or the context's
+// error, if it fails.
+func (s *weighted) obtain(ctx context.Context, weight int64) error {
+	return s.obtainPriority(ctx, weight, 0)
+}
+
+// obtainPriority is obtain waiting as of priority.
+func (s *weighted) obtainPriority(ctx context.Context, weight int64, priority int) error {
+	if weight <= 0 {
+		return nil
+	}
+	if weight > s.size {
+		return fmt.Errorf("%w: weight %d over %d", ErrTooMany, weight, s.size)
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrSemaphoreClosed
+	}
+	if s.size-s.cur >= weight && s.waiters.Len() == 0 {
+		s.cur += weight
+		s.mu.Unlock()
+		return nil
+	}
+	w := &weightedWaiter{n: weight, ready: make(chan struct{}), priority: priority, since: time.Now()}
+	elem := s.waiters.PushBack(w)
+	s.mu.Unlock()
+
+	var err error
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-s.done:
+		err = ErrSemaphoreClosed
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-w.ready:
+		if !s.closed {
+			// granted meanwhile, keep it
+			return nil
+		}
+		s.release(weight)
+	default:
+		next := s.next() == elem
+		s.waiters.Remove(elem)
+		if next {
+			// the next may fit now
+			s.notify()
+		}
+	}
+	return err
+}
+
+func (s *weighted) TryObtain(weight int64) bool {
+	if weight <= 0 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.size-s.cur < weight || s.waiters.Len() > 0 {
+		return false
+	}
+	s.cur += weight
+	return true
+}
+
+func (s *weighted) Release(weight int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if weight < 0 || weight > s.cur {
+		return false
+	}
+	s.release(weight)
+	return true
+}
+
+// releaseUpTo releases at most weight, returns how much it did.
+func (s *weighted) releaseUpTo(weight int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if weight > s.cur {
+		weight = s.cur
+	}
+	if weight > 0 {
+		s.release(weight)
+	}
+	return weight
+}
+
+// release takes weight, waking the waiters it lets in (or the Waits
+// if none is left); s.mu must be held.
+func (s *weighted) release(weight int64) {
+	s.cur -= weight
+	s.notify()
+	if s.cur == 0 {
+		for _, ch := range s.empty {
+			close(ch)
+		}
+		s.empty = nil
+	}
+}
+
+// wait blocks until nothing is obtained or ctx is done.
+func (s *weighted) wait(ctx context.Context) error {
+	s.mu.Lock()
+	if s.cur == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	s.empty = append(s.empty, ch)
+	s.mu.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, c := range s.empty {
+			if c == ch {
+				s.empty = append(s.empty[:i], s.empty[i+1:]...)
+				break
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// notify grants the next waiters that fit, in order;
+// s.mu must be held.
+func (s *weighted) notify() {
+	if s.closed {
+		return
+	}
+	for {
+		next := s.next()
+		if next == nil {
+			return
+		}
+		w := next.Value.(*weightedWaiter)
+		if s.size-s.cur < w.n {
+			// not passing it over
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(next)
+		close(w.ready)
+	}
+}
+
+// next returns the waiter to be served next, the front one but by
+// priority; s.mu must be held.
+func (s *weighted) next() *list.Element {
+	front := s.waiters.Front()
+	if !s.prioritized || front == nil {
+		return front
+	}
+	var now time.Time
+	if s.aging > 0 {
+		now = time.Now()
+	}
+	best, bestPriority := front, s.effective(front.Value.(*weightedWaiter), now)
+	for e := front.Next(); e != nil; e = e.Next() {
+		// the earlier of a priority wins
+		if p := s.effective(e.Value.(*weightedWaiter), now); p > bestPriority {
+			best, bestPriority = e, p
+		}
+	}
+	return best
+}
+
+// effective returns the priority of w aged till now.
+func (s *weighted) effective(w *weightedWaiter, now time.Time) int {
+	if s.aging <= 0 {
+		return w.priority
+	}
+	return w.priority + int(now.Sub(w.since)/s.aging)
+}
+
+// snapshot returns the state of s, counted by unit weights.
+func (s *weighted) snapshot() SemaphoreSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := SemaphoreSnapshot{
+		Capacity: int(s.size),
+		Count:    int(s.cur),
+		Waiting:  s.waiters.Len(),
+		Closed:   s.closed,
+	}
+	if front := s.waiters.Front(); front != nil {
+		// the front one came first, whoever is served next
+		snap.OldestWait = time.Since(front.Value.(*weightedWaiter).since)
+	}
+	return snap
+}
+
+func (s *weighted) waiting() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.waiters.Len()
+}
+
+func (s *weighted) Capacity() int64 {
+	return s.size
+}
+
+func (s *weighted) Count() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cur
+}
+
+func (s *weighted) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// once closed, cannot be un-done
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+}
+
+func (s *weighted) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *weighted) Done() <-chan struct{} {
+	return s.done
+}
+
+// fair implements Semaphore as a weighted one of unit weights.
+type fair struct {
+	w    *weighted
+	held int32 // by AcquireAll, atomically
+}
+
+// NewFairSemaphore returns a Semaphore of n serving its waiters in the
+// order they came, so none starves however contended; a waiter
+// cancelled leaves the queue.
+func NewFairSemaphore(n int) Semaphore {
+	return &fair{w: newWeighted(int64(n))}
+}
+
+func (s *fair) Obtain(ctx context.Context) bool {
+	return s.w.obtain(ctx, 1) == nil
+}
+
+func (s *fair) ObtainErr(ctx context.Context) error {
+	return s.w.obtain(ctx, 1)
+}
+
+func (s *fair) TryObtain() bool {
+	return s.w.TryObtain(1)
+}
+
+func (s *fair) ObtainWithTimeout(d time.Duration) bool {
+	return obtainWithin(s, d) == nil
+}
+
+func (s *fair) ObtainWithTimeoutErr(d time.Duration) error {
+	return obtainWithin(s, d)
+}
+
+func (s *fair) ObtainN(ctx context.Context, n int) bool {
+	return s.w.Obtain(ctx, int64(n))
+}
+
+func (s *fair) ObtainNErr(ctx context.Context, n int) error {
+	return s.w.obtain(ctx, int64(n))
+}
+
+func (s *fair) Release() bool {
+	return s.w.Release(1)
+}
+
+func (s *fair) ReleaseErr() error {
+	if !s.w.Release(1) {
+		return ErrNothingHeld
+	}
+	return nil
+}
+
+func (s *fair) ReleaseN(n int) int {
+	return int(s.w.releaseUpTo(int64(n)))
+}
+
+func (s *fair) AcquireAll(ctx context.Context) bool {
+	// those after wait in the queue behind it
+	if !s.w.Obtain(ctx, s.w.size) {
+		return false
+	}
+	atomic.StoreInt32(&s.held, 1)
+	return true
+}
+
+func (s *fair) ReleaseAll() bool {
+	return atomic.CompareAndSwapInt32(&s.held, 1, 0) && s.w.Release(s.w.size)
+}
+
+func (s *fair) Capacity() int {
+	return int(s.w.Capacity())
+}
+
+func (s *fair) Count() int {
+	return int(s.w.Count())
+}
+
+func (s *fair) Close() {
+	s.w.Close()
+}
+
+func (s *fair) Closed() bool {
+	return s.w.Closed()
+}
+
+func (s *fair) Waiting() int {
+	return s.w.waiting()
+}
+
+func (s *fair) Snapshot() SemaphoreSnapshot {
+	return s.w.snapshot()
+}
+
+func (s *fair) String() string {
+	return s.w.snapshot().String()
+}
+
+func (s *fair) Done() <-chan struct{} {
+	return s.w.done
+}
+
+func (s *fair) Wait(ctx context.Context) error {
+	return s.w.wait(ctx)
+}
+
+func (s *fair) CloseAndWait(ctx context.Context) error {
+	return closeAndWait(ctx, s)
+}
+
+func (s *fair) Do(ctx context.Context, fn func() error) error {
+	return do(s, s.ObtainErr(ctx), fn)
+}
+
+func (s *fair) DoWithTimeout(d time.Duration, fn func() error) error {
+	return do(s, obtainWithin(s, d), fn)
+}
+
+// PrioritySemaphore is a Semaphore admitting its waiters by priority,
+// e.g., interactive requests before background ones.
+type PrioritySemaphore interface {
+	// Obtain puts one into the semaphore, returns true if succeeds.
+	// It blocks until succeeds or the context cancelled, the waiters
+	// of a higher priority served first, those of one in the order
+	// they came. Obtaining from a closed semaphore returns false.
+	Obtain(ctx context.Context, priority int) bool
+
+	// TryObtain is Obtain that never blocks, failing if anyone waits.
+	TryObtain() bool
+
+	// Release takes one from the semaphore, returns true if succeeds.
+	Release() bool
+
+	// Capacity returns semaphore's max concurrent resources.
+	Capacity() int
+
+	// Count returns semaphore's current used resources.
+	Count() int
+
+	// Close stops obtaining from semaphore, waking those blocked in
+	// Obtain; it makes Obtain() return false ever since.
+	Close()
+
+	// Closed tells if semaphore is closed.
+	Closed() bool
+
+	// Done returns a channel closed by Close, the same for every call.
+	Done() <-chan struct{}
+
+	// Waiting returns how many are blocked in Obtain.
+	Waiting() int
+
+	// Snapshot returns the state of the semaphore, as Semaphore's.
+	Snapshot() SemaphoreSnapshot
+}
+
+// prioritized implements PrioritySemaphore as a weighted one
+// of unit weights.
+type prioritized struct {
+	w *weighted
+}
+
+// NewPrioritySemaphore returns a PrioritySemaphore of n. A positive
+// aging raises a waiter a priority for every aging it waits, so
+// that the low ones never starve.
+func NewPrioritySemaphore(n int, aging time.Duration) PrioritySemaphore {
+	w := newWeighted(int64(n))
+	w.prioritized, w.aging = true, aging
+	return &prioritized{w}
+}
+
+func (s *prioritized) Obtain(ctx context.Context, priority int) bool {
+	return s.w.obtainPriority(ctx, 1, priority) == nil
+}
+
+func (s *prioritized) TryObtain() bool {
+	return s.w.TryObtain(1)
+}
+
+func (s *prioritized) Release() bool {
+	return s.w.Release(1)
+}
+
+func (s *prioritized) Capacity() int {
+	return int(s.w.Capacity())
+}
+
+func (s *prioritized) Count() int {
+	return int(s.w.Count())
+}
+
+func (s *prioritized) Close() {
+	s.w.Close()
+}
+
+func (s *prioritized) Closed() bool {
+	return s.w.Closed()
+}
+
+func (s *prioritized) Waiting() int {
+	return s.w.waiting()
+}
+
+func (s *prioritized) Snapshot() SemaphoreSnapshot {
+	return s.w.snapshot()
+}
+
+func (s *prioritized) String() string {
+	return s.w.snapshot().String()
+}
+
+func (s *prioritized) Done() <-chan struct{} {
+	return s.w.done
+}
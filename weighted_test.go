@@ -0,0 +1,227 @@
+package utils_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/ShevaXu/web-utils"
+	"github.com/ShevaXu/web-utils/assert"
+)
+
+func TestWeightedSemaphore(t *testing.T) {
+	assert := assert.NewAssert(t)
+
+	sema := NewWeightedSemaphore(10)
+	bc := context.Background()
+	assert.Equal(int64(10), sema.Capacity(), "Full cap")
+	assert.True(sema.Obtain(bc, 3), "Obtained 3")
+	assert.True(sema.Obtain(bc, 5), "Obtained 5")
+	assert.True(sema.TryObtain(2), "Obtained the last 2")
+	assert.Equal(int64(10), sema.Count(), "Now it is full")
+	assert.True(!sema.TryObtain(1), "No room")
+
+	ctx, cancel := context.WithTimeout(bc, 10*time.Millisecond)
+	defer cancel()
+	assert.True(!sema.Obtain(ctx, 1), "Should fail")
+	assert.True(!sema.Obtain(bc, 11), "Over capacity")
+
+	assert.True(sema.Release(5), "Released 5")
+	assert.True(!sema.Release(6), "More than obtained")
+	assert.Equal(int64(5), sema.Count(), "Exact accounting")
+	assert.True(sema.Release(3) && sema.Release(2), "Released the rest")
+	assert.Equal(int64(0), sema.Count(), "Empty")
+
+	sema.Close()
+	assert.True(sema.Closed(), "It is closed now")
+	assert.True(!sema.Obtain(bc, 1), "Should fail immediately when closed")
+}
+
+func TestWeightedSemaphore_Fair(t *testing.T) {
+	assert := assert.NewAssert(t)
+
+	sema := NewWeightedSemaphore(8)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// continuous weight-1 traffic
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if sema.Obtain(ctx, 1) {
+					time.Sleep(time.Millisecond)
+					sema.Release(1)
+				}
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	assert.True(sema.Obtain(ctx, 8), "Heavy waiter runs")
+	assert.Equal(int64(8), sema.Count(), "Has it all")
+	sema.Release(8)
+	close(stop)
+	wg.Wait()
+	assert.Equal(int64(0), sema.Count(), "All released")
+}
+
+func TestWeightedSemaphore_Cancel(t *testing.T) {
+	assert := assert.NewAssert(t)
+
+	sema := NewWeightedSemaphore(4)
+	bc := context.Background()
+	sema.Obtain(bc, 3)
+
+	// a cancelled waiter at the front lets the next one in
+	ctx, cancel := context.WithCancel(bc)
+	var obtained int32
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		if sema.Obtain(bc, 1) {
+			atomic.StoreInt32(&obtained, 1)
+		}
+	}()
+	assert.True(!sema.Obtain(ctx, 2), "Cancelled")
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(int32(1), atomic.LoadInt32(&obtained), "Next one in")
+	assert.Equal(int64(4), sema.Count(), "Exact accounting")
+
+	// Close wakes the waiters
+	result := make(chan bool)
+	go func() {
+		result <- sema.Obtain(bc, 1)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	sema.Close()
+	assert.True(!<-result, "Woken with false")
+	assert.Equal(int64(4), sema.Count(), "Nothing taken")
+}
+
+func TestFairSemaphore(t *testing.T) {
+	assert := assert.NewAssert(t)
+	const m = 20 // waiters
+
+	sema := NewFairSemaphore(1)
+	bc := context.Background()
+	sema.Obtain(bc)
+
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := 0; i < m; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if sema.Obtain(bc) {
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+				sema.Release()
+			}
+		}(i)
+		// queued in order
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	// a cancelled waiter leaves the queue
+	ctx, cancel := context.WithCancel(bc)
+	errs := make(chan error)
+	go func() {
+		errs <- sema.ObtainErr(ctx)
+	}()
+	time.Sleep(time.Millisecond)
+	cancel()
+	assert.Equal(context.Canceled, <-errs, "Cancelled")
+
+	// a slow releaser
+	time.Sleep(5 * time.Millisecond)
+	sema.Release()
+	wg.Wait()
+	expected := make([]int, m)
+	for i := range expected {
+		expected[i] = i
+	}
+	assert.Equal(expected, order, "Arrival order")
+	assert.Equal(0, sema.Count(), "All released")
+	assert.NoError(sema.Wait(bc), "Drained")
+
+	assert.True(sema.ObtainN(bc, 1) && !sema.TryObtain(), "Full")
+	assert.Equal(1, sema.ReleaseN(3), "Released what there was")
+	assert.Equal(ErrNothingHeld, sema.ReleaseErr(), "Nothing held")
+	sema.Close()
+	assert.Equal(ErrSemaphoreClosed, sema.ObtainErr(bc), "Closed")
+}
+
+// admitted returns the order the waiters of priorities obtain from
+// sema held by one, released after each came in turn.
+func admitted(sema PrioritySemaphore, priorities []int, wait time.Duration) []int {
+	bc := context.Background()
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i, p := range priorities {
+		wg.Add(1)
+		go func(i, p int) {
+			defer wg.Done()
+			if sema.Obtain(bc, p) {
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+				sema.Release()
+			}
+		}(i, p)
+		time.Sleep(wait)
+	}
+	sema.Release()
+	wg.Wait()
+	return order
+}
+
+func TestPrioritySemaphore(t *testing.T) {
+	assert := assert.NewAssert(t)
+	bc := context.Background()
+
+	sema := NewPrioritySemaphore(1, 0)
+	assert.True(sema.Obtain(bc, 0), "Obtained")
+	assert.True(!sema.TryObtain(), "Full")
+	assert.Equal([]int{1, 3, 2, 5, 0, 4}, admitted(sema, []int{0, 2, 1, 2, 0, 1}, 2*time.Millisecond), "By priority, then arrival")
+	assert.Equal(0, sema.Count(), "All released")
+
+	// a cancelled waiter leaves the queue
+	sema.Obtain(bc, 0)
+	ctx, cancel := context.WithTimeout(bc, 5*time.Millisecond)
+	defer cancel()
+	assert.True(!sema.Obtain(ctx, 9), "Cancelled")
+	assert.Equal([]int{1, 0}, admitted(sema, []int{0, 1}, 2*time.Millisecond), "Cancelled one gone")
+
+	sema.Close()
+	assert.True(!sema.Obtain(bc, 0) && sema.Closed(), "Closed")
+}
+
+func TestPrioritySemaphore_Aging(t *testing.T) {
+	assert := assert.NewAssert(t)
+	bc := context.Background()
+
+	sema := NewPrioritySemaphore(1, 0)
+	sema.Obtain(bc, 0)
+	assert.Equal([]int{1, 0}, admitted(sema, []int{0, 2}, 50*time.Millisecond), "Starved without aging")
+
+	sema = NewPrioritySemaphore(1, 10*time.Millisecond)
+	sema.Obtain(bc, 0)
+	assert.Equal([]int{0, 1}, admitted(sema, []int{0, 2}, 50*time.Millisecond), "Aged past higher")
+}